@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBatcherClosed = errors.New("batcher is closed")
+)
+
+// Batcher accumulates items into batches as described by a BatchHint.  A
+// batch is flushed when it has MaxMesasges items, or when its first item has
+// waited for MaxLingerDuration, whichever comes first.  A zero value means
+// there is no limit, and a BatchHint of all zeros disables batching so every
+// item is flushed by itself.
+//
+// A Batcher is safe for concurrent use.  The batches are passed to the flush
+// function one at a time, in the order they were filled.  The flush function
+// may call Len, but must not add to, flush or close the Batcher.
+type Batcher[T any] struct {
+	maxMessages int
+	maxLinger   time.Duration
+	clock       Clock
+	flush       func([]T)
+
+	seq sequencer
+
+	mu     sync.Mutex
+	batch  []T
+	timer  Timer
+	gen    uint64
+	closed bool
+}
+
+// NewBatcher creates a Batcher for the BatchHint that passes the batches to
+// flush.  If the clock is nil, the system clock is used.
+func NewBatcher[T any](hint BatchHint, clock Clock, flush func([]T)) (*Batcher[T], error) {
+	if err := hint.validate("batch_hints"); err != nil {
+		return nil, err
+	}
+	if flush == nil {
+		return nil, fmt.Errorf("%w: a flush function is required", ErrInvalidInput)
+	}
+
+	b := Batcher[T]{
+		maxMessages: hint.MaxMesasges,
+		maxLinger:   hint.MaxLingerDuration,
+		clock:       clockOrSystem(clock),
+		flush:       flush,
+	}
+	if b.maxMessages == 0 && b.maxLinger == 0 {
+		b.maxMessages = 1
+	}
+	return &b, nil
+}
+
+// Add adds the item to the current batch, flushing it if it is full.
+func (b *Batcher[T]) Add(item T) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBatcherClosed
+	}
+
+	b.batch = append(b.batch, item)
+	if b.maxMessages > 0 && len(b.batch) >= b.maxMessages {
+		b.release(b.take())
+		return nil
+	}
+
+	if len(b.batch) == 1 && b.maxLinger > 0 {
+		gen := b.gen
+		b.timer = b.clock.AfterFunc(b.maxLinger, func() {
+			b.expire(gen)
+		})
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Flush flushes the current batch, if it has any items.
+func (b *Batcher[T]) Flush() {
+	b.mu.Lock()
+	b.release(b.take())
+}
+
+// Close flushes the current batch and stops the Batcher.  Items added after
+// Close are rejected with ErrBatcherClosed.
+func (b *Batcher[T]) Close() {
+	b.mu.Lock()
+	b.closed = true
+	b.release(b.take())
+}
+
+// Len returns the number of items in the current batch.
+func (b *Batcher[T]) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.batch)
+}
+
+// expire flushes the batch the linger timer was started for, unless it has
+// already been flushed.
+func (b *Batcher[T]) expire(gen uint64) {
+	b.mu.Lock()
+	if gen != b.gen {
+		b.mu.Unlock()
+		return
+	}
+	b.release(b.take())
+}
+
+// take removes the current batch and starts a new one.  mu must be held.
+func (b *Batcher[T]) take() []T {
+	batch := b.batch
+	b.batch = nil
+	b.gen++
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	return batch
+}
+
+// release unlocks mu and flushes the batch, if it has any items.  mu must be
+// held.
+func (b *Batcher[T]) release(batch []T) {
+	if len(batch) == 0 {
+		b.mu.Unlock()
+		return
+	}
+
+	t := b.seq.ticket()
+	b.mu.Unlock()
+	b.seq.run(t, func() {
+		b.flush(batch)
+	})
+}
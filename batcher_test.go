@@ -0,0 +1,316 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a Clock that only moves when Advance is called.  The timer
+// functions are called synchronously by Advance.
+type fakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+type fakeTimer struct {
+	clock   *fakeClock
+	when    time.Time
+	f       func()
+	stopped bool
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: mockNow()}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, when: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward and calls the functions of the timers that
+// have fired, in the order they fire.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		var next *fakeTimer
+		for _, t := range c.timers {
+			if t.stopped || t.when.After(end) {
+				continue
+			}
+			if next == nil || t.when.Before(next.when) {
+				next = t
+			}
+		}
+		if next == nil {
+			break
+		}
+		next.stopped = true
+		c.now = next.when
+		c.mu.Unlock()
+		next.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+func (t *fakeTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	stopped := t.stopped
+	t.stopped = true
+	return !stopped
+}
+
+// collector records the flushed batches.
+type collector struct {
+	mu      sync.Mutex
+	batches [][]int
+}
+
+func (c *collector) flush(batch []int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.batches = append(c.batches, batch)
+}
+
+func (c *collector) get() [][]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.batches
+}
+
+func TestNewBatcher(t *testing.T) {
+	tests := []struct {
+		description string
+		hint        BatchHint
+		noFlush     bool
+		expectedErr error
+	}{
+		{
+			description: "valid",
+			hint:        BatchHint{MaxLingerDuration: time.Second, MaxMesasges: 10},
+		}, {
+			description: "disabled",
+		}, {
+			description: "negative linger",
+			hint:        BatchHint{MaxLingerDuration: -1},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative messages",
+			hint:        BatchHint{MaxMesasges: -1},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "no flush function",
+			noFlush:     true,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var c collector
+			flush := c.flush
+			if tc.noFlush {
+				flush = nil
+			}
+			b, err := NewBatcher(tc.hint, nil, flush)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Nil(t, b)
+				return
+			}
+			assert.NoError(t, err)
+			assert.NotNil(t, b)
+		})
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	tests := []struct {
+		description string
+		hint        BatchHint
+		// steps are either an item to add, or a negative number of seconds
+		// to advance the clock by.
+		steps    []int
+		expected [][]int
+		pending  int
+	}{
+		{
+			description: "disabled flushes every item",
+			steps:       []int{1, 2, 3},
+			expected:    [][]int{{1}, {2}, {3}},
+		}, {
+			description: "flush on count",
+			hint:        BatchHint{MaxMesasges: 2},
+			steps:       []int{1, 2, 3, 4, 5, -100},
+			expected:    [][]int{{1, 2}, {3, 4}},
+			pending:     1,
+		}, {
+			description: "flush on linger",
+			hint:        BatchHint{MaxLingerDuration: 10 * time.Second},
+			steps:       []int{1, 2, -5, 3, -5, 4, -9},
+			expected:    [][]int{{1, 2, 3}},
+			pending:     1,
+		}, {
+			description: "linger starts at the first item of a batch",
+			hint:        BatchHint{MaxLingerDuration: 10 * time.Second, MaxMesasges: 2},
+			steps:       []int{1, -5, 2, 3, -9, 4, -1},
+			expected:    [][]int{{1, 2}, {3, 4}},
+		}, {
+			description: "linger after count flush does not flush early",
+			hint:        BatchHint{MaxLingerDuration: 10 * time.Second, MaxMesasges: 2},
+			steps:       []int{1, 2, -5, 3, -6},
+			expected:    [][]int{{1, 2}},
+			pending:     1,
+		}, {
+			description: "linger flushes a single item",
+			hint:        BatchHint{MaxLingerDuration: time.Second, MaxMesasges: 5},
+			steps:       []int{1, -1, 2, -1},
+			expected:    [][]int{{1}, {2}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var c collector
+			clock := newFakeClock()
+			b, err := NewBatcher(tc.hint, clock, c.flush)
+			require.NoError(err)
+
+			for _, step := range tc.steps {
+				if step < 0 {
+					clock.Advance(time.Duration(-step) * time.Second)
+					continue
+				}
+				require.NoError(b.Add(step))
+			}
+
+			assert.Equal(tc.expected, c.get())
+			assert.Equal(tc.pending, b.Len())
+		})
+	}
+}
+
+func TestBatcherFlushAndClose(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var c collector
+	clock := newFakeClock()
+	b, err := NewBatcher(BatchHint{MaxLingerDuration: time.Second, MaxMesasges: 10}, clock, c.flush)
+	require.NoError(err)
+
+	// Flushing an empty batch does nothing.
+	b.Flush()
+	assert.Empty(c.get())
+
+	require.NoError(b.Add(1))
+	require.NoError(b.Add(2))
+	b.Flush()
+	assert.Equal([][]int{{1, 2}}, c.get())
+
+	// The linger timer of the flushed batch is stopped.
+	clock.Advance(time.Second)
+	assert.Equal([][]int{{1, 2}}, c.get())
+
+	require.NoError(b.Add(3))
+	b.Close()
+	assert.Equal([][]int{{1, 2}, {3}}, c.get())
+
+	assert.ErrorIs(b.Add(4), ErrBatcherClosed)
+	b.Close()
+	assert.Equal([][]int{{1, 2}, {3}}, c.get())
+}
+
+func TestBatcherConcurrent(t *testing.T) {
+	var c collector
+	b, err := NewBatcher(BatchHint{MaxLingerDuration: time.Millisecond, MaxMesasges: 7}, nil, c.flush)
+	require.NoError(t, err)
+
+	const workers, items = 8, 100
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < items; i++ {
+				assert.NoError(t, b.Add(w*items+i))
+			}
+		}(w)
+	}
+	wg.Wait()
+	b.Close()
+
+	seen := make(map[int]bool)
+	for _, batch := range c.get() {
+		assert.LessOrEqual(t, len(batch), 7)
+		for _, item := range batch {
+			assert.False(t, seen[item])
+			seen[item] = true
+		}
+	}
+	assert.Len(t, seen, workers*items)
+}
+
+func TestBatcherFlushCallsLen(t *testing.T) {
+	var (
+		b     *Batcher[int]
+		wg    sync.WaitGroup
+		c     collector
+		lens  []int
+		added = make(chan struct{})
+	)
+	b, err := NewBatcher(BatchHint{MaxMesasges: 1}, nil, func(batch []int) {
+		if batch[0] == 1 {
+			// Another item is added, and waits for its turn, while the
+			// first batch is being flushed.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				assert.NoError(t, b.Add(2))
+				close(added)
+			}()
+			time.Sleep(10 * time.Millisecond)
+		}
+		lens = append(lens, b.Len())
+		c.flush(batch)
+	})
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, b.Add(1))
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the flush function deadlocked with Add")
+	}
+	<-added
+	assert.Equal(t, [][]int{{1}, {2}}, c.get())
+	assert.Equal(t, []int{0, 0}, lens)
+}
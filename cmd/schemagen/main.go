@@ -0,0 +1,35 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// schemagen writes the JSON Schema documents for the registration types.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	webhook "github.com/xmidt-org/webhook-schema"
+)
+
+func main() {
+	out := flag.String("out", ".", "the directory to write the schema files to")
+	flag.Parse()
+
+	files := map[string]any{
+		"registration_v1.schema.json": webhook.RegistrationV1{},
+		"registration_v2.schema.json": webhook.RegistrationV2{},
+	}
+
+	for name, registration := range files {
+		b, err := webhook.GenerateSchema(registration)
+		if err == nil {
+			err = os.WriteFile(filepath.Join(*out, name), append(b, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to write %s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package compress implements the encodings that can be used as the Webhook
+// AcceptEncoding value.  It is a separate module, so that the brotli and zstd
+// libraries and the Go version they need are only required by the users of
+// this package.
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	webhook "github.com/xmidt-org/webhook-schema"
+)
+
+var (
+	ErrUnsupportedEncoding = errors.New("unsupported encoding")
+	ErrTooLarge            = errors.New("decompressed data is too large")
+)
+
+// zstdMaxLevel is the highest zstd compression level.
+const zstdMaxLevel = 22
+
+// Compressor compresses and decompresses bodies using one encoding.
+type Compressor interface {
+	// Compress compresses the data.  A level of 0 means the default level of
+	// the encoding, otherwise the level is specific to the encoding.
+	Compress(data []byte, level int) ([]byte, error)
+
+	// Decompress decompresses the data.  It fails with ErrTooLarge, without
+	// decompressing the rest of the data, once the result grows past
+	// maxSize bytes.  maxSize must be positive; math.MaxInt64 means there is
+	// no limit.
+	Decompress(data []byte, maxSize int64) ([]byte, error)
+}
+
+// Compressors maps encodings to the Compressor that implements them.
+// Additional encodings can be supported by adding them to the map.
+type Compressors map[string]Compressor
+
+// DefaultCompressors returns the Compressors for each encoding that can be
+// used as the Webhook AcceptEncoding value, along with
+// webhook.EncodingIdentity.
+func DefaultCompressors() Compressors {
+	return Compressors{
+		webhook.EncodingIdentity: identityCompressor{},
+		webhook.EncodingGzip:     gzipCompressor{},
+		webhook.EncodingDeflate:  deflateCompressor{},
+		webhook.EncodingBrotli:   brotliCompressor{},
+		webhook.EncodingZstd:     &zstdCompressor{},
+	}
+}
+
+// Compress compresses the data with the encoding.  See Compressor.
+func (c Compressors) Compress(encoding string, data []byte, level int) ([]byte, error) {
+	compressor, found := c[encoding]
+	if !found {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedEncoding, encoding)
+	}
+	return compressor.Compress(data, level)
+}
+
+// Decompress decompresses the data with the encoding.  See Compressor.
+func (c Compressors) Decompress(encoding string, data []byte, maxSize int64) ([]byte, error) {
+	compressor, found := c[encoding]
+	if !found {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnsupportedEncoding, encoding)
+	}
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("%w: the max size %d is not positive", webhook.ErrInvalidInput, maxSize)
+	}
+	return compressor.Decompress(data, maxSize)
+}
+
+type identityCompressor struct{}
+
+func (identityCompressor) Compress(data []byte, _ int) ([]byte, error) {
+	return data, nil
+}
+
+func (identityCompressor) Decompress(data []byte, maxSize int64) ([]byte, error) {
+	if int64(len(data)) > maxSize {
+		return nil, tooLarge(maxSize)
+	}
+	return data, nil
+}
+
+// tooLarge returns the error for data that is larger than maxSize.
+func tooLarge(maxSize int64) error {
+	return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, maxSize)
+}
+
+// decompress reads the decompressed data from r, stopping once it has read
+// more than maxSize bytes.
+func decompress(r io.Reader, maxSize int64) ([]byte, error) {
+	if maxSize == math.MaxInt64 {
+		return io.ReadAll(r)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, tooLarge(maxSize)
+	}
+	return data, nil
+}
+
+// compress writes the data to the writer w creates and returns the result.
+func compress(data []byte, w func(io.Writer) (io.WriteCloser, error)) ([]byte, error) {
+	var buf bytes.Buffer
+	wc, err := w(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = wc.Write(data); err != nil {
+		return nil, err
+	}
+	if err = wc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(data []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return compress(data, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriterLevel(w, level)
+	})
+}
+
+func (gzipCompressor) Decompress(data []byte, maxSize int64) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return decompress(r, maxSize)
+}
+
+// deflateCompressor uses the zlib format, which is what the http deflate
+// encoding refers to.
+type deflateCompressor struct{}
+
+func (deflateCompressor) Compress(data []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = zlib.DefaultCompression
+	}
+	return compress(data, func(w io.Writer) (io.WriteCloser, error) {
+		return zlib.NewWriterLevel(w, level)
+	})
+}
+
+func (deflateCompressor) Decompress(data []byte, maxSize int64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return decompress(r, maxSize)
+}
+
+type brotliCompressor struct{}
+
+func (brotliCompressor) Compress(data []byte, level int) ([]byte, error) {
+	if level == 0 {
+		level = brotli.DefaultCompression
+	}
+	if level < brotli.BestSpeed || level > brotli.BestCompression {
+		return nil, fmt.Errorf("%w: brotli level %d", webhook.ErrInvalidInput, level)
+	}
+	return compress(data, func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, level), nil
+	})
+}
+
+func (brotliCompressor) Decompress(data []byte, maxSize int64) ([]byte, error) {
+	return decompress(brotli.NewReader(bytes.NewReader(data)), maxSize)
+}
+
+// zstdCompressor keeps one encoder per level, since they are expensive to
+// create and safe for concurrent use.  The decoders stream, so the size of
+// the result can be limited, and are pooled since a streaming decoder can
+// only be used by one caller at a time.  The memory and window limits of a
+// decoder are fixed when it is created, so there is a pool for each limit.
+type zstdCompressor struct {
+	mu       sync.Mutex
+	encoders map[int]*zstd.Encoder
+	decoders map[uint64]*sync.Pool
+}
+
+func (z *zstdCompressor) Compress(data []byte, level int) ([]byte, error) {
+	enc, err := z.encoder(level)
+	if err != nil {
+		return nil, err
+	}
+	return enc.EncodeAll(data, nil), nil
+}
+
+func (z *zstdCompressor) Decompress(data []byte, maxSize int64) ([]byte, error) {
+	limit := zstdLimit(maxSize)
+	pool := z.pool(limit)
+
+	dec, _ := pool.Get().(*zstd.Decoder)
+	if dec == nil {
+		opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+		if limit != 0 {
+			window := limit
+			if window < zstd.MinWindowSize {
+				window = zstd.MinWindowSize
+			}
+			if window > zstd.MaxWindowSize {
+				window = zstd.MaxWindowSize
+			}
+			opts = append(opts, zstd.WithDecoderMaxMemory(limit), zstd.WithDecoderMaxWindow(window))
+		}
+
+		var err error
+		if dec, err = zstd.NewReader(nil, opts...); err != nil {
+			return nil, err
+		}
+	}
+
+	// A bytes.Reader is used since the decoder decodes a whole
+	// bytes.Buffer at once.
+	if err := dec.Reset(bytes.NewReader(data)); err != nil {
+		dec.Close()
+		return nil, zstdError(err, maxSize)
+	}
+	out, err := decompress(dec, maxSize)
+	if err != nil {
+		dec.Close()
+		return nil, zstdError(err, maxSize)
+	}
+
+	_ = dec.Reset(nil)
+	pool.Put(dec)
+	return out, nil
+}
+
+// zstdLimit returns the memory limit of the decoders used for maxSize, or 0
+// if the decoders are not limited.  The limit is maxSize rounded up to a
+// power of two, so only a few pools are needed; decompress still enforces
+// maxSize exactly.
+func zstdLimit(maxSize int64) uint64 {
+	if maxSize == math.MaxInt64 {
+		return 0
+	}
+	return 1 << bits.Len64(uint64(maxSize-1))
+}
+
+// zstdError reports the errors of a decoder that reached its limits as
+// ErrTooLarge.
+func zstdError(err error, maxSize int64) error {
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) || errors.Is(err, zstd.ErrWindowSizeExceeded) {
+		return tooLarge(maxSize)
+	}
+	return err
+}
+
+// pool returns the pool of decoders with the limit.
+func (z *zstdCompressor) pool(limit uint64) *sync.Pool {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if z.decoders == nil {
+		z.decoders = make(map[uint64]*sync.Pool)
+	}
+	pool, found := z.decoders[limit]
+	if !found {
+		pool = new(sync.Pool)
+		z.decoders[limit] = pool
+	}
+	return pool
+}
+
+func (z *zstdCompressor) encoder(level int) (*zstd.Encoder, error) {
+	if level < 0 || level > zstdMaxLevel {
+		return nil, fmt.Errorf("%w: zstd level %d", webhook.ErrInvalidInput, level)
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	if enc, found := z.encoders[level]; found {
+		return enc, nil
+	}
+
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if level != 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if z.encoders == nil {
+		z.encoders = make(map[int]*zstd.Encoder)
+	}
+	z.encoders[level] = enc
+	return enc, nil
+}
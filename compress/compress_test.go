@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	webhook "github.com/xmidt-org/webhook-schema"
+)
+
+func TestCompressorsRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"msg_type":4,"source":"mac:112233445566"}`), 100)
+	tests := []struct {
+		encoding string
+		levels   []int
+	}{
+		{encoding: webhook.EncodingIdentity, levels: []int{0}},
+		{encoding: webhook.EncodingGzip, levels: []int{0, 1, 9}},
+		{encoding: webhook.EncodingDeflate, levels: []int{0, 1, 9}},
+		{encoding: webhook.EncodingBrotli, levels: []int{0, 1, 11}},
+		{encoding: webhook.EncodingZstd, levels: []int{0, 1, 3, 22}},
+	}
+	c := DefaultCompressors()
+	for _, tc := range tests {
+		for _, level := range tc.levels {
+			t.Run(fmt.Sprintf("%s-%d", tc.encoding, level), func(t *testing.T) {
+				compressed, err := c.Compress(tc.encoding, data, level)
+				require.NoError(t, err)
+				if tc.encoding != webhook.EncodingIdentity {
+					assert.Less(t, len(compressed), len(data))
+				}
+
+				got, err := c.Decompress(tc.encoding, compressed, int64(len(data)))
+				require.NoError(t, err)
+				assert.Equal(t, data, got)
+			})
+		}
+	}
+}
+
+func TestCompressorsEmpty(t *testing.T) {
+	c := DefaultCompressors()
+	for encoding := range c {
+		compressed, err := c.Compress(encoding, nil, 0)
+		require.NoError(t, err, encoding)
+		got, err := c.Decompress(encoding, compressed, 1)
+		require.NoError(t, err, encoding)
+		assert.Empty(t, got, encoding)
+	}
+}
+
+func TestCompressorsErrors(t *testing.T) {
+	c := DefaultCompressors()
+
+	_, err := c.Compress("lz4", []byte("x"), 0)
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+	_, err = c.Decompress("lz4", []byte("x"), 1)
+	assert.ErrorIs(t, err, ErrUnsupportedEncoding)
+
+	for _, tc := range []struct {
+		encoding string
+		level    int
+	}{
+		{encoding: webhook.EncodingGzip, level: 10},
+		{encoding: webhook.EncodingDeflate, level: 10},
+		{encoding: webhook.EncodingBrotli, level: 12},
+		{encoding: webhook.EncodingZstd, level: 23},
+		{encoding: webhook.EncodingZstd, level: -1},
+	} {
+		_, err = c.Compress(tc.encoding, []byte("x"), tc.level)
+		assert.Error(t, err, tc.encoding)
+	}
+
+	for _, encoding := range []string{webhook.EncodingGzip, webhook.EncodingDeflate, webhook.EncodingZstd} {
+		_, err = c.Decompress(encoding, []byte("not compressed"), math.MaxInt64)
+		assert.Error(t, err, encoding)
+	}
+}
+
+func TestCompressorsTooLarge(t *testing.T) {
+	// A small body that decompresses to far more than the limit.
+	data := bytes.Repeat([]byte{0}, 10<<20)
+	c := DefaultCompressors()
+	for encoding := range c {
+		t.Run(encoding, func(t *testing.T) {
+			assert := assert.New(t)
+
+			compressed, err := c.Compress(encoding, data, 0)
+			require.NoError(t, err)
+
+			_, err = c.Decompress(encoding, compressed, 1<<20)
+			assert.ErrorIs(err, ErrTooLarge)
+
+			got, err := c.Decompress(encoding, compressed, int64(len(data)))
+			assert.NoError(err)
+			assert.Len(got, len(data))
+
+			for _, maxSize := range []int64{0, -1} {
+				_, err = c.Decompress(encoding, compressed, maxSize)
+				assert.ErrorIs(err, webhook.ErrInvalidInput)
+			}
+		})
+	}
+}
+
+func TestZstdCompressorConcurrent(t *testing.T) {
+	c := DefaultCompressors()
+	data := []byte("concurrent zstd data")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(level int) {
+			defer wg.Done()
+			compressed, err := c.Compress(webhook.EncodingZstd, data, level)
+			if assert.NoError(t, err) {
+				got, err := c.Decompress(webhook.EncodingZstd, compressed, math.MaxInt64)
+				assert.NoError(t, err)
+				assert.Equal(t, data, got)
+			}
+		}(i % 3)
+	}
+	wg.Wait()
+}
+
+func TestZstdLimit(t *testing.T) {
+	assert.Equal(t, uint64(1), zstdLimit(1))
+	assert.Equal(t, uint64(1024), zstdLimit(1000))
+	assert.Equal(t, uint64(1024), zstdLimit(1024))
+	assert.Equal(t, uint64(2048), zstdLimit(1025))
+	assert.Equal(t, uint64(1<<63), zstdLimit(math.MaxInt64-1))
+	assert.Equal(t, uint64(0), zstdLimit(math.MaxInt64))
+
+	// The decoders are pooled by their limit.
+	z := &zstdCompressor{}
+	assert.Same(t, z.pool(zstdLimit(1000)), z.pool(zstdLimit(1024)))
+	assert.NotSame(t, z.pool(zstdLimit(1000)), z.pool(zstdLimit(1025)))
+}
@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"strings"
+	"time"
+)
+
+// ConversionIssue describes a RegistrationV1 value that could not be carried
+// over to a RegistrationV2 without changing its meaning.
+type ConversionIssue struct {
+	// Field is the json name of the RegistrationV1 field the issue is about.
+	Field string `json:"field"`
+
+	// Reason describes what was lost or changed.
+	Reason string `json:"reason"`
+}
+
+func (ci ConversionIssue) String() string {
+	return ci.Field + ": " + ci.Reason
+}
+
+// ToV2 converts the RegistrationV1 into the equivalent RegistrationV2.  The
+// mapping is:
+//
+//   - Config.ReceiverURL followed by Config.AlternativeURLs become the
+//     ReceiverURLs of a single Webhook.
+//   - Config.ContentType becomes the Webhook Accept value.  A content type
+//     that is not one of the wrp media types a Webhook accepts is reported.
+//   - Config.Secret becomes the Webhook Secret.
+//   - Events become FieldRegex matchers for FieldEvent and Matcher.DeviceID
+//     become FieldRegex matchers for FieldDeviceID.  Matchers for the same
+//     field match if any of them match and matchers for different fields must
+//     all match, which is the same behavior as RegistrationV1.
+//   - Until becomes Expires.  If only Duration is set, Expires is set to the
+//     current time plus Duration.
+//   - Config.ReceiverURL becomes the CanonicalName, since that is what
+//     identifies a RegistrationV1.
+//
+// Anything that could not be carried over unchanged is reported in the
+// returned list of issues.  The current time is provided by the function set
+// with SetNowFunc, or time.Now if none is set.
+func (v1 *RegistrationV1) ToV2() (RegistrationV2, []ConversionIssue) {
+	var issues []ConversionIssue
+
+	v2 := RegistrationV2{
+		CanonicalName: v1.Config.ReceiverURL,
+		Address:       v1.Address,
+		FailureURL:    v1.FailureURL,
+	}
+
+	w := Webhook{
+		Accept: v1.Config.ContentType,
+		Secret: v1.Config.Secret,
+	}
+
+	if v1.Config.ReceiverURL != "" {
+		w.ReceiverURLs = append(w.ReceiverURLs, v1.Config.ReceiverURL)
+	}
+	w.ReceiverURLs = append(w.ReceiverURLs, v1.Config.AlternativeURLs...)
+	v2.Webhooks = []Webhook{w}
+
+	if ct := v1.Config.ContentType; ct != "" && !contains(webhookAcceptTypes, ct) {
+		issues = append(issues, ConversionIssue{
+			Field:  "config.content_type",
+			Reason: "'" + ct + "' is not a wrp media type, RegistrationV2 webhooks only accept: " + strings.Join(webhookAcceptTypes, ", "),
+		})
+	}
+
+	if v1.Config.Secret != "" {
+		issues = append(issues, ConversionIssue{
+			Field:  "config.secret",
+			Reason: "the secret is used with sha1 HMAC in RegistrationV1, RegistrationV2 only supports sha256 and sha512 HMAC",
+		})
+	}
+
+	if len(v1.Events) == 0 {
+		issues = append(issues, ConversionIssue{
+			Field:  "events",
+			Reason: "no events matches nothing in RegistrationV1, but matches every event in RegistrationV2",
+		})
+	}
+	v2.Matcher = v1.fieldRegexes()
+
+	switch {
+	case !v1.Until.IsZero():
+		v2.Expires = v1.Until
+		if v1.Duration != 0 {
+			issues = append(issues, ConversionIssue{
+				Field:  "duration",
+				Reason: "both duration and until are set, only until is used",
+			})
+		}
+	case v1.Duration != 0:
+		nowFunc := time.Now
+		if v1.nowFunc != nil {
+			nowFunc = v1.nowFunc
+		}
+		v2.Expires = CustomTime{Time: nowFunc().Add(time.Duration(v1.Duration))}
+		issues = append(issues, ConversionIssue{
+			Field:  "duration",
+			Reason: "the relative duration is converted to an absolute expiration time",
+		})
+	default:
+		issues = append(issues, ConversionIssue{
+			Field:  "duration",
+			Reason: "neither duration nor until are set, the registration has no expiration",
+		})
+	}
+
+	return v2, issues
+}
+
+// fieldRegexes returns the Events and Matcher.DeviceID values as the
+// equivalent FieldRegex matchers.
+func (v1 *RegistrationV1) fieldRegexes() []FieldRegex {
+	var list []FieldRegex
+	for _, e := range v1.Events {
+		list = append(list, FieldRegex{Field: FieldEvent, Regex: e})
+	}
+	for _, id := range v1.Matcher.DeviceID {
+		list = append(list, FieldRegex{Field: FieldDeviceID, Regex: id})
+	}
+	return list
+}
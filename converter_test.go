@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToV2(t *testing.T) {
+	until := CustomTime{Time: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)}
+
+	tests := []struct {
+		description    string
+		in             RegistrationV1
+		now            func() time.Time
+		expected       RegistrationV2
+		expectedIssues []string
+	}{
+		{
+			description: "full registration with until",
+			in: RegistrationV1{
+				Address: "127.0.0.1",
+				Config: DeliveryConfig{
+					ReceiverURL:     "https://example.com",
+					ContentType:     "application/json",
+					AlternativeURLs: []string{"https://alt1.example.com", "https://alt2.example.com"},
+				},
+				FailureURL: "https://failure.example.com",
+				Events:     []string{"device-status.*", "iot"},
+				Matcher:    MetadataMatcherConfig{DeviceID: []string{"mac:112233445566"}},
+				Until:      until,
+			},
+			expected: RegistrationV2{
+				CanonicalName: "https://example.com",
+				Address:       "127.0.0.1",
+				FailureURL:    "https://failure.example.com",
+				Webhooks: []Webhook{{
+					Accept:       "application/json",
+					ReceiverURLs: []string{"https://example.com", "https://alt1.example.com", "https://alt2.example.com"},
+				}},
+				Matcher: []FieldRegex{
+					{Field: FieldEvent, Regex: "device-status.*"},
+					{Field: FieldEvent, Regex: "iot"},
+					{Field: FieldDeviceID, Regex: "mac:112233445566"},
+				},
+				Expires: until,
+			},
+			expectedIssues: []string{"config.content_type"},
+		}, {
+			description: "wrp content type",
+			in: RegistrationV1{
+				Config: DeliveryConfig{ReceiverURL: "https://example.com", ContentType: MediaTypeWRPMsgpack},
+				Events: []string{".*"},
+				Until:  until,
+			},
+			expected: RegistrationV2{
+				CanonicalName: "https://example.com",
+				Webhooks:      []Webhook{{ReceiverURLs: []string{"https://example.com"}, Accept: MediaTypeWRPMsgpack}},
+				Matcher:       []FieldRegex{{Field: FieldEvent, Regex: ".*"}},
+				Expires:       until,
+			},
+		}, {
+			description: "duration is converted using the now func",
+			in: RegistrationV1{
+				Config:   DeliveryConfig{ReceiverURL: "https://example.com"},
+				Events:   []string{".*"},
+				Duration: CustomDuration(5 * time.Minute),
+			},
+			now: mockNow,
+			expected: RegistrationV2{
+				CanonicalName: "https://example.com",
+				Webhooks:      []Webhook{{ReceiverURLs: []string{"https://example.com"}}},
+				Matcher:       []FieldRegex{{Field: FieldEvent, Regex: ".*"}},
+				Expires:       CustomTime{Time: mockNow().Add(5 * time.Minute)},
+			},
+			expectedIssues: []string{"duration"},
+		}, {
+			description: "secret semantics are reported",
+			in: RegistrationV1{
+				Config: DeliveryConfig{ReceiverURL: "https://example.com", Secret: "foobar"},
+				Events: []string{".*"},
+				Until:  until,
+			},
+			expected: RegistrationV2{
+				CanonicalName: "https://example.com",
+				Webhooks:      []Webhook{{ReceiverURLs: []string{"https://example.com"}, Secret: "foobar"}},
+				Matcher:       []FieldRegex{{Field: FieldEvent, Regex: ".*"}},
+				Expires:       until,
+			},
+			expectedIssues: []string{"config.secret"},
+		}, {
+			description: "both duration and until set",
+			in: RegistrationV1{
+				Config:   DeliveryConfig{ReceiverURL: "https://example.com"},
+				Events:   []string{".*"},
+				Duration: CustomDuration(5 * time.Minute),
+				Until:    until,
+			},
+			expected: RegistrationV2{
+				CanonicalName: "https://example.com",
+				Webhooks:      []Webhook{{ReceiverURLs: []string{"https://example.com"}}},
+				Matcher:       []FieldRegex{{Field: FieldEvent, Regex: ".*"}},
+				Expires:       until,
+			},
+			expectedIssues: []string{"duration"},
+		}, {
+			description: "empty registration",
+			expected: RegistrationV2{
+				Webhooks: []Webhook{{}},
+			},
+			expectedIssues: []string{"events", "duration"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			if tc.now != nil {
+				tc.in.SetNowFunc(tc.now)
+			}
+
+			v2, issues := tc.in.ToV2()
+			assert.Equal(tc.expected, v2)
+
+			var fields []string
+			for _, issue := range issues {
+				assert.NotEmpty(issue.Reason)
+				assert.Contains(issue.String(), issue.Field)
+				fields = append(fields, issue.Field)
+			}
+			assert.Equal(tc.expectedIssues, fields)
+		})
+	}
+}
@@ -5,6 +5,9 @@ package webhook
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -16,17 +19,76 @@ type InvalidDurationError struct {
 
 func (ide *InvalidDurationError) Error() string {
 	var o strings.Builder
-	o.WriteString("duration must be of type int or string (example:'5m'); Invalid value: ")
+	o.WriteString("duration must be of type int or string (example:'5m', '1d' or 'P7D'); Invalid value: ")
 	o.WriteString(ide.Value)
 	return o.String()
 }
 
+// DurationStyle is the way a duration is written.
+type DurationStyle int
+
+const (
+	// DurationStyleGo is the format used by Go's time.Duration, for example
+	// "168h0m0s".
+	DurationStyleGo DurationStyle = iota
+
+	// DurationStyleISO8601 is the ISO 8601 duration format, for example
+	// "P7D".
+	DurationStyleISO8601
+
+	// DurationStyleSeconds is the number of seconds, for example 604800.
+	DurationStyleSeconds
+)
+
+const day = 24 * time.Hour
+
 // CustomDuration is a custom type for time.Duration that allows for
-// unmarshaling from a string or int.  If unmarshaling from a string,
-// the string must be parsable by time.ParseDuration.  If unmarshaling
-// from an int, the int is assumed to be in seconds. Wes
+// unmarshaling from a string or int.  If unmarshaling from a string, the
+// string may be:
+//   - parsable by time.ParseDuration, with the additional units 'd' (24h)
+//     and 'w' (7d), for example '5m' or '1d12h'
+//   - an ISO 8601 duration, for example 'P7D' or 'PT1H30M'.  Years and months
+//     are not supported since their length varies.
+//   - a number of seconds, for example '50'
+//
+// If unmarshaling from an int, the int is assumed to be in seconds.
+//
+// A CustomDuration is always marshaled in the time.Duration format.  Use a
+// StyledDuration to marshal the value in the format it was unmarshaled from.
 type CustomDuration time.Duration
 
+// ParseCustomDuration parses the string the same way a CustomDuration is
+// unmarshaled, and returns the style the string was written in.
+func ParseCustomDuration(s string) (CustomDuration, DurationStyle, error) {
+	var (
+		d     time.Duration
+		style DurationStyle
+		err   error
+	)
+
+	switch {
+	case isSeconds(s):
+		style = DurationStyleSeconds
+		d, err = time.ParseDuration(s + "s")
+	case strings.HasPrefix(strings.TrimLeft(s, "+-"), "P"):
+		style = DurationStyleISO8601
+		d, err = parseISO8601Duration(s)
+	default:
+		style = DurationStyleGo
+		d, err = parseGoDuration(s)
+	}
+
+	if err != nil {
+		return 0, 0, &InvalidDurationError{Value: s}
+	}
+	return CustomDuration(d), style, nil
+}
+
+// Styled returns the duration with the style to write it in.
+func (cd CustomDuration) Styled(style DurationStyle) StyledDuration {
+	return StyledDuration{Duration: cd, Style: style}
+}
+
 func (cd CustomDuration) String() string {
 	return time.Duration(cd).String()
 }
@@ -39,26 +101,324 @@ func (cd CustomDuration) MarshalJSON() ([]byte, error) {
 	return d.Bytes(), nil
 }
 
-func (cd *CustomDuration) UnmarshalJSON(b []byte) (err error) {
-	if b[0] == '"' {
-		var d time.Duration
-		d, err = time.ParseDuration(string(b[1 : len(b)-1]))
-		if err == nil {
-			*cd = CustomDuration(d)
-			return
-		}
+func (cd *CustomDuration) UnmarshalJSON(b []byte) error {
+	d, _, err := unmarshalDurationJSON(b)
+	if err == nil {
+		*cd = d
+	}
+	return err
+}
+
+func (cd CustomDuration) MarshalText() ([]byte, error) {
+	return []byte(cd.String()), nil
+}
+
+func (cd *CustomDuration) UnmarshalText(b []byte) error {
+	d, _, err := ParseCustomDuration(string(b))
+	if err == nil {
+		*cd = d
+	}
+	return err
+}
+
+// MarshalYAML implements the yaml Marshaler interface used by the
+// gopkg.in/yaml packages.
+func (cd CustomDuration) MarshalYAML() (any, error) {
+	return cd.String(), nil
+}
+
+// UnmarshalYAML implements the yaml Unmarshaler interface used by the
+// gopkg.in/yaml packages.
+func (cd *CustomDuration) UnmarshalYAML(unmarshal func(any) error) error {
+	d, _, err := unmarshalDurationYAML(unmarshal)
+	if err == nil {
+		*cd = d
+	}
+	return err
+}
+
+// StyledDuration is a CustomDuration that remembers the style it was
+// unmarshaled from, so it is marshaled the same way the client wrote it.
+// RegistrationV1 remembers the style of its Duration this way.
+type StyledDuration struct {
+	Duration CustomDuration
+	Style    DurationStyle
+}
+
+// String returns the duration written in the style.
+func (sd StyledDuration) String() string {
+	d := time.Duration(sd.Duration)
+	switch sd.Style {
+	case DurationStyleISO8601:
+		return formatISO8601Duration(d)
+	case DurationStyleSeconds:
+		return formatSeconds(d)
 	}
+	return d.String()
+}
+
+func (sd StyledDuration) MarshalJSON() ([]byte, error) {
+	if sd.Style == DurationStyleSeconds {
+		return []byte(sd.String()), nil
+	}
+	return json.Marshal(sd.String())
+}
 
-	var d int64
-	d, err = strconv.ParseInt(string(b), 10, 64)
+func (sd *StyledDuration) UnmarshalJSON(b []byte) error {
+	d, style, err := unmarshalDurationJSON(b)
 	if err == nil {
-		*cd = CustomDuration(time.Duration(d) * time.Second)
-		return
+		*sd = d.Styled(style)
 	}
+	return err
+}
+
+func (sd StyledDuration) MarshalText() ([]byte, error) {
+	return []byte(sd.String()), nil
+}
 
-	err = &InvalidDurationError{
-		Value: string(b),
+func (sd *StyledDuration) UnmarshalText(b []byte) error {
+	d, style, err := ParseCustomDuration(string(b))
+	if err == nil {
+		*sd = d.Styled(style)
 	}
+	return err
+}
 
-	return
+// MarshalYAML implements the yaml Marshaler interface used by the
+// gopkg.in/yaml packages.
+func (sd StyledDuration) MarshalYAML() (any, error) {
+	if sd.Style != DurationStyleSeconds {
+		return sd.String(), nil
+	}
+	if d := time.Duration(sd.Duration); d%time.Second == 0 {
+		return int64(d / time.Second), nil
+	}
+	return time.Duration(sd.Duration).Seconds(), nil
+}
+
+// UnmarshalYAML implements the yaml Unmarshaler interface used by the
+// gopkg.in/yaml packages.
+func (sd *StyledDuration) UnmarshalYAML(unmarshal func(any) error) error {
+	d, style, err := unmarshalDurationYAML(unmarshal)
+	if err == nil {
+		*sd = d.Styled(style)
+	}
+	return err
+}
+
+func unmarshalDurationJSON(b []byte) (CustomDuration, DurationStyle, error) {
+	if len(b) > 0 && b[0] == '"' {
+		var s string
+		if err := json.Unmarshal(b, &s); err == nil {
+			return ParseCustomDuration(s)
+		}
+	} else if isSeconds(string(b)) {
+		return ParseCustomDuration(string(b))
+	}
+
+	return 0, 0, &InvalidDurationError{Value: string(b)}
+}
+
+func unmarshalDurationYAML(unmarshal func(any) error) (CustomDuration, DurationStyle, error) {
+	var v any
+	if err := unmarshal(&v); err != nil {
+		return 0, 0, err
+	}
+
+	switch v := v.(type) {
+	case string:
+		return ParseCustomDuration(v)
+	case int:
+		return ParseCustomDuration(strconv.Itoa(v))
+	case float64:
+		return ParseCustomDuration(strconv.FormatFloat(v, 'f', -1, 64))
+	}
+
+	return 0, 0, &InvalidDurationError{Value: fmt.Sprint(v)}
+}
+
+// isSeconds returns true if the string is a plain decimal number.
+func isSeconds(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	if s == "" || s == "." {
+		return false
+	}
+	return strings.Count(s, ".") <= 1 && strings.Trim(s, "0123456789.") == ""
+}
+
+// parseGoDuration parses the time.ParseDuration format, with the additional
+// units 'd' and 'w'.
+func parseGoDuration(s string) (time.Duration, error) {
+	sign, s := cutSign(s)
+	if s == "0" {
+		return 0, nil
+	}
+
+	d, err := sumComponents(s, func(unit string) (string, time.Duration, bool) {
+		switch unit {
+		case "d":
+			return "h", 24, true
+		case "w":
+			return "h", 7 * 24, true
+		}
+		return unit, 1, true
+	})
+	return sign * d, err
+}
+
+// parseISO8601Duration parses an ISO 8601 duration in the form
+// PnWnDTnHnMnS.  Years and months are not supported.
+func parseISO8601Duration(s string) (time.Duration, error) {
+	sign, s := cutSign(s)
+	s, ok := strings.CutPrefix(s, "P")
+	if !ok {
+		return 0, fmt.Errorf("missing the P designator")
+	}
+
+	date, clock, hasTime := strings.Cut(s, "T")
+	if clock == "" && (date == "" || hasTime) {
+		return 0, fmt.Errorf("missing the duration components")
+	}
+
+	// The components must be in order and may only be used once.
+	ordered := func(units ...string) func(string) (string, time.Duration, bool) {
+		return func(unit string) (string, time.Duration, bool) {
+			for i, u := range units {
+				if u == unit {
+					units = units[i+1:]
+					switch unit {
+					case "W":
+						return "h", 7 * 24, true
+					case "D":
+						return "h", 24, true
+					}
+					return strings.ToLower(unit), 1, true
+				}
+			}
+			return "", 0, false
+		}
+	}
+
+	var d, t time.Duration
+	var err error
+	if date != "" {
+		d, err = sumComponents(strings.ReplaceAll(date, ",", "."), ordered("W", "D"))
+	}
+	if err == nil && clock != "" {
+		t, err = sumComponents(strings.ReplaceAll(clock, ",", "."), ordered("H", "M", "S"))
+	}
+	if err == nil && t > math.MaxInt64-d {
+		err = fmt.Errorf("duration out of range")
+	}
+
+	return sign * (d + t), err
+}
+
+// cutSign removes the leading sign from the string.
+func cutSign(s string) (time.Duration, string) {
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		return -1, rest
+	}
+	return 1, strings.TrimPrefix(s, "+")
+}
+
+// sumComponents adds up the unsigned <number><unit> components of the string.
+// The units function returns the time.ParseDuration unit and the multiplier
+// for each unit, or false if the unit is not allowed.
+func sumComponents(s string, units func(string) (string, time.Duration, bool)) (time.Duration, error) {
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	var total time.Duration
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool {
+			return r != '.' && (r < '0' || '9' < r)
+		})
+		if i <= 0 {
+			return 0, fmt.Errorf("missing the number or unit")
+		}
+		j := strings.IndexFunc(s[i:], func(r rune) bool {
+			return r == '.' || '0' <= r && r <= '9'
+		})
+		if j < 0 {
+			j = len(s) - i
+		}
+
+		number, unit := s[:i], s[i:i+j]
+		s = s[i+j:]
+
+		goUnit, scale, ok := units(unit)
+		if !ok {
+			return 0, fmt.Errorf("unexpected unit %q", unit)
+		}
+		d, err := time.ParseDuration(number + goUnit)
+		if err != nil {
+			return 0, err
+		}
+		if d > math.MaxInt64/scale || d*scale > math.MaxInt64-total {
+			return 0, fmt.Errorf("duration out of range")
+		}
+		total += d * scale
+	}
+
+	return total, nil
+}
+
+// formatISO8601Duration writes the duration in the ISO 8601 format, using
+// days as the largest unit.
+func formatISO8601Duration(d time.Duration) string {
+	if d == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	u := uint64(d)
+	if d < 0 {
+		b.WriteByte('-')
+		u = -u
+	}
+
+	b.WriteByte('P')
+	if days := u / uint64(day); days > 0 {
+		b.WriteString(strconv.FormatUint(days, 10))
+		b.WriteByte('D')
+	}
+
+	u %= uint64(day)
+	if u == 0 {
+		return b.String()
+	}
+
+	b.WriteByte('T')
+	if hours := u / uint64(time.Hour); hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10))
+		b.WriteByte('H')
+	}
+	if minutes := u % uint64(time.Hour) / uint64(time.Minute); minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10))
+		b.WriteByte('M')
+	}
+	if seconds := u % uint64(time.Minute); seconds > 0 {
+		b.WriteString(formatSeconds(time.Duration(seconds)))
+		b.WriteByte('S')
+	}
+
+	return b.String()
+}
+
+// formatSeconds writes the duration as a decimal number of seconds.
+func formatSeconds(d time.Duration) string {
+	var sign string
+	u := uint64(d)
+	if d < 0 {
+		sign = "-"
+		u = -u
+	}
+
+	s := sign + strconv.FormatUint(u/uint64(time.Second), 10)
+	if frac := u % uint64(time.Second); frac != 0 {
+		s += strings.TrimRight(fmt.Sprintf(".%09d", frac), "0")
+	}
+	return s
 }
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 func TestUnmarshalJSON(t *testing.T) {
@@ -36,6 +38,21 @@ func TestUnmarshalJSON(t *testing.T) {
 			input:       []byte(`{"duration":"2r"}`),
 			errExpected: true,
 		},
+		{
+			description:      "Day success",
+			input:            []byte(`{"duration":"1d"}`),
+			expectedDuration: CustomDuration(24 * time.Hour),
+		},
+		{
+			description:      "ISO 8601 success",
+			input:            []byte(`{"duration":"P7D"}`),
+			expectedDuration: CustomDuration(7 * 24 * time.Hour),
+		},
+		{
+			description: "ISO 8601 failure",
+			input:       []byte(`{"duration":"P1M"}`),
+			errExpected: true,
+		},
 		{
 			description: "Object failure",
 			input:       []byte(`{"duration":{"key":"val"}}`),
@@ -92,3 +109,196 @@ func TestMarshalJSON(t *testing.T) {
 	}
 
 }
+
+func TestParseCustomDuration(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		expected    time.Duration
+		style       DurationStyle
+		errExpected bool
+	}{
+		{description: "go style", input: "5m", expected: 5 * time.Minute},
+		{description: "go style zero", input: "0", expected: 0, style: DurationStyleSeconds},
+		{description: "go style with days", input: "1d12h", expected: 36 * time.Hour},
+		{description: "go style with weeks", input: "2w", expected: 14 * 24 * time.Hour},
+		{description: "go style fractional days", input: "1.5d", expected: 36 * time.Hour},
+		{description: "go style negative", input: "-1d", expected: -24 * time.Hour},
+		{description: "go style sub second", input: "1s500ms", expected: 1500 * time.Millisecond},
+		{description: "seconds", input: "50", expected: 50 * time.Second, style: DurationStyleSeconds},
+		{description: "fractional seconds", input: "1.5", expected: 1500 * time.Millisecond, style: DurationStyleSeconds},
+		{description: "negative seconds", input: "-10", expected: -10 * time.Second, style: DurationStyleSeconds},
+		{description: "iso days", input: "P7D", expected: 7 * 24 * time.Hour, style: DurationStyleISO8601},
+		{description: "iso weeks", input: "P1W", expected: 7 * 24 * time.Hour, style: DurationStyleISO8601},
+		{description: "iso time", input: "PT1H30M", expected: 90 * time.Minute, style: DurationStyleISO8601},
+		{description: "iso everything", input: "P1W1DT1H1M1.5S", expected: 8*24*time.Hour + time.Hour + time.Minute + 1500*time.Millisecond, style: DurationStyleISO8601},
+		{description: "iso comma decimal", input: "PT0,5S", expected: 500 * time.Millisecond, style: DurationStyleISO8601},
+		{description: "iso fractional hours", input: "PT0.5H", expected: 30 * time.Minute, style: DurationStyleISO8601},
+		{description: "iso negative", input: "-PT5M", expected: -5 * time.Minute, style: DurationStyleISO8601},
+		{description: "iso zero", input: "PT0S", expected: 0, style: DurationStyleISO8601},
+		{description: "empty", input: "", errExpected: true},
+		{description: "unknown unit", input: "2r", errExpected: true},
+		{description: "missing number", input: "d", errExpected: true},
+		{description: "missing unit", input: "5m3", errExpected: true},
+		{description: "embedded sign", input: "5m-3s", errExpected: true},
+		{description: "double decimal", input: "1.2.3", errExpected: true},
+		{description: "go style overflow", input: "100000000w", errExpected: true},
+		{description: "iso only P", input: "P", errExpected: true},
+		{description: "iso empty time", input: "P1DT", errExpected: true},
+		{description: "iso years", input: "P1Y", errExpected: true},
+		{description: "iso months", input: "P1M", errExpected: true},
+		{description: "iso out of order", input: "PT1S1M", errExpected: true},
+		{description: "iso repeated", input: "P1D1D", errExpected: true},
+		{description: "iso time unit in date", input: "P1H", errExpected: true},
+		{description: "iso embedded sign", input: "P-1D", errExpected: true},
+		{description: "iso lower case", input: "p1d", errExpected: true},
+		{description: "iso overflow", input: "P1000000W", errExpected: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			d, style, err := ParseCustomDuration(tc.input)
+			if tc.errExpected {
+				var ide *InvalidDurationError
+				assert.ErrorAs(err, &ide)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(CustomDuration(tc.expected), d)
+			assert.Equal(tc.style, style)
+		})
+	}
+}
+
+func TestStyledDurationString(t *testing.T) {
+	tests := []struct {
+		input    time.Duration
+		style    DurationStyle
+		expected string
+	}{
+		{input: 7 * 24 * time.Hour, style: DurationStyleGo, expected: "168h0m0s"},
+		{input: 7 * 24 * time.Hour, style: DurationStyleISO8601, expected: "P7D"},
+		{input: 7 * 24 * time.Hour, style: DurationStyleSeconds, expected: "604800"},
+		{input: 0, style: DurationStyleISO8601, expected: "PT0S"},
+		{input: 0, style: DurationStyleSeconds, expected: "0"},
+		{input: 90 * time.Minute, style: DurationStyleISO8601, expected: "PT1H30M"},
+		{input: 25*time.Hour + 1500*time.Millisecond, style: DurationStyleISO8601, expected: "P1DT1H1.5S"},
+		{input: -5 * time.Minute, style: DurationStyleISO8601, expected: "-PT5M"},
+		{input: 1500 * time.Millisecond, style: DurationStyleSeconds, expected: "1.5"},
+		{input: -time.Nanosecond, style: DurationStyleSeconds, expected: "-0.000000001"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expected, func(t *testing.T) {
+			sd := CustomDuration(tc.input).Styled(tc.style)
+			assert.Equal(t, tc.expected, sd.String())
+
+			// the output can always be parsed back in the same style
+			d, style, err := ParseCustomDuration(sd.String())
+			assert.NoError(t, err)
+			assert.Equal(t, sd, d.Styled(style))
+		})
+	}
+}
+
+func TestStyledDurationRoundTrip(t *testing.T) {
+	type test struct {
+		Duration StyledDuration `json:"duration" yaml:"duration"`
+	}
+	tests := []struct {
+		description string
+		json        string
+		yaml        string
+		expected    time.Duration
+	}{
+		{description: "go style", json: `{"duration":"5m0s"}`, yaml: "duration: 5m0s\n", expected: 5 * time.Minute},
+		{description: "days", json: `{"duration":"1d"}`, expected: 24 * time.Hour},
+		{description: "iso", json: `{"duration":"P7D"}`, yaml: "duration: P7D\n", expected: 7 * 24 * time.Hour},
+		{description: "seconds", json: `{"duration":50}`, yaml: "duration: 50\n", expected: 50 * time.Second},
+		{description: "fractional seconds", json: `{"duration":1.5}`, yaml: "duration: 1.5\n", expected: 1500 * time.Millisecond},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var j test
+			require.NoError(json.Unmarshal([]byte(tc.json), &j))
+			assert.Equal(CustomDuration(tc.expected), j.Duration.Duration)
+
+			b, err := json.Marshal(j)
+			require.NoError(err)
+			if j.Duration.Style != DurationStyleGo {
+				// Go style is normalized, so "1d" is written as "24h0m0s"
+				assert.Equal(tc.json, string(b))
+			}
+
+			if tc.yaml == "" {
+				return
+			}
+			var y test
+			require.NoError(yaml.Unmarshal([]byte(tc.yaml), &y))
+			assert.Equal(j, y)
+
+			b, err = yaml.Marshal(y)
+			require.NoError(err)
+			assert.Equal(tc.yaml, string(b))
+		})
+	}
+}
+
+func TestCustomDurationText(t *testing.T) {
+	assert := assert.New(t)
+
+	var cd CustomDuration
+	assert.NoError(cd.UnmarshalText([]byte("P1D")))
+	assert.Equal(CustomDuration(24*time.Hour), cd)
+
+	b, err := cd.MarshalText()
+	assert.NoError(err)
+	assert.Equal("24h0m0s", string(b))
+
+	assert.Error(cd.UnmarshalText([]byte("P1Y")))
+	assert.Equal(CustomDuration(24*time.Hour), cd, "the value is not changed on error")
+
+	var sd StyledDuration
+	assert.NoError(sd.UnmarshalText([]byte("PT90M")))
+	b, err = sd.MarshalText()
+	assert.NoError(err)
+	assert.Equal("PT1H30M", string(b))
+}
+
+func TestCustomDurationYAML(t *testing.T) {
+	type test struct {
+		Duration CustomDuration `yaml:"duration"`
+	}
+	tests := []struct {
+		description string
+		input       string
+		expected    time.Duration
+		errExpected bool
+	}{
+		{description: "int", input: "duration: 50", expected: 50 * time.Second},
+		{description: "go style", input: "duration: 5m", expected: 5 * time.Minute},
+		{description: "days", input: "duration: 1d", expected: 24 * time.Hour},
+		{description: "iso", input: "duration: P7D", expected: 7 * 24 * time.Hour},
+		{description: "invalid", input: "duration: 2r", errExpected: true},
+		{description: "wrong type", input: "duration: [1]", errExpected: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var v test
+			err := yaml.Unmarshal([]byte(tc.input), &v)
+			if tc.errExpected {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(CustomDuration(tc.expected), v.Duration)
+
+			b, err := yaml.Marshal(v)
+			assert.NoError(err)
+			assert.Equal("duration: "+time.Duration(tc.expected).String()+"\n", string(b))
+		})
+	}
+}
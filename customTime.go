@@ -0,0 +1,241 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// epochMillisThreshold is the smallest Unix epoch value that is treated as
+// milliseconds instead of seconds.  As seconds it is in the year 5138, as
+// milliseconds it is in 1973.
+const epochMillisThreshold = 100_000_000_000
+
+type InvalidTimeError struct {
+	Value string
+	Err   error
+}
+
+func (ite *InvalidTimeError) Error() string {
+	var o strings.Builder
+	o.WriteString("time must be an RFC 3339 string (example:'2023-01-02T15:04:05Z'), ")
+	o.WriteString("an RFC 1123 string (example:'Mon, 02 Jan 2023 15:04:05 GMT'), ")
+	o.WriteString("Unix epoch seconds or milliseconds, ")
+	o.WriteString("or a duration after the current time (example:'+24h'); Invalid value: ")
+	o.WriteString(ite.Value)
+	if ite.Err != nil {
+		o.WriteString(": ")
+		o.WriteString(ite.Err.Error())
+	}
+	return o.String()
+}
+
+func (ite *InvalidTimeError) Unwrap() error {
+	return ite.Err
+}
+
+// CustomTime is a custom type for time.Time that allows for unmarshaling
+// from a string or int.  If unmarshaling from a string, the string may be:
+//   - an RFC 3339 time, for example '2023-01-02T15:04:05Z'
+//   - an RFC 1123 time in GMT or UTC, for example
+//     'Mon, 02 Jan 2023 15:04:05 GMT'
+//   - an RFC 1123 time with a numeric zone, for example
+//     'Mon, 02 Jan 2023 10:04:05 -0500'
+//   - a Unix epoch, for example '1672671845'
+//   - '+' followed by an unsigned CustomDuration, for example '+24h' or
+//     '+P1D', which is relative to the current time
+//   - empty, which is the zero time
+//
+// If unmarshaling from an int, the int is a Unix epoch.  Epoch values of
+// 100000000000 or larger are in milliseconds, smaller values are in seconds.
+//
+// The unmarshaled time is always in UTC.  A CustomTime is marshaled as an
+// RFC 3339 string.
+//
+// A relative time is unmarshaled against time.Now, and is resolved again
+// against the registration clock when the Until or Expires field is part of
+// a registration that is given a clock with SetNowFunc or ProvideTimeNowFunc.
+//
+// A CustomTime also remembers whether it was written as a relative time, so
+// two CustomTimes for the same instant are not always ==.  Compare them with
+// Equal instead.
+type CustomTime struct {
+	time.Time
+
+	// relative is set if the time was written relative to the current time,
+	// in which case offset is the duration that was written.
+	relative bool
+	offset   time.Duration
+}
+
+// ParseCustomTime parses the string the same way a CustomTime is
+// unmarshaled.  Relative times are added to now.
+func ParseCustomTime(s string, now time.Time) (CustomTime, error) {
+	ct, err := parseTime(s, now)
+	if err != nil {
+		return CustomTime{}, &InvalidTimeError{Value: s, Err: err}
+	}
+	ct.Time = ct.Time.UTC()
+	return ct, nil
+}
+
+func parseTime(s string, now time.Time) (CustomTime, error) {
+	if s == "" {
+		return CustomTime{}, nil
+	}
+
+	if rel, ok := strings.CutPrefix(s, "+"); ok {
+		if strings.HasPrefix(rel, "+") || strings.HasPrefix(rel, "-") {
+			return CustomTime{}, fmt.Errorf("the relative duration %q must not have a sign", rel)
+		}
+		d, _, err := ParseCustomDuration(rel)
+		if err != nil {
+			return CustomTime{}, fmt.Errorf("invalid relative duration %q", rel)
+		}
+		return CustomTime{
+			Time:     now.Add(time.Duration(d)),
+			relative: true,
+			offset:   time.Duration(d),
+		}, nil
+	}
+
+	if isEpoch(s) {
+		t, err := parseEpoch(s)
+		return CustomTime{Time: t}, err
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, time.RFC1123Z} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return CustomTime{Time: t}, nil
+		}
+	}
+
+	if t, err := time.Parse(time.RFC1123, s); err == nil {
+		// time.Parse reads any zone abbreviation it does not know as UTC, so
+		// only the ones that really are UTC are accepted.
+		if zone := s[strings.LastIndex(s, " ")+1:]; zone != "GMT" && zone != "UTC" {
+			return CustomTime{}, fmt.Errorf("the RFC 1123 time zone %q must be GMT or UTC, use RFC 1123Z for a numeric offset", zone)
+		}
+		return CustomTime{Time: t}, nil
+	}
+
+	return CustomTime{}, fmt.Errorf("unknown format")
+}
+
+// Relative returns the duration after the current time the CustomTime was
+// written as, for example 24h for '+24h'.  The bool is false if the
+// CustomTime is not a relative time that has yet to be resolved.
+func (ct CustomTime) Relative() (time.Duration, bool) {
+	return ct.offset, ct.relative
+}
+
+// resolve sets a relative time to its duration after now.  The time is only
+// resolved once.
+func (ct *CustomTime) resolve(now time.Time) {
+	if ct.relative {
+		*ct = CustomTime{Time: now.Add(ct.offset).UTC()}
+	}
+}
+
+// isEpoch returns true if the string is a plain integer.
+func isEpoch(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func parseEpoch(s string) (time.Time, error) {
+	epoch, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("epoch out of range")
+	}
+
+	if epoch >= epochMillisThreshold || epoch <= -epochMillisThreshold {
+		return time.UnixMilli(epoch), nil
+	}
+	return time.Unix(epoch, 0), nil
+}
+
+func (ct CustomTime) MarshalJSON() ([]byte, error) {
+	return ct.Time.UTC().MarshalJSON()
+}
+
+func (ct *CustomTime) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+
+	if len(b) > 0 && b[0] == '"' {
+		if err := json.Unmarshal(b, &s); err != nil {
+			return &InvalidTimeError{Value: string(b)}
+		}
+	} else if !isEpoch(s) {
+		return &InvalidTimeError{Value: string(b)}
+	}
+
+	t, err := ParseCustomTime(s, time.Now())
+	if err == nil {
+		*ct = t
+	}
+	return err
+}
+
+func (ct CustomTime) MarshalText() ([]byte, error) {
+	return ct.Time.UTC().MarshalText()
+}
+
+func (ct *CustomTime) UnmarshalText(b []byte) error {
+	t, err := ParseCustomTime(string(b), time.Now())
+	if err == nil {
+		*ct = t
+	}
+	return err
+}
+
+// MarshalYAML implements the yaml Marshaler interface used by the
+// gopkg.in/yaml packages.
+func (ct CustomTime) MarshalYAML() (any, error) {
+	return ct.Time.UTC().Format(time.RFC3339Nano), nil
+}
+
+// UnmarshalYAML implements the yaml Unmarshaler interface used by the
+// gopkg.in/yaml packages.
+func (ct *CustomTime) UnmarshalYAML(unmarshal func(any) error) error {
+	var v any
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		// yaml resolves unquoted timestamps itself.
+		*ct = CustomTime{Time: v.UTC()}
+		return nil
+	case string:
+		s = v
+	case int:
+		s = strconv.Itoa(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return &InvalidTimeError{Value: fmt.Sprint(v)}
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return &InvalidTimeError{Value: fmt.Sprint(v)}
+	}
+
+	t, err := ParseCustomTime(s, time.Now())
+	if err == nil {
+		*ct = t
+	}
+	return err
+}
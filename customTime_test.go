@@ -0,0 +1,210 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestParseCustomTime(t *testing.T) {
+	now := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	expected := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		input       string
+		expected    time.Time
+		errExpected bool
+	}{
+		{description: "empty", input: ""},
+		{description: "rfc 3339", input: "2023-01-02T15:04:05Z", expected: expected},
+		{description: "rfc 3339 offset", input: "2023-01-02T10:04:05-05:00", expected: expected},
+		{description: "rfc 3339 nano", input: "2023-01-02T15:04:05.5Z", expected: expected.Add(500 * time.Millisecond)},
+		{description: "rfc 1123", input: "Mon, 02 Jan 2023 15:04:05 GMT", expected: expected},
+		{description: "rfc 1123 utc", input: "Mon, 02 Jan 2023 15:04:05 UTC", expected: expected},
+		{description: "rfc 1123 numeric zone", input: "Mon, 02 Jan 2023 10:04:05 -0500", expected: expected},
+		{description: "rfc 1123 zone abbreviation", input: "Mon, 02 Jan 2023 10:04:05 EST", errExpected: true},
+		{description: "rfc 1123 unknown zone", input: "Mon, 02 Jan 2023 10:04:05 XYZ", errExpected: true},
+		{description: "epoch seconds", input: "1672671845", expected: expected},
+		{description: "epoch milliseconds", input: "1672671845500", expected: expected.Add(500 * time.Millisecond)},
+		{description: "epoch zero", input: "0", expected: time.Unix(0, 0).UTC()},
+		{description: "negative epoch", input: "-86400", expected: time.Date(1969, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{description: "relative", input: "+24h", expected: now.Add(24 * time.Hour)},
+		{description: "relative days", input: "+1d", expected: now.Add(24 * time.Hour)},
+		{description: "relative iso", input: "+P1DT15H4M5S", expected: expected},
+		{description: "relative invalid", input: "+2r", errExpected: true},
+		{description: "relative empty", input: "+", errExpected: true},
+		{description: "relative negative", input: "+-5m", errExpected: true},
+		{description: "relative negative iso", input: "+-P1D", errExpected: true},
+		{description: "relative double plus", input: "++5m", errExpected: true},
+		{description: "epoch out of range", input: "99999999999999999999", errExpected: true},
+		{description: "date only", input: "2023-01-02", errExpected: true},
+		{description: "garbage", input: "tomorrow", errExpected: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			ct, err := ParseCustomTime(tc.input, now)
+			if tc.errExpected {
+				var ite *InvalidTimeError
+				assert.ErrorAs(err, &ite)
+				assert.Contains(err.Error(), "RFC 3339")
+				assert.Contains(err.Error(), tc.input)
+				return
+			}
+			assert.NoError(err)
+			assert.True(tc.expected.Equal(ct.Time), "%v != %v", tc.expected, ct.Time)
+			assert.Equal(time.UTC, ct.Location())
+		})
+	}
+}
+
+func TestCustomTimeJSON(t *testing.T) {
+	type test struct {
+		Expires CustomTime `json:"expires"`
+	}
+	expected := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		input       string
+		expected    time.Time
+		errExpected bool
+	}{
+		{description: "rfc 3339", input: `{"expires":"2023-01-02T10:04:05-05:00"}`, expected: expected},
+		{description: "rfc 1123", input: `{"expires":"Mon, 02 Jan 2023 15:04:05 GMT"}`, expected: expected},
+		{description: "epoch seconds", input: `{"expires":1672671845}`, expected: expected},
+		{description: "epoch milliseconds", input: `{"expires":1672671845000}`, expected: expected},
+		{description: "epoch string", input: `{"expires":"1672671845"}`, expected: expected},
+		{description: "null", input: `{"expires":null}`},
+		{description: "missing", input: `{}`},
+		{description: "fractional epoch", input: `{"expires":1672671845.5}`, errExpected: true},
+		{description: "invalid", input: `{"expires":"yesterday"}`, errExpected: true},
+		{description: "object", input: `{"expires":{}}`, errExpected: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var v test
+			err := json.Unmarshal([]byte(tc.input), &v)
+			if tc.errExpected {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.True(tc.expected.Equal(v.Expires.Time))
+
+			// the output is always rfc 3339 in utc
+			b, err := json.Marshal(v)
+			assert.NoError(err)
+			if !tc.expected.IsZero() {
+				assert.Equal(`{"expires":"2023-01-02T15:04:05Z"}`, string(b))
+			}
+		})
+	}
+}
+
+func TestCustomTimeRelative(t *testing.T) {
+	var v RegistrationV2
+	require.NoError(t, json.Unmarshal([]byte(`{"expires":"+24h"}`), &v))
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), v.Expires.Time, time.Minute)
+	assert.Equal(t, time.UTC, v.Expires.Location())
+}
+
+func TestCustomTimeRelativeResolved(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var v1 RegistrationV1
+	var v2 RegistrationV2
+	require.NoError(json.Unmarshal([]byte(`{"until":"+1h"}`), &v1))
+	require.NoError(json.Unmarshal([]byte(`{"expires":"+P1D"}`), &v2))
+
+	d, ok := v2.Expires.Relative()
+	assert.True(ok)
+	assert.Equal(24*time.Hour, d)
+
+	v1.SetNowFunc(mockNow)
+	v2.SetNowFunc(mockNow)
+	assert.Equal(mockNow().Add(time.Hour), v1.Until.Time)
+	assert.Equal(mockNow().Add(24*time.Hour), v2.Expires.Time)
+
+	// A resolved time is not moved by a later clock.
+	_, ok = v2.Expires.Relative()
+	assert.False(ok)
+	v2.SetNowFunc(func() time.Time { return mockNow().Add(time.Hour) })
+	assert.Equal(mockNow().Add(24*time.Hour), v2.Expires.Time)
+
+	// Absolute times are not changed.
+	ct, err := ParseCustomTime("2023-01-02T15:04:05Z", mockNow())
+	require.NoError(err)
+	_, ok = ct.Relative()
+	assert.False(ok)
+}
+
+func TestCustomTimeMarshalLocal(t *testing.T) {
+	loc := time.FixedZone("test", -5*60*60)
+	ct := CustomTime{Time: time.Date(2023, 1, 2, 10, 4, 5, 0, loc)}
+
+	b, err := json.Marshal(ct)
+	require.NoError(t, err)
+	assert.Equal(t, `"2023-01-02T15:04:05Z"`, string(b))
+
+	b, err = ct.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "2023-01-02T15:04:05Z", string(b))
+}
+
+func TestCustomTimeText(t *testing.T) {
+	var ct CustomTime
+	require.NoError(t, ct.UnmarshalText([]byte("1672671845")))
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), ct.Time)
+
+	assert.Error(t, ct.UnmarshalText([]byte("soon")))
+	assert.Equal(t, time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC), ct.Time, "the value is not changed on error")
+}
+
+func TestCustomTimeYAML(t *testing.T) {
+	type test struct {
+		Until CustomTime `yaml:"until"`
+	}
+	expected := time.Date(2023, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		description string
+		input       string
+		errExpected bool
+	}{
+		{description: "timestamp", input: "until: 2023-01-02T10:04:05-05:00"},
+		{description: "quoted", input: `until: "2023-01-02T15:04:05Z"`},
+		{description: "rfc 1123", input: "until: Mon, 02 Jan 2023 15:04:05 GMT"},
+		{description: "epoch", input: "until: 1672671845"},
+		{description: "invalid", input: "until: yesterday", errExpected: true},
+		{description: "fractional epoch", input: "until: 1672671845.5", errExpected: true},
+		{description: "wrong type", input: "until: [1]", errExpected: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			var v test
+			err := yaml.Unmarshal([]byte(tc.input), &v)
+			if tc.errExpected {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(expected, v.Until.Time)
+
+			b, err := yaml.Marshal(v)
+			assert.NoError(err)
+			assert.Equal("until: \"2023-01-02T15:04:05Z\"\n", string(b))
+		})
+	}
+}
@@ -0,0 +1,123 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Registration is implemented by every version of a registration.
+type Registration interface {
+	// Version returns the version of the registration schema, either 1 or 2.
+	Version() int
+}
+
+// Version returns 1.
+func (v1 *RegistrationV1) Version() int {
+	return 1
+}
+
+// Version returns 2.
+func (v2 *RegistrationV2) Version() int {
+	return 2
+}
+
+var (
+	// v1Fields are the json fields that only a RegistrationV1 has.
+	v1Fields = []string{"config", "events", "duration", "until"}
+
+	// v2Fields are the json fields that only a RegistrationV2 has.
+	v2Fields = []string{"contact_info", "canonical_name", "webhooks", "kafkas", "hash", "batch_hints", "expires"}
+)
+
+// DecodeRegistration inspects the json document to determine which version of
+// registration it contains and unmarshals it into that version.  The returned
+// Registration is either a *RegistrationV1 or a *RegistrationV2.
+//
+// A document is a RegistrationV1 if it has any of the `config`, `events`,
+// `duration` or `until` fields and a RegistrationV2 if it has any of the
+// `contact_info`, `canonical_name`, `webhooks`, `kafkas`, `hash`,
+// `batch_hints` or `expires` fields.  If only fields shared by both versions
+// are present, the shape of the `matcher` field decides.  Documents with fields
+// from both versions, or with nothing to tell the versions apart, are rejected.
+func DecodeRegistration(b []byte) (Registration, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, fmt.Errorf("%w: registration must be a json object: %w", ErrInvalidInput, err)
+	}
+	if fields == nil {
+		return nil, fmt.Errorf("%w: registration must be a json object", ErrInvalidInput)
+	}
+
+	v1 := present(fields, v1Fields)
+	v2 := present(fields, v2Fields)
+
+	var r Registration
+	switch {
+	case len(v1) > 0 && len(v2) > 0:
+		return nil, fmt.Errorf("%w: registration mixes RegistrationV1 fields [%s] with RegistrationV2 fields [%s]",
+			ErrInvalidInput, strings.Join(v1, ", "), strings.Join(v2, ", "))
+	case len(v1) > 0:
+		r = &RegistrationV1{}
+	case len(v2) > 0:
+		r = &RegistrationV2{}
+	default:
+		raw, _ := jsonField(fields, "matcher")
+		switch matcherShape(raw) {
+		case '{':
+			r = &RegistrationV1{}
+		case '[':
+			r = &RegistrationV2{}
+		default:
+			return nil, fmt.Errorf("%w: unable to determine the registration version", ErrInvalidInput)
+		}
+	}
+
+	if err := json.Unmarshal(b, r); err != nil {
+		return nil, fmt.Errorf("%w: unable to decode RegistrationV%d: %w", ErrInvalidInput, r.Version(), err)
+	}
+
+	return r, nil
+}
+
+// present returns the sorted list of names that are keys in fields.  The
+// keys are compared case insensitively, as encoding/json does.
+func present(fields map[string]json.RawMessage, names []string) []string {
+	var found []string
+	for _, name := range names {
+		if _, ok := jsonField(fields, name); ok {
+			found = append(found, name)
+		}
+	}
+	sort.Strings(found)
+	return found
+}
+
+// jsonField returns the value of the key in fields that matches the name case
+// insensitively, preferring an exact match as encoding/json does.
+func jsonField(fields map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if raw, ok := fields[name]; ok {
+		return raw, true
+	}
+	for key, raw := range fields {
+		if strings.EqualFold(key, name) {
+			return raw, true
+		}
+	}
+	return nil, false
+}
+
+// matcherShape returns the first character of the raw json value, which is
+// '{' for a RegistrationV1 matcher and '[' for a RegistrationV2 matcher.
+func matcherShape(raw json.RawMessage) byte {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 {
+		return 0
+	}
+	return raw[0]
+}
@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeRegistration(t *testing.T) {
+	tests := []struct {
+		description string
+		input       string
+		expected    Registration
+		expectedErr error
+	}{
+		{
+			description: "RegistrationV1",
+			input:       `{"config":{"url":"https://example.com"},"events":["iot"],"duration":"5m0s"}`,
+			expected: &RegistrationV1{
+				Config:   DeliveryConfig{ReceiverURL: "https://example.com"},
+				Events:   []string{"iot"},
+				Duration: CustomDuration(5 * time.Minute),
+			},
+		}, {
+			description: "RegistrationV1 by matcher",
+			input:       `{"failure_url":"https://example.com","matcher":{"device_id":["mac:.*"]}}`,
+			expected: &RegistrationV1{
+				FailureURL: "https://example.com",
+				Matcher:    MetadataMatcherConfig{DeviceID: []string{"mac:.*"}},
+			},
+		}, {
+			description: "RegistrationV2",
+			input:       `{"canonical_name":"foo","webhooks":[{"receiver_urls":["https://example.com"]}]}`,
+			expected: &RegistrationV2{
+				CanonicalName: "foo",
+				Webhooks:      []Webhook{{ReceiverURLs: []string{"https://example.com"}}},
+			},
+		}, {
+			description: "RegistrationV2 kafkas only",
+			input:       `{"kafkas":[{"bootstrap_servers":["localhost:9092"]}]}`,
+			expected: &RegistrationV2{
+				Kafkas: []Kafka{{BootstrapServers: []string{"localhost:9092"}}},
+			},
+		}, {
+			description: "RegistrationV2 by matcher",
+			input:       ` {"matcher" : [{"field":"source","regex":".*"}]}`,
+			expected: &RegistrationV2{
+				Matcher: []FieldRegex{{Field: "source", Regex: ".*"}},
+			},
+		}, {
+			description: "RegistrationV2 with differently cased keys",
+			input:       `{"Canonical_Name":"foo","EXPIRES":"2021-01-01T00:00:00Z"}`,
+			expected: &RegistrationV2{
+				CanonicalName: "foo",
+				Expires:       CustomTime{Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		}, {
+			description: "RegistrationV1 by differently cased matcher",
+			input:       `{"Matcher":{"device_id":["mac:.*"]}}`,
+			expected: &RegistrationV1{
+				Matcher: MetadataMatcherConfig{DeviceID: []string{"mac:.*"}},
+			},
+		}, {
+			description: "mixed document",
+			input:       `{"config":{"url":"https://example.com"},"webhooks":[]}`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "ambiguous document",
+			input:       `{"failure_url":"https://example.com"}`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "empty document",
+			input:       `{}`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "null document",
+			input:       `null`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "not an object",
+			input:       `["config"]`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid json",
+			input:       `{"config":`,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid field value",
+			input:       `{"events":["iot"],"duration":{}}`,
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			r, err := DecodeRegistration([]byte(tc.input))
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.Nil(r)
+				return
+			}
+
+			assert.NoError(err)
+			assert.Equal(tc.expected, r)
+		})
+	}
+}
+
+func TestDecodeRegistrationRoundTrip(t *testing.T) {
+	regs := []Registration{
+		&RegistrationV1{
+			Config: DeliveryConfig{ReceiverURL: "https://example.com"},
+			Events: []string{"iot"},
+		},
+		&RegistrationV2{
+			CanonicalName: "foo",
+		},
+	}
+
+	for _, reg := range regs {
+		b, err := json.Marshal(reg)
+		require.NoError(t, err)
+
+		got, err := DecodeRegistration(b)
+		require.NoError(t, err)
+		assert.Equal(t, reg.Version(), got.Version())
+	}
+}
+
+func TestDecodeRegistrationKeepsDurationStyles(t *testing.T) {
+	docs := []string{
+		`{"config":{"url":"https://example.com"},"events":["iot"],"duration":"P7D"}`,
+		`{"config":{"url":"https://example.com"},"events":["iot"],"duration":604800}`,
+		`{"config":{"url":"https://example.com"},"events":["iot"],"duration":"PT1H30M"}`,
+		`{"config":{"url":"https://example.com"},"events":["iot"],"duration":"5m0s"}`,
+	}
+
+	for _, doc := range docs {
+		t.Run(doc, func(t *testing.T) {
+			reg, err := DecodeRegistration([]byte(doc))
+			require.NoError(t, err)
+
+			b, err := json.Marshal(reg)
+			require.NoError(t, err)
+
+			var in, out map[string]any
+			require.NoError(t, json.Unmarshal([]byte(doc), &in))
+			require.NoError(t, json.Unmarshal(b, &out))
+			assert.Equal(t, in["duration"], out["duration"])
+
+			again, err := DecodeRegistration(b)
+			require.NoError(t, err)
+			assert.Equal(t, reg, again)
+		})
+	}
+}
+
+func TestDecodeRegistrationErrorChain(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := DecodeRegistration([]byte(`{"config":{"url":"https://example.com"},"duration":"5 fortnights"}`))
+	assert.ErrorIs(err, ErrInvalidInput)
+	var ide *InvalidDurationError
+	assert.ErrorAs(err, &ide)
+
+	_, err = DecodeRegistration([]byte(`{"canonical_name":"a","expires":"not a time"}`))
+	assert.ErrorIs(err, ErrInvalidInput)
+	var ite *InvalidTimeError
+	assert.ErrorAs(err, &ite)
+
+	_, err = DecodeRegistration([]byte(`[`))
+	assert.ErrorIs(err, ErrInvalidInput)
+	var se *json.SyntaxError
+	assert.ErrorAs(err, &se)
+}
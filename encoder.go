@@ -0,0 +1,290 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+)
+
+// MediaTypeMultipartMixed is the media type used for batches of messages
+// that cannot be put in a single body.
+const MediaTypeMultipartMixed = "multipart/mixed"
+
+// The headers that carry the wrp message fields of an octet-stream body.
+const (
+	HeaderMessageType             = "X-Xmidt-Message-Type"
+	HeaderSource                  = "X-Xmidt-Source"
+	HeaderDestination             = "X-Xmidt-Dest"
+	HeaderTransactionUUID         = "X-Xmidt-Transaction-Uuid"
+	HeaderContentType             = "X-Xmidt-Content-Type"
+	HeaderAccept                  = "X-Xmidt-Accept"
+	HeaderStatus                  = "X-Xmidt-Status"
+	HeaderRequestDeliveryResponse = "X-Xmidt-Request-Delivery-Response"
+	HeaderHeaders                 = "X-Xmidt-Headers"
+	HeaderMetadata                = "X-Xmidt-Metadata"
+	HeaderPath                    = "X-Xmidt-Path"
+	HeaderServiceName             = "X-Xmidt-Service-Name"
+	HeaderURL                     = "X-Xmidt-Url"
+	HeaderPartnerID               = "X-Xmidt-Partner-Id"
+	HeaderSessionID               = "X-Xmidt-Session-Id"
+	HeaderQualityOfService        = "X-Xmidt-Qos"
+)
+
+var (
+	ErrEmptyBatch           = errors.New("empty batch")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+)
+
+// Body is an encoded batch of messages.
+type Body struct {
+	// ContentType is the value of the Content-Type header of the body.
+	ContentType string
+
+	// Header contains any additional headers that describe the body.  It is
+	// nil if there are none.
+	Header http.Header
+
+	// Data is the encoded batch.
+	Data []byte
+}
+
+// Encoder encodes a batch of one or more messages into a Body.
+type Encoder func(batch []Message) (Body, error)
+
+// Encoders maps media types to the Encoder that produces them.  Additional
+// media types can be supported by adding them to the map.
+type Encoders map[string]Encoder
+
+// DefaultEncoders returns the Encoders for each media type documented for
+// the Webhook and Kafka Accept values.
+//
+// The media types that hold a single message, application/wrp+json,
+// application/wrp+msgpack, application/wrp+octet-stream, application/json and
+// application/octet-stream, produce a multipart/mixed body with one part per
+// message for batches larger than 1.  The list media types,
+// application/wrp+jsonl, application/wrp+msgpackl, application/jsonl and
+// application/msgpack, always produce a single body.
+func DefaultEncoders() Encoders {
+	return Encoders{
+		MediaTypeWRPJSON:        single(encodeJSON(MediaTypeWRPJSON)),
+		MediaTypeWRPMsgpack:     single(encodeMsgpack(MediaTypeWRPMsgpack)),
+		MediaTypeWRPOctetStream: single(encodeOctetStream(MediaTypeWRPOctetStream)),
+		MediaTypeWRPJSONL:       encodeJSONL(MediaTypeWRPJSONL),
+		MediaTypeWRPMsgpackL:    encodeMsgpackL,
+		MediaTypeJSON:           single(encodeJSON(MediaTypeJSON)),
+		MediaTypeOctetStream:    single(encodeOctetStream(MediaTypeOctetStream)),
+		MediaTypeJSONL:          encodeJSONL(MediaTypeJSONL),
+		MediaTypeMsgpack:        encodeMsgpackList,
+	}
+}
+
+// Encode encodes the batch as the media type.  If payloadOnly is true, as
+// with the Webhook PayloadOnly field, the media type is ignored and each
+// message is represented by its payload and content type instead; a batch
+// larger than 1 then produces a multipart/mixed body.
+//
+// The media type must be a single media type, not an Accept list.  Use
+// Webhook.Encode or Kafka.Encode to negotiate the Accept value first.
+func (e Encoders) Encode(mediaType string, batch []Message, payloadOnly bool) (Body, error) {
+	if len(batch) == 0 {
+		return Body{}, ErrEmptyBatch
+	}
+	if payloadOnly {
+		return single(encodePayload)(batch)
+	}
+
+	encode, found := e[mediaType]
+	if !found {
+		return Body{}, fmt.Errorf("%w: '%s'", ErrUnsupportedMediaType, mediaType)
+	}
+	return encode(batch)
+}
+
+// Encode encodes the batch as the media type the Accept value of the webhook
+// negotiates to, or as the payloads if PayloadOnly is set.  If the Accept
+// value does not allow any supported media type a *NotAcceptableError is
+// returned.
+func (w Webhook) Encode(e Encoders, batch []Message) (Body, error) {
+	if w.PayloadOnly {
+		return e.Encode("", batch, true)
+	}
+	mediaType, err := w.NegotiateAccept()
+	if err != nil {
+		return Body{}, err
+	}
+	return e.Encode(mediaType, batch, false)
+}
+
+// Encode encodes the batch as the media type the Accept value of the kafka
+// negotiates to.  If the Accept value does not allow any supported media type
+// a *NotAcceptableError is returned.
+func (k Kafka) Encode(e Encoders, batch []Message) (Body, error) {
+	mediaType, err := k.NegotiateAccept()
+	if err != nil {
+		return Body{}, err
+	}
+	return e.Encode(mediaType, batch, false)
+}
+
+// single returns an Encoder that uses the encode function for a batch of 1
+// and creates a multipart/mixed body of the encoded messages for a larger
+// batch.
+func single(encode func(*Message) (Body, error)) Encoder {
+	return func(batch []Message) (Body, error) {
+		if len(batch) == 1 {
+			return encode(&batch[0])
+		}
+
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for i := range batch {
+			part, err := encode(&batch[i])
+			if err != nil {
+				return Body{}, err
+			}
+
+			header := textproto.MIMEHeader{}
+			for k, v := range part.Header {
+				header[k] = v
+			}
+			header.Set("Content-Type", part.ContentType)
+
+			w, err := mw.CreatePart(header)
+			if err != nil {
+				return Body{}, err
+			}
+			if _, err = w.Write(part.Data); err != nil {
+				return Body{}, err
+			}
+		}
+		if err := mw.Close(); err != nil {
+			return Body{}, err
+		}
+
+		return Body{
+			ContentType: mime.FormatMediaType(MediaTypeMultipartMixed, map[string]string{"boundary": mw.Boundary()}),
+			Data:        buf.Bytes(),
+		}, nil
+	}
+}
+
+func encodeJSON(mediaType string) func(*Message) (Body, error) {
+	return func(m *Message) (Body, error) {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return Body{}, err
+		}
+		return Body{ContentType: mediaType, Data: data}, nil
+	}
+}
+
+func encodeJSONL(mediaType string) Encoder {
+	return func(batch []Message) (Body, error) {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for i := range batch {
+			if err := enc.Encode(&batch[i]); err != nil {
+				return Body{}, err
+			}
+		}
+		return Body{ContentType: mediaType, Data: buf.Bytes()}, nil
+	}
+}
+
+func encodeMsgpack(mediaType string) func(*Message) (Body, error) {
+	return func(m *Message) (Body, error) {
+		var w msgpackWriter
+		w.message(m)
+		return Body{ContentType: mediaType, Data: w.buf}, nil
+	}
+}
+
+// encodeMsgpackL encodes the messages one after the other.
+func encodeMsgpackL(batch []Message) (Body, error) {
+	var w msgpackWriter
+	for i := range batch {
+		w.message(&batch[i])
+	}
+	return Body{ContentType: MediaTypeWRPMsgpackL, Data: w.buf}, nil
+}
+
+// encodeMsgpackList encodes the messages as a single msgpack array.
+func encodeMsgpackList(batch []Message) (Body, error) {
+	var w msgpackWriter
+	w.arrayHeader(len(batch))
+	for i := range batch {
+		w.message(&batch[i])
+	}
+	return Body{ContentType: MediaTypeMsgpack, Data: w.buf}, nil
+}
+
+// encodeOctetStream returns the payload as the body and the rest of the
+// message fields as headers.
+func encodeOctetStream(mediaType string) func(*Message) (Body, error) {
+	return func(m *Message) (Body, error) {
+		return Body{
+			ContentType: mediaType,
+			Header:      messageHeaders(m),
+			Data:        m.Payload,
+		}, nil
+	}
+}
+
+// encodePayload returns the payload as the body with the content type of the
+// message.
+func encodePayload(m *Message) (Body, error) {
+	ct := m.ContentType
+	if ct == "" {
+		ct = MediaTypeOctetStream
+	}
+	return Body{ContentType: ct, Data: m.Payload}, nil
+}
+
+// messageHeaders returns the headers for the fields of the message other
+// than the payload.  Empty fields are left out.
+func messageHeaders(m *Message) http.Header {
+	h := http.Header{}
+	set := func(key, value string) {
+		if value != "" {
+			h.Set(key, value)
+		}
+	}
+	setInt := func(key string, i *int64) {
+		if i != nil {
+			h.Set(key, strconv.FormatInt(*i, 10))
+		}
+	}
+
+	h.Set(HeaderMessageType, strconv.Itoa(m.Type))
+	set(HeaderSource, m.Source)
+	set(HeaderDestination, m.Destination)
+	set(HeaderTransactionUUID, m.TransactionUUID)
+	set(HeaderContentType, m.ContentType)
+	set(HeaderAccept, m.Accept)
+	setInt(HeaderStatus, m.Status)
+	setInt(HeaderRequestDeliveryResponse, m.RequestDeliveryResponse)
+	for _, v := range m.Headers {
+		h.Add(HeaderHeaders, v)
+	}
+	for _, k := range sortedKeys(m.Metadata) {
+		h.Add(HeaderMetadata, k+"="+m.Metadata[k])
+	}
+	set(HeaderPath, m.Path)
+	set(HeaderServiceName, m.ServiceName)
+	set(HeaderURL, m.URL)
+	for _, v := range m.PartnerIDs {
+		h.Add(HeaderPartnerID, v)
+	}
+	set(HeaderSessionID, m.SessionID)
+	h.Set(HeaderQualityOfService, strconv.Itoa(m.QualityOfService))
+	return h
+}
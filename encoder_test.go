@@ -0,0 +1,351 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	encoderMsgA = Message{
+		Type:        4,
+		Source:      "mac:112233445566",
+		Destination: "event:device-status",
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"b": "2", "a": "1"},
+		Payload:     []byte("hello"),
+	}
+	encoderMsgB = Message{
+		Type:    4,
+		Source:  "mac:665544332211",
+		Payload: []byte("world"),
+	}
+)
+
+type encodedPart struct {
+	ContentType string
+	Header      http.Header
+	Data        []byte
+}
+
+// parts returns the parts of a multipart/mixed body.
+func parts(t *testing.T, body Body) []encodedPart {
+	mediaType, params, err := mime.ParseMediaType(body.ContentType)
+	require.NoError(t, err)
+	require.Equal(t, MediaTypeMultipartMixed, mediaType)
+
+	var list []encodedPart
+	r := multipart.NewReader(bytes.NewReader(body.Data), params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			return list
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(p)
+		require.NoError(t, err)
+
+		header := http.Header(p.Header)
+		ct := header.Get("Content-Type")
+		header.Del("Content-Type")
+		if len(header) == 0 {
+			header = nil
+		}
+		list = append(list, encodedPart{ContentType: ct, Header: header, Data: data})
+	}
+}
+
+func jsonLine(t *testing.T, m Message) string {
+	b, err := json.Marshal(m)
+	require.NoError(t, err)
+	return string(b) + "\n"
+}
+
+func msgpackOf(m Message) []byte {
+	var w msgpackWriter
+	w.message(&m)
+	return w.buf
+}
+
+func TestEncodersSingle(t *testing.T) {
+	jsonA, err := json.Marshal(encoderMsgA)
+	require.NoError(t, err)
+
+	tests := []struct {
+		mediaType string
+		expected  Body
+	}{
+		{
+			mediaType: MediaTypeWRPJSON,
+			expected:  Body{ContentType: MediaTypeWRPJSON, Data: jsonA},
+		}, {
+			mediaType: MediaTypeJSON,
+			expected:  Body{ContentType: MediaTypeJSON, Data: jsonA},
+		}, {
+			mediaType: MediaTypeWRPMsgpack,
+			expected:  Body{ContentType: MediaTypeWRPMsgpack, Data: msgpackOf(encoderMsgA)},
+		}, {
+			mediaType: MediaTypeWRPMsgpackL,
+			expected:  Body{ContentType: MediaTypeWRPMsgpackL, Data: msgpackOf(encoderMsgA)},
+		}, {
+			mediaType: MediaTypeMsgpack,
+			expected:  Body{ContentType: MediaTypeMsgpack, Data: append([]byte{0x91}, msgpackOf(encoderMsgA)...)},
+		}, {
+			mediaType: MediaTypeWRPJSONL,
+			expected:  Body{ContentType: MediaTypeWRPJSONL, Data: []byte(jsonLine(t, encoderMsgA))},
+		}, {
+			mediaType: MediaTypeJSONL,
+			expected:  Body{ContentType: MediaTypeJSONL, Data: []byte(jsonLine(t, encoderMsgA))},
+		}, {
+			mediaType: MediaTypeWRPOctetStream,
+			expected: Body{
+				ContentType: MediaTypeWRPOctetStream,
+				Header: http.Header{
+					HeaderMessageType:      {"4"},
+					HeaderSource:           {"mac:112233445566"},
+					HeaderDestination:      {"event:device-status"},
+					HeaderContentType:      {"text/plain"},
+					HeaderMetadata:         {"a=1", "b=2"},
+					HeaderQualityOfService: {"0"},
+				},
+				Data: []byte("hello"),
+			},
+		}, {
+			mediaType: MediaTypeOctetStream,
+			expected: Body{
+				ContentType: MediaTypeOctetStream,
+				Header: http.Header{
+					HeaderMessageType:      {"4"},
+					HeaderSource:           {"mac:112233445566"},
+					HeaderDestination:      {"event:device-status"},
+					HeaderContentType:      {"text/plain"},
+					HeaderMetadata:         {"a=1", "b=2"},
+					HeaderQualityOfService: {"0"},
+				},
+				Data: []byte("hello"),
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.mediaType, func(t *testing.T) {
+			body, err := DefaultEncoders().Encode(tc.mediaType, []Message{encoderMsgA}, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, body)
+		})
+	}
+}
+
+func TestEncodersBatch(t *testing.T) {
+	batch := []Message{encoderMsgA, encoderMsgB}
+	jsonA, err := json.Marshal(encoderMsgA)
+	require.NoError(t, err)
+	jsonB, err := json.Marshal(encoderMsgB)
+	require.NoError(t, err)
+
+	t.Run("lists", func(t *testing.T) {
+		tests := []struct {
+			mediaType string
+			expected  []byte
+		}{
+			{
+				mediaType: MediaTypeWRPJSONL,
+				expected:  []byte(jsonLine(t, encoderMsgA) + jsonLine(t, encoderMsgB)),
+			}, {
+				mediaType: MediaTypeJSONL,
+				expected:  []byte(jsonLine(t, encoderMsgA) + jsonLine(t, encoderMsgB)),
+			}, {
+				mediaType: MediaTypeWRPMsgpackL,
+				expected:  append(msgpackOf(encoderMsgA), msgpackOf(encoderMsgB)...),
+			}, {
+				mediaType: MediaTypeMsgpack,
+				expected:  append(append([]byte{0x92}, msgpackOf(encoderMsgA)...), msgpackOf(encoderMsgB)...),
+			},
+		}
+		for _, tc := range tests {
+			body, err := DefaultEncoders().Encode(tc.mediaType, batch, false)
+			require.NoError(t, err)
+			assert.Equal(t, tc.mediaType, body.ContentType)
+			assert.Equal(t, tc.expected, body.Data)
+		}
+	})
+
+	t.Run("multipart", func(t *testing.T) {
+		tests := []struct {
+			mediaType string
+			expected  []encodedPart
+		}{
+			{
+				mediaType: MediaTypeWRPJSON,
+				expected: []encodedPart{
+					{ContentType: MediaTypeWRPJSON, Data: jsonA},
+					{ContentType: MediaTypeWRPJSON, Data: jsonB},
+				},
+			}, {
+				mediaType: MediaTypeJSON,
+				expected: []encodedPart{
+					{ContentType: MediaTypeJSON, Data: jsonA},
+					{ContentType: MediaTypeJSON, Data: jsonB},
+				},
+			}, {
+				mediaType: MediaTypeWRPMsgpack,
+				expected: []encodedPart{
+					{ContentType: MediaTypeWRPMsgpack, Data: msgpackOf(encoderMsgA)},
+					{ContentType: MediaTypeWRPMsgpack, Data: msgpackOf(encoderMsgB)},
+				},
+			}, {
+				mediaType: MediaTypeWRPOctetStream,
+				expected: []encodedPart{
+					{ContentType: MediaTypeWRPOctetStream, Header: messageHeaders(&encoderMsgA), Data: []byte("hello")},
+					{ContentType: MediaTypeWRPOctetStream, Header: messageHeaders(&encoderMsgB), Data: []byte("world")},
+				},
+			},
+		}
+		for _, tc := range tests {
+			body, err := DefaultEncoders().Encode(tc.mediaType, batch, false)
+			require.NoError(t, err)
+			assert.Nil(t, body.Header)
+			assert.Equal(t, tc.expected, parts(t, body), tc.mediaType)
+		}
+	})
+}
+
+func TestEncodersPayloadOnly(t *testing.T) {
+	enc := DefaultEncoders()
+
+	body, err := enc.Encode(MediaTypeWRPJSONL, []Message{encoderMsgA}, true)
+	require.NoError(t, err)
+	assert.Equal(t, Body{ContentType: "text/plain", Data: []byte("hello")}, body)
+
+	body, err = enc.Encode(MediaTypeWRPMsgpackL, []Message{encoderMsgA, encoderMsgB}, true)
+	require.NoError(t, err)
+	assert.Equal(t, []encodedPart{
+		{ContentType: "text/plain", Data: []byte("hello")},
+		{ContentType: MediaTypeOctetStream, Data: []byte("world")},
+	}, parts(t, body))
+}
+
+func TestWebhookAndKafkaEncode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	enc := DefaultEncoders()
+	batch := []Message{encoderMsgA}
+
+	// The Accept list is negotiated before the encoder is chosen.
+	body, err := Webhook{Accept: "text/plain, application/wrp+msgpack;q=0.5, application/*;q=0.1"}.Encode(enc, batch)
+	require.NoError(err)
+	assert.Equal(MediaTypeWRPMsgpack, body.ContentType)
+
+	body, err = Webhook{}.Encode(enc, batch)
+	require.NoError(err)
+	assert.Equal(MediaTypeWRPJSON, body.ContentType)
+
+	body, err = Webhook{Accept: "text/plain", PayloadOnly: true}.Encode(enc, batch)
+	require.NoError(err)
+	assert.Equal("text/plain", body.ContentType)
+
+	_, err = Webhook{Accept: "text/plain"}.Encode(enc, batch)
+	var nae *NotAcceptableError
+	assert.ErrorAs(err, &nae)
+
+	body, err = Kafka{Accept: "application/*"}.Encode(enc, batch)
+	require.NoError(err)
+	assert.Equal(MediaTypeOctetStream, body.ContentType)
+
+	_, err = Kafka{Accept: MediaTypeWRPJSON}.Encode(enc, batch)
+	assert.ErrorIs(err, ErrNotAcceptable)
+}
+
+func TestEncodersErrors(t *testing.T) {
+	enc := DefaultEncoders()
+
+	_, err := enc.Encode(MediaTypeWRPJSON, nil, false)
+	assert.ErrorIs(t, err, ErrEmptyBatch)
+
+	_, err = enc.Encode("text/html", []Message{encoderMsgA}, false)
+	assert.ErrorIs(t, err, ErrUnsupportedMediaType)
+
+	enc["text/plain"] = func(batch []Message) (Body, error) {
+		return Body{ContentType: "text/plain", Data: batch[0].Payload}, nil
+	}
+	body, err := enc.Encode("text/plain", []Message{encoderMsgA}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), body.Data)
+}
+
+func TestMsgpackWriter(t *testing.T) {
+	status := int64(-200)
+	tests := []struct {
+		description string
+		msg         Message
+		expected    []byte
+	}{
+		{
+			description: "empty message",
+			expected: []byte{
+				0x82,
+				0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0x00,
+				0xa3, 'q', 'o', 's', 0x00,
+			},
+		}, {
+			description: "all kinds of values",
+			msg: Message{
+				Type:       3,
+				Status:     &status,
+				Headers:    []string{"a"},
+				Metadata:   map[string]string{"k": "v"},
+				Payload:    []byte{0x01},
+				PartnerIDs: []string{},
+			},
+			expected: []byte{
+				0x86,
+				0xa8, 'm', 's', 'g', '_', 't', 'y', 'p', 'e', 0x03,
+				0xa6, 's', 't', 'a', 't', 'u', 's', 0xd1, 0xff, 0x38,
+				0xa7, 'h', 'e', 'a', 'd', 'e', 'r', 's', 0x91, 0xa1, 'a',
+				0xa8, 'm', 'e', 't', 'a', 'd', 'a', 't', 'a', 0x81, 0xa1, 'k', 0xa1, 'v',
+				0xa7, 'p', 'a', 'y', 'l', 'o', 'a', 'd', 0xc4, 0x01, 0x01,
+				0xa3, 'q', 'o', 's', 0x00,
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, msgpackOf(tc.msg))
+		})
+	}
+
+	ints := []struct {
+		in       int64
+		expected []byte
+	}{
+		{in: 0, expected: []byte{0x00}},
+		{in: 127, expected: []byte{0x7f}},
+		{in: -1, expected: []byte{0xff}},
+		{in: -32, expected: []byte{0xe0}},
+		{in: -33, expected: []byte{0xd0, 0xdf}},
+		{in: 128, expected: []byte{0xd1, 0x00, 0x80}},
+		{in: 70000, expected: []byte{0xd2, 0x00, 0x01, 0x11, 0x70}},
+		{in: 1 << 40, expected: []byte{0xd3, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00}},
+	}
+	for _, tc := range ints {
+		var w msgpackWriter
+		w.int(tc.in)
+		assert.Equal(t, tc.expected, w.buf, tc.in)
+	}
+
+	var w msgpackWriter
+	w.string(string(make([]byte, 40)))
+	assert.Equal(t, []byte{0xd9, 40}, w.buf[:2])
+	w = msgpackWriter{}
+	w.arrayHeader(16)
+	assert.Equal(t, []byte{0xdc, 0x00, 0x10}, w.buf)
+}
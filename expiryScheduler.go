@@ -0,0 +1,370 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"container/heap"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSchedulerStopped = errors.New("expiry scheduler is stopped")
+)
+
+// ExpiryNotice tells the owner of a registration that it is about to expire,
+// or that it has expired.
+type ExpiryNotice struct {
+	// Key is the key of the registration.
+	Key RegistrationKey
+
+	// Registration is the registration that is expiring.
+	Registration Registration
+
+	// ExpiresAt is when the registration expires.
+	ExpiresAt time.Time
+
+	// Lead is how long before ExpiresAt the notice is for.  It is 0 for the
+	// notice sent when the registration expires.
+	Lead time.Duration
+
+	// Remaining is how long was left until ExpiresAt when the notice was
+	// sent.  It is close to Lead, but is shorter when the notice is sent
+	// late, and is never negative.
+	Remaining time.Duration
+
+	// FailureURL is the FailureURL of the registration, where the Payload
+	// can be posted.  It is empty if the registration does not have one.
+	FailureURL string
+
+	// Email is the ContactInfo.Email of a RegistrationV2.  It is empty if the
+	// registration does not have one.
+	Email string
+}
+
+// Expired returns true if the notice is for the expiration itself.
+func (n ExpiryNotice) Expired() bool {
+	return n.Lead == 0
+}
+
+// expiryPayload is the json body of an ExpiryNotice.
+type expiryPayload struct {
+	Version   int            `json:"version"`
+	ID        string         `json:"id"`
+	ExpiresAt time.Time      `json:"expires_at"`
+	Remaining CustomDuration `json:"remaining"`
+	Expired   bool           `json:"expired"`
+	Message   string         `json:"message"`
+}
+
+// Payload returns the json body of the notice, which can be posted to the
+// FailureURL or sent to the Email.  The remaining time is Remaining, rounded
+// to the second.  For example:
+//
+//	{
+//	  "version": 2,
+//	  "id": "my-registration",
+//	  "expires_at": "2023-01-02T15:04:05Z",
+//	  "remaining": "1h0m0s",
+//	  "expired": false,
+//	  "message": "the registration expires in 1h0m0s, renew it to keep receiving events"
+//	}
+func (n ExpiryNotice) Payload() ([]byte, error) {
+	remaining := CustomDuration(n.Remaining.Round(time.Second))
+	p := expiryPayload{
+		Version:   n.Key.Version,
+		ID:        n.Key.ID,
+		ExpiresAt: n.ExpiresAt.UTC(),
+		Remaining: remaining,
+		Expired:   n.Expired(),
+		Message:   fmt.Sprintf("the registration expires in %s, renew it to keep receiving events", remaining),
+	}
+	if p.Expired {
+		p.Message = "the registration has expired and no longer receives events"
+	}
+	return json.Marshal(p)
+}
+
+// expiryItem is the next notice of a registration.
+type expiryItem struct {
+	key          RegistrationKey
+	registration Registration
+	expiresAt    time.Time
+
+	// lead is the index into ExpiryScheduler.leads of the notice, or
+	// len(leads) for the notice at expiration.
+	lead  int
+	when  time.Time
+	index int
+}
+
+// expiryHeap is a min-heap of the items ordered by when they are due.
+type expiryHeap []*expiryItem
+
+func (h expiryHeap) Len() int { return len(h) }
+
+func (h expiryHeap) Less(i, j int) bool {
+	if h[i].when.Equal(h[j].when) {
+		return keyLess(h[i].key, h[j].key)
+	}
+	return h[i].when.Before(h[j].when)
+}
+
+func (h expiryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *expiryHeap) Push(x any) {
+	item := x.(*expiryItem)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *expiryHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	item.index = -1
+	return item
+}
+
+// ExpiryScheduler notifies the owners of registrations before and when they
+// expire.  For each scheduled registration a notice is sent at each of the
+// lead times before it expires, and then when it expires.  Notices that are
+// already due when a registration is scheduled are skipped, except for the
+// notice at expiration.
+//
+// An ExpiryScheduler is safe for concurrent use.  The notices are passed to
+// the notify function one at a time, in the order they are due.  The notify
+// function may call the ExpiryScheduler, for example to reschedule a
+// registration that was renewed.
+type ExpiryScheduler struct {
+	leads  []time.Duration
+	clock  Clock
+	notify func(ExpiryNotice)
+
+	seq sequencer
+
+	mu      sync.Mutex
+	heap    expiryHeap
+	items   map[RegistrationKey]*expiryItem
+	timer   Timer
+	stopped bool
+}
+
+// NewExpiryScheduler creates an ExpiryScheduler that sends notices at each of
+// the lead times before a registration expires, for example 24h and 1h.  The
+// lead times must be positive.  If the clock is nil, the system clock is
+// used.
+func NewExpiryScheduler(leads []time.Duration, clock Clock, notify func(ExpiryNotice)) (*ExpiryScheduler, error) {
+	if notify == nil {
+		return nil, fmt.Errorf("%w: a notify function is required", ErrInvalidInput)
+	}
+
+	sorted := make([]time.Duration, 0, len(leads))
+	for _, lead := range leads {
+		if lead <= 0 {
+			return nil, fmt.Errorf("%w: lead time %s must be positive", ErrInvalidInput, lead)
+		}
+		sorted = append(sorted, lead)
+	}
+
+	// Longest lead first, which is the order the notices are due in.
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+	unique := sorted[:0]
+	for i, lead := range sorted {
+		if i == 0 || lead != sorted[i-1] {
+			unique = append(unique, lead)
+		}
+	}
+
+	return &ExpiryScheduler{
+		leads:  unique,
+		clock:  clockOrSystem(clock),
+		notify: notify,
+		items:  make(map[RegistrationKey]*expiryItem),
+	}, nil
+}
+
+// Schedule schedules the notices for the registration, which expires at the
+// time passed in, replacing any notices scheduled for a registration with the
+// same key.  A zero expiresAt cancels the notices instead.
+func (s *ExpiryScheduler) Schedule(r Registration, expiresAt time.Time) error {
+	key, err := KeyOf(r)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return ErrSchedulerStopped
+	}
+
+	s.remove(key)
+	if !expiresAt.IsZero() {
+		item := &expiryItem{
+			key:          key,
+			registration: r,
+			expiresAt:    expiresAt,
+			lead:         -1,
+		}
+		if s.advance(item, s.clock.Now()) {
+			heap.Push(&s.heap, item)
+			s.items[key] = item
+		}
+	}
+	s.reset()
+	return nil
+}
+
+// Cancel cancels the notices of the registration with the key.  It returns
+// false if there are none.
+func (s *ExpiryScheduler) Cancel(key RegistrationKey) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	found := s.remove(key)
+	s.reset()
+	return found
+}
+
+// Len returns the number of registrations with notices still to send.
+func (s *ExpiryScheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.heap)
+}
+
+// Stop cancels all of the notices.  Registrations cannot be scheduled after
+// the ExpiryScheduler is stopped.
+func (s *ExpiryScheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	s.heap = nil
+	s.items = make(map[RegistrationKey]*expiryItem)
+	s.reset()
+}
+
+// Follow keeps the notices in step with the registrations of the Registry
+// until the returned cancel function is called.  The registrations already
+// in the Registry, and those added or updated later, are scheduled and
+// deleted ones are canceled.
+func (s *ExpiryScheduler) Follow(r *Registry) (cancel func()) {
+	return r.Subscribe(func(e RegistryEvent) {
+		switch e.Type {
+		case RegistryAdded, RegistryUpdated:
+			_ = s.Schedule(e.Registration, e.ExpiresAt)
+		case RegistryDeleted:
+			s.Cancel(e.Key)
+		}
+	}, ReplayExisting())
+}
+
+// advance moves the item on to its next notice that is not yet due, or to the
+// notice at expiration.  It returns false if there are no notices left.
+func (s *ExpiryScheduler) advance(item *expiryItem, now time.Time) bool {
+	for item.lead++; item.lead < len(s.leads); item.lead++ {
+		item.when = item.expiresAt.Add(-s.leads[item.lead])
+		if item.when.After(now) {
+			return true
+		}
+	}
+	if item.lead == len(s.leads) {
+		item.when = item.expiresAt
+		return true
+	}
+	return false
+}
+
+// remove removes the item of the key.  mu must be held.
+func (s *ExpiryScheduler) remove(key RegistrationKey) bool {
+	item, found := s.items[key]
+	if !found {
+		return false
+	}
+	heap.Remove(&s.heap, item.index)
+	delete(s.items, key)
+	return true
+}
+
+// reset starts the timer for the first item that is due.  mu must be held.
+func (s *ExpiryScheduler) reset() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if len(s.heap) == 0 {
+		return
+	}
+
+	d := s.heap[0].when.Sub(s.clock.Now())
+	if d < 0 {
+		d = 0
+	}
+	s.timer = s.clock.AfterFunc(d, s.fire)
+}
+
+// fire sends the notices that are due.
+func (s *ExpiryScheduler) fire() {
+	s.mu.Lock()
+	now := s.clock.Now()
+
+	var notices []ExpiryNotice
+	for len(s.heap) > 0 && !s.heap[0].when.After(now) {
+		item := s.heap[0]
+		notices = append(notices, s.notice(item, now))
+
+		if s.advance(item, now) {
+			heap.Fix(&s.heap, 0)
+		} else {
+			heap.Pop(&s.heap)
+			delete(s.items, item.key)
+		}
+	}
+	s.reset()
+
+	t := s.seq.ticket()
+	s.mu.Unlock()
+	s.seq.run(t, func() {
+		for _, n := range notices {
+			s.notify(n)
+		}
+	})
+}
+
+// notice returns the notice of the item sent at the time passed in.
+func (s *ExpiryScheduler) notice(item *expiryItem, now time.Time) ExpiryNotice {
+	n := ExpiryNotice{
+		Key:          item.key,
+		Registration: item.registration,
+		ExpiresAt:    item.expiresAt,
+	}
+	if remaining := item.expiresAt.Sub(now); remaining > 0 {
+		n.Remaining = remaining
+	}
+	if item.lead < len(s.leads) {
+		n.Lead = s.leads[item.lead]
+	}
+
+	switch r := item.registration.(type) {
+	case *RegistrationV1:
+		n.FailureURL = r.FailureURL
+	case *RegistrationV2:
+		n.FailureURL = r.FailureURL
+		n.Email = r.ContactInfo.Email
+	}
+	return n
+}
@@ -0,0 +1,263 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noticeRecorder records the notices of an ExpiryScheduler.
+type noticeRecorder struct {
+	mu      sync.Mutex
+	clock   Clock
+	notices []string
+}
+
+func (nr *noticeRecorder) notify(n ExpiryNotice) {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	nr.notices = append(nr.notices, fmt.Sprintf("%s %s at %s", n.Key, n.Lead, nr.clock.Now().Sub(mockNow())))
+}
+
+// take returns the recorded notices and forgets them.
+func (nr *noticeRecorder) take() []string {
+	nr.mu.Lock()
+	defer nr.mu.Unlock()
+	list := nr.notices
+	nr.notices = nil
+	return list
+}
+
+func TestNewExpiryScheduler(t *testing.T) {
+	_, err := NewExpiryScheduler(nil, nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewExpiryScheduler([]time.Duration{time.Hour, 0}, nil, func(ExpiryNotice) {})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour, 24 * time.Hour, time.Hour}, nil, func(ExpiryNotice) {})
+	require.NoError(t, err)
+	assert.Equal(t, []time.Duration{24 * time.Hour, time.Hour}, s.leads)
+}
+
+func TestExpiryScheduler(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := newFakeClock()
+	rec := noticeRecorder{clock: clock}
+	s, err := NewExpiryScheduler([]time.Duration{24 * time.Hour, time.Hour}, clock, rec.notify)
+	require.NoError(err)
+
+	now := clock.Now()
+	a := &RegistrationV2{CanonicalName: "a"}
+	b := &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "b"}}
+	c := &RegistrationV2{CanonicalName: "c"}
+
+	require.NoError(s.Schedule(a, now.Add(48*time.Hour)))
+	// The 24h notice is already due so it is skipped.
+	require.NoError(s.Schedule(b, now.Add(12*time.Hour)))
+	require.NoError(s.Schedule(c, now.Add(30*time.Minute)))
+	assert.Equal(3, s.Len())
+
+	clock.Advance(time.Hour)
+	assert.Equal([]string{"v2:c 0s at 30m0s"}, rec.take())
+	assert.Equal(2, s.Len())
+
+	clock.Advance(24 * time.Hour)
+	assert.Equal([]string{
+		"v1:b 1h0m0s at 11h0m0s",
+		"v1:b 0s at 12h0m0s",
+		"v2:a 24h0m0s at 24h0m0s",
+	}, rec.take())
+
+	// Rescheduling replaces the notices.
+	require.NoError(s.Schedule(a, now.Add(72*time.Hour)))
+	clock.Advance(22 * time.Hour)
+	assert.Empty(rec.take())
+	clock.Advance(2 * time.Hour)
+	assert.Equal([]string{"v2:a 24h0m0s at 48h0m0s"}, rec.take())
+
+	clock.Advance(48 * time.Hour)
+	assert.Equal([]string{
+		"v2:a 1h0m0s at 71h0m0s",
+		"v2:a 0s at 72h0m0s",
+	}, rec.take())
+	assert.Zero(s.Len())
+}
+
+func TestExpirySchedulerCancelAndStop(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := newFakeClock()
+	rec := noticeRecorder{clock: clock}
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour}, clock, rec.notify)
+	require.NoError(err)
+
+	a := &RegistrationV2{CanonicalName: "a"}
+	b := &RegistrationV2{CanonicalName: "b"}
+	require.NoError(s.Schedule(a, clock.Now().Add(2*time.Hour)))
+	require.NoError(s.Schedule(b, clock.Now().Add(3*time.Hour)))
+
+	assert.True(s.Cancel(RegistrationKey{Version: 2, ID: "a"}))
+	assert.False(s.Cancel(RegistrationKey{Version: 2, ID: "a"}))
+
+	// A zero time cancels too.
+	require.NoError(s.Schedule(b, time.Time{}))
+	assert.Zero(s.Len())
+
+	require.NoError(s.Schedule(a, clock.Now().Add(2*time.Hour)))
+	s.Stop()
+	clock.Advance(3 * time.Hour)
+	assert.Empty(rec.take())
+	assert.ErrorIs(s.Schedule(a, clock.Now().Add(time.Hour)), ErrSchedulerStopped)
+
+	assert.ErrorIs(s.Schedule(&RegistrationV2{}, clock.Now()), ErrInvalidInput)
+}
+
+func TestExpirySchedulerFollow(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := newFakeClock()
+	rec := noticeRecorder{clock: clock}
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour}, clock, rec.notify)
+	require.NoError(err)
+
+	// The registrations added before Follow is called are scheduled too.
+	r := NewRegistry(nil, clock)
+	_, err = r.Upsert(&RegistrationV1{Config: DeliveryConfig{ReceiverURL: "a"}, Duration: CustomDuration(2 * time.Hour)})
+	require.NoError(err)
+	cancel := s.Follow(r)
+	defer cancel()
+
+	_, err = r.Upsert(&RegistrationV2{CanonicalName: "b", Expires: CustomTime{Time: clock.Now().Add(2 * time.Hour)}})
+	require.NoError(err)
+	_, err = r.Upsert(&RegistrationV2{CanonicalName: "forever"})
+	require.NoError(err)
+	assert.Equal(2, s.Len())
+
+	r.Delete(RegistrationKey{Version: 2, ID: "b"})
+	clock.Advance(2 * time.Hour)
+	assert.Equal([]string{"v1:a 1h0m0s at 1h0m0s", "v1:a 0s at 2h0m0s"}, rec.take())
+}
+
+func TestExpiryNotice(t *testing.T) {
+	expiresAt := mockNow().Add(time.Hour)
+
+	var got []ExpiryNotice
+	clock := newFakeClock()
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour}, clock, func(n ExpiryNotice) {
+		got = append(got, n)
+	})
+	require.NoError(t, err)
+
+	v2 := &RegistrationV2{
+		CanonicalName: "a",
+		FailureURL:    "https://failure.example.com",
+		ContactInfo:   ContactInfo{Email: "owner@example.com"},
+	}
+	v1 := &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "b"}, FailureURL: "https://v1.example.com"}
+	require.NoError(t, s.Schedule(v2, expiresAt.Add(time.Hour)))
+	require.NoError(t, s.Schedule(v1, expiresAt))
+
+	clock.Advance(time.Hour)
+	require.Len(t, got, 2)
+
+	assert.Equal(t, "https://v1.example.com", got[0].FailureURL)
+	assert.Empty(t, got[0].Email)
+	assert.True(t, got[0].Expired())
+
+	assert.Equal(t, "https://failure.example.com", got[1].FailureURL)
+	assert.Equal(t, "owner@example.com", got[1].Email)
+	assert.False(t, got[1].Expired())
+	assert.Same(t, v2, got[1].Registration)
+
+	payload, err := got[1].Payload()
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"version": 2,
+		"id": "a",
+		"expires_at": "2009-11-11T01:00:00Z",
+		"remaining": "1h0m0s",
+		"expired": false,
+		"message": "the registration expires in 1h0m0s, renew it to keep receiving events"
+	}`, string(payload))
+
+	payload, err = got[0].Payload()
+	require.NoError(t, err)
+	var p map[string]any
+	require.NoError(t, json.Unmarshal(payload, &p))
+	assert.Equal(t, true, p["expired"])
+	assert.Equal(t, "b", p["id"])
+	assert.Equal(t, "0s", p["remaining"])
+}
+
+// lateClock is a fakeClock whose timers fire late.
+type lateClock struct {
+	*fakeClock
+	late time.Duration
+}
+
+func (c lateClock) AfterFunc(d time.Duration, f func()) Timer {
+	return c.fakeClock.AfterFunc(d+c.late, f)
+}
+
+func TestExpiryNoticeSentLate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var got []ExpiryNotice
+	clock := lateClock{fakeClock: newFakeClock(), late: 10 * time.Minute}
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour}, clock, func(n ExpiryNotice) {
+		got = append(got, n)
+	})
+	require.NoError(err)
+
+	v2 := &RegistrationV2{CanonicalName: "a"}
+	require.NoError(s.Schedule(v2, clock.Now().Add(2*time.Hour)))
+	clock.Advance(time.Hour + 10*time.Minute)
+	require.Len(got, 1)
+	assert.Equal(time.Hour, got[0].Lead)
+	assert.Equal(50*time.Minute, got[0].Remaining)
+
+	payload, err := got[0].Payload()
+	require.NoError(err)
+	var p map[string]any
+	require.NoError(json.Unmarshal(payload, &p))
+	assert.Equal("50m0s", p["remaining"])
+	assert.Equal("the registration expires in 50m0s, renew it to keep receiving events", p["message"])
+
+	// The notice at expiration has no time remaining.
+	clock.Advance(time.Hour)
+	require.Len(got, 2)
+	assert.Zero(got[1].Remaining)
+}
+
+func TestExpirySchedulerNotifyReschedules(t *testing.T) {
+	clock := newFakeClock()
+	var s *ExpiryScheduler
+	var notices []time.Duration
+	s, err := NewExpiryScheduler([]time.Duration{time.Hour}, clock, func(n ExpiryNotice) {
+		notices = append(notices, n.Lead)
+		if !n.Expired() {
+			// The registration is renewed when its owner is told.
+			assert.NoError(t, s.Schedule(n.Registration, n.ExpiresAt.Add(time.Hour)))
+		}
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, s.Schedule(&RegistrationV2{CanonicalName: "a"}, clock.Now().Add(2*time.Hour)))
+	clock.Advance(2 * time.Hour)
+	assert.Equal(t, []time.Duration{time.Hour, time.Hour}, notices)
+	assert.Equal(t, 1, s.Len())
+}
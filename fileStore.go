@@ -0,0 +1,329 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// The files a FileStore keeps in its directory.
+const (
+	FileStoreSnapshot = "snapshot.jsonl"
+	FileStoreLog      = "log.jsonl"
+)
+
+// The operations recorded in the FileStore log.
+const (
+	fileOpUpsert = "upsert"
+	fileOpDelete = "delete"
+)
+
+// fileRecord is a line of the FileStore snapshot or log.  Snapshot lines
+// have no Op.
+type fileRecord struct {
+	Op           string          `json:"op,omitempty"`
+	Version      int             `json:"version"`
+	ID           string          `json:"id,omitempty"`
+	Registration json.RawMessage `json:"registration,omitempty"`
+}
+
+// registration decodes the registration of the record.
+func (fr *fileRecord) registration() (Registration, error) {
+	var r Registration
+	switch fr.Version {
+	case 1:
+		r = &RegistrationV1{}
+	case 2:
+		r = &RegistrationV2{}
+	default:
+		return nil, fmt.Errorf("%w: registration version %d", ErrInvalidInput, fr.Version)
+	}
+	if err := json.Unmarshal(fr.Registration, r); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FileStore is a Store that keeps the registrations in a directory.  The
+// directory holds a snapshot of the registrations with one json record per
+// line, and a log of the changes made since the snapshot was written.  When
+// the FileStore is opened the log is replayed onto the snapshot, and Compact
+// folds the log into a new snapshot.
+//
+// A FileStore is safe for concurrent use, but only one FileStore may use a
+// directory at a time.
+type FileStore struct {
+	dir string
+	mem *MemoryStore
+
+	// mu serializes the changes so the log and mem agree.
+	mu  sync.Mutex
+	log *os.File
+}
+
+var _ Store = (*FileStore)(nil)
+
+// OpenFileStore opens the FileStore in the directory, creating the directory
+// if it does not exist.  The registrations in the directory are loaded and
+// compacted into a new snapshot.
+func OpenFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	fs := FileStore{
+		dir: dir,
+		mem: NewMemoryStore(),
+	}
+	if err := fs.load(FileStoreSnapshot); err != nil {
+		return nil, err
+	}
+	if err := fs.load(FileStoreLog); err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	if err := fs.compact(); err != nil {
+		return nil, err
+	}
+	return &fs, nil
+}
+
+func (fs *FileStore) Get(ctx context.Context, key RegistrationKey) (Registration, error) {
+	return fs.mem.Get(ctx, key)
+}
+
+func (fs *FileStore) List(ctx context.Context) ([]Registration, error) {
+	return fs.mem.List(ctx)
+}
+
+func (fs *FileStore) Upsert(_ context.Context, r Registration) error {
+	key, err := KeyOf(r)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	err = fs.append(fileRecord{
+		Op:           fileOpUpsert,
+		Version:      key.Version,
+		ID:           key.ID,
+		Registration: data,
+	})
+	if err != nil {
+		return err
+	}
+
+	fs.mem.mu.Lock()
+	fs.mem.upsert(key, r)
+	return nil
+}
+
+func (fs *FileStore) Delete(_ context.Context, key RegistrationKey) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.mem.mu.Lock()
+	if _, found := fs.mem.regs[key]; !found {
+		fs.mem.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	fs.mem.mu.Unlock()
+
+	err := fs.append(fileRecord{
+		Op:      fileOpDelete,
+		Version: key.Version,
+		ID:      key.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	fs.mem.mu.Lock()
+	fs.mem.delete(key)
+	return nil
+}
+
+func (fs *FileStore) Watch(ctx context.Context, fn func(RegistryEvent)) error {
+	return fs.mem.Watch(ctx, fn)
+}
+
+// Compact writes a new snapshot of the registrations and empties the log.
+func (fs *FileStore) Compact() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	return fs.compact()
+}
+
+// Close closes the log.  The FileStore must not be used after it is closed.
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.log == nil {
+		return nil
+	}
+	err := fs.log.Close()
+	fs.log = nil
+	return err
+}
+
+// load applies the records of the file to mem.  A missing file is empty.  A
+// last line without a newline is applied if it decodes, and is otherwise
+// ignored as left by an interrupted write.
+func (fs *FileStore) load(name string) error {
+	data, err := os.ReadFile(filepath.Join(fs.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := bytes.Split(data, []byte{'\n'})
+	last := len(lines) - 1
+	for i, line := range lines {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		if err := fs.apply(line); err != nil && i != last {
+			return fmt.Errorf("%s line %d: %w", name, i+1, err)
+		}
+	}
+	return nil
+}
+
+// apply applies a snapshot or log record to mem.
+func (fs *FileStore) apply(line []byte) error {
+	var fr fileRecord
+	if err := json.Unmarshal(line, &fr); err != nil {
+		return err
+	}
+
+	key := RegistrationKey{Version: fr.Version, ID: fr.ID}
+	switch fr.Op {
+	case "", fileOpUpsert:
+		r, err := fr.registration()
+		if err != nil {
+			return err
+		}
+		if key, err = KeyOf(r); err != nil {
+			return err
+		}
+		fs.mem.regs[key] = r
+	case fileOpDelete:
+		delete(fs.mem.regs, key)
+	default:
+		return fmt.Errorf("%w: unknown operation '%s'", ErrInvalidInput, fr.Op)
+	}
+	return nil
+}
+
+// append writes the record to the log and syncs it.  mu must be held.
+func (fs *FileStore) append(fr fileRecord) error {
+	if fs.log == nil {
+		return os.ErrClosed
+	}
+
+	data, err := json.Marshal(fr)
+	if err != nil {
+		return err
+	}
+	if _, err = fs.log.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return fs.log.Sync()
+}
+
+// compact writes the snapshot to a temporary file, renames it over the old
+// snapshot and then starts a new log.  If it is interrupted, the old log is
+// replayed onto the new snapshot, which gives the same result.  mu must be
+// held.
+func (fs *FileStore) compact() error {
+	list, err := fs.mem.List(context.Background())
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(fs.dir, FileStoreSnapshot+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	for _, r := range list {
+		key, err := KeyOf(r)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		data, err := json.Marshal(r)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		line, err := json.Marshal(fileRecord{Version: key.Version, ID: key.ID, Registration: data})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err = w.Write(append(line, '\n')); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err = w.Flush(); err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = os.Rename(tmp.Name(), filepath.Join(fs.dir, FileStoreSnapshot)); err != nil {
+		return err
+	}
+
+	// The rename must be durable before the log is emptied.
+	if err = syncDir(fs.dir); err != nil {
+		return err
+	}
+
+	if fs.log != nil {
+		fs.log.Close()
+	}
+	fs.log, err = os.OpenFile(filepath.Join(fs.dir, FileStoreLog), os.O_CREATE|os.O_WRONLY|os.O_TRUNC|os.O_APPEND, 0o600)
+	return err
+}
+
+// syncDir syncs the directory so the changes to its entries are durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if cerr := d.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
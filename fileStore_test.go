@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readLines(t *testing.T, dir, name string) []string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+func TestFileStoreLogAndCompact(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	fs, err := OpenFileStore(dir)
+	require.NoError(err)
+
+	expires := CustomTime{Time: mockNow().Add(time.Hour)}
+	require.NoError(fs.Upsert(ctx, &RegistrationV2{CanonicalName: "a", Expires: expires}))
+	require.NoError(fs.Upsert(ctx, &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "b"}, Duration: CustomDuration(time.Minute)}))
+	require.NoError(fs.Delete(ctx, RegistrationKey{Version: 1, ID: "b"}))
+
+	assert.Len(readLines(t, dir, FileStoreLog), 3)
+	assert.Equal([]string{""}, readLines(t, dir, FileStoreSnapshot))
+
+	require.NoError(fs.Compact())
+	assert.Equal([]string{""}, readLines(t, dir, FileStoreLog))
+	snapshot := readLines(t, dir, FileStoreSnapshot)
+	require.Len(snapshot, 1)
+	assert.Contains(snapshot[0], `"version":2,"id":"a"`)
+
+	// Reopening without closing, as after a crash, replays the log.
+	require.NoError(fs.Upsert(ctx, &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "c"}, Duration: CustomDuration(time.Minute)}))
+	reopened, err := OpenFileStore(dir)
+	require.NoError(err)
+	defer reopened.Close()
+
+	got, err := reopened.Get(ctx, RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(err)
+	assert.True(expires.Equal(got.(*RegistrationV2).Expires.Time))
+	got, err = reopened.Get(ctx, RegistrationKey{Version: 1, ID: "c"})
+	require.NoError(err)
+	assert.Equal(CustomDuration(time.Minute), got.(*RegistrationV1).Duration)
+
+	require.NoError(fs.Close())
+	require.NoError(fs.Close())
+	assert.ErrorIs(fs.Upsert(ctx, &RegistrationV2{CanonicalName: "d"}), os.ErrClosed)
+}
+
+func TestFileStoreLoad(t *testing.T) {
+	tests := []struct {
+		description string
+		snapshot    string
+		log         string
+		expected    []RegistrationKey
+		expectErr   bool
+	}{
+		{
+			description: "empty files",
+		}, {
+			description: "incomplete last line is ignored",
+			snapshot:    `{"version":2,"id":"a","registration":{"canonical_name":"a"}}` + "\n",
+			log:         `{"op":"upsert","version":2,"id":"b","registration":{"canonical_name":"b"}}` + "\n" + `{"op":"delete","vers`,
+			expected:    []RegistrationKey{{Version: 2, ID: "a"}, {Version: 2, ID: "b"}},
+		}, {
+			description: "last line without a newline is applied",
+			snapshot:    `{"version":2,"id":"a","registration":{"canonical_name":"a"}}`,
+			log:         `{"op":"upsert","version":2,"id":"b","registration":{"canonical_name":"b"}}` + "\n" + `{"op":"delete","version":2,"id":"a"}`,
+			expected:    []RegistrationKey{{Version: 2, ID: "b"}},
+		}, {
+			description: "invalid line before the last one",
+			log:         `{"op":"delete","vers` + "\n" + `{"op":"delete","version":2,"id":"a"}`,
+			expectErr:   true,
+		}, {
+			description: "blank lines are skipped",
+			snapshot:    "\n" + `{"version":1,"registration":{"config":{"url":"a"}}}` + "\n\n",
+			expected:    []RegistrationKey{{Version: 1, ID: "a"}},
+		}, {
+			description: "delete",
+			snapshot:    `{"version":2,"id":"a","registration":{"canonical_name":"a"}}` + "\n",
+			log:         `{"op":"delete","version":2,"id":"a"}` + "\n",
+		}, {
+			description: "invalid json",
+			snapshot:    "{\n",
+			expectErr:   true,
+		}, {
+			description: "unknown version",
+			snapshot:    `{"version":3,"registration":{}}` + "\n",
+			expectErr:   true,
+		}, {
+			description: "unknown operation",
+			log:         `{"op":"rename","version":2,"id":"a"}` + "\n",
+			expectErr:   true,
+		}, {
+			description: "missing key",
+			log:         `{"op":"upsert","version":2,"registration":{}}` + "\n",
+			expectErr:   true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, FileStoreSnapshot), []byte(tc.snapshot), 0o600))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, FileStoreLog), []byte(tc.log), 0o600))
+
+			fs, err := OpenFileStore(dir)
+			if tc.expectErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer fs.Close()
+
+			list, err := fs.List(context.Background())
+			require.NoError(t, err)
+			var got []RegistrationKey
+			for _, r := range list {
+				key, err := KeyOf(r)
+				require.NoError(t, err)
+				got = append(got, key)
+			}
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
@@ -5,10 +5,10 @@ go 1.20
 require (
 	github.com/stretchr/testify v1.11.1
 	github.com/xmidt-org/urlegit v0.1.29
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
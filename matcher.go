@@ -0,0 +1,253 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// fieldValues returns the values of a field of a message.  A field without
+// any values never matches.
+type fieldValues func(*Message) []string
+
+func one(s string) []string {
+	return []string{s}
+}
+
+func optionalInt(i *int64) []string {
+	if i == nil {
+		return nil
+	}
+	return one(strconv.FormatInt(*i, 10))
+}
+
+// messageFields maps the names usable in FieldRegex.Field to the values of
+// the message they refer to.
+var messageFields = map[string]fieldValues{
+	FieldEvent: func(m *Message) []string {
+		if event, found := strings.CutPrefix(m.Destination, "event:"); found {
+			return one(event)
+		}
+		return nil
+	},
+	FieldDeviceID: func(m *Message) []string {
+		id, _, _ := strings.Cut(m.Source, "/")
+		return one(id)
+	},
+	"msg_type":         func(m *Message) []string { return one(strconv.Itoa(m.Type)) },
+	"source":           func(m *Message) []string { return one(m.Source) },
+	"dest":             func(m *Message) []string { return one(m.Destination) },
+	"transaction_uuid": func(m *Message) []string { return one(m.TransactionUUID) },
+	"content_type":     func(m *Message) []string { return one(m.ContentType) },
+	"accept":           func(m *Message) []string { return one(m.Accept) },
+	"status":           func(m *Message) []string { return optionalInt(m.Status) },
+	"rdr":              func(m *Message) []string { return optionalInt(m.RequestDeliveryResponse) },
+	"headers":          func(m *Message) []string { return m.Headers },
+	"path":             func(m *Message) []string { return one(m.Path) },
+	"service_name":     func(m *Message) []string { return one(m.ServiceName) },
+	"url":              func(m *Message) []string { return one(m.URL) },
+	"partner_ids":      func(m *Message) []string { return m.PartnerIDs },
+	"session_id":       func(m *Message) []string { return one(m.SessionID) },
+	"qos":              func(m *Message) []string { return one(strconv.Itoa(m.QualityOfService)) },
+}
+
+// lookupField returns the function that provides the values for the named
+// field, or nil if the field is not known.
+func lookupField(name string) fieldValues {
+	if key, found := strings.CutPrefix(name, FieldMetadataPrefix); found {
+		return func(m *Message) []string {
+			if v, ok := m.Metadata[key]; ok {
+				return one(v)
+			}
+			return nil
+		}
+	}
+	return messageFields[name]
+}
+
+// unknownField returns the error for the RegistrationV2 matcher at index i
+// referring to a field that does not exist.
+func unknownField(i int, field string) error {
+	return &ValidationError{
+		Err:     ErrInvalidInput,
+		Code:    CodeUnknownField,
+		Path:    fmt.Sprintf("matcher[%d].field", i),
+		Value:   field,
+		Message: "unknown field",
+	}
+}
+
+// fieldMatcher is the group of compiled regular expressions for one field.
+type fieldMatcher struct {
+	field   string
+	values  fieldValues
+	regexes []*regexp.Regexp
+}
+
+// match returns true if any of the regular expressions match any of the
+// values of the field.
+func (fm *fieldMatcher) match(msg *Message) bool {
+	for _, v := range fm.values(msg) {
+		for _, re := range fm.regexes {
+			if re.MatchString(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Matcher is a compiled set of matchers from a registration that can be used
+// to test if a Message should be delivered to the registration.
+//
+// The matchers are evaluated as follows:
+//
+//   - Matchers for the same field match if any of them match.
+//   - Matchers for different fields must all match.
+//   - A message field that has no value, such as an unset status, a missing
+//     metadata key or the event of a message that is not an event, is not
+//     matched by any regular expression.
+//   - Fields with more than one value, such as headers and partner_ids,
+//     match if any value matches.
+//   - Regular expressions are not anchored, use `^` and `$` to match the
+//     whole value.
+//
+// A RegistrationV2 without matchers matches every message, while a
+// RegistrationV1 without events matches no message.
+type Matcher struct {
+	fields  []*fieldMatcher
+	nothing bool
+}
+
+// NewMatcher compiles the matchers of a *RegistrationV1 or *RegistrationV2.
+// The RegistrationV1 Events are matched against FieldEvent and the
+// Matcher.DeviceID values against FieldDeviceID.  The RegistrationV2 Matcher
+// fields can be any of FieldEvent, FieldDeviceID, a FieldMetadataPrefix
+// prefixed metadata key or one of the wrp message fields: msg_type, source,
+// dest, transaction_uuid, content_type, accept, status, rdr, headers, path,
+// service_name, url, partner_ids, session_id or qos.
+//
+// An unknown field or a regular expression that does not compile results in
+// an error wrapping ErrInvalidInput.
+func NewMatcher(r Registration) (*Matcher, error) {
+	return compileMatcher(r, regexp.Compile)
+}
+
+// compileMatcher is NewMatcher with the function used to compile the regular
+// expressions.
+func compileMatcher(r Registration, compile func(string) (*regexp.Regexp, error)) (*Matcher, error) {
+	var (
+		list  []FieldRegex
+		paths func(int) string
+	)
+
+	switch r := r.(type) {
+	case *RegistrationV1:
+		if len(r.Events) == 0 {
+			return &Matcher{nothing: true}, nil
+		}
+		list = r.fieldRegexes()
+		paths = func(i int) string {
+			if i < len(r.Events) {
+				return fmt.Sprintf("events[%d]", i)
+			}
+			return fmt.Sprintf("matcher.device_id[%d]", i-len(r.Events))
+		}
+	case *RegistrationV2:
+		list = r.Matcher
+		paths = func(i int) string {
+			return fmt.Sprintf("matcher[%d].regex", i)
+		}
+	default:
+		return nil, ErrUknownType
+	}
+
+	var (
+		m    Matcher
+		errs error
+	)
+	byField := map[string]*fieldMatcher{}
+	for i, fr := range list {
+		values := lookupField(fr.Field)
+		if values == nil {
+			errs = errors.Join(errs, unknownField(i, fr.Field))
+			continue
+		}
+
+		re, err := compile(fr.Regex)
+		if err != nil {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidRegex,
+				Path:    paths(i),
+				Value:   fr.Regex,
+				Message: "unable to compile matching",
+				Cause:   err,
+			})
+			continue
+		}
+
+		fm, ok := byField[fr.Field]
+		if !ok {
+			fm = &fieldMatcher{field: fr.Field, values: values}
+			byField[fr.Field] = fm
+			m.fields = append(m.fields, fm)
+		}
+		fm.regexes = append(fm.regexes, re)
+	}
+
+	if errs != nil {
+		return nil, errs
+	}
+
+	return &m, nil
+}
+
+// Match returns true if the message matches the registration the Matcher was
+// created from.
+func (m *Matcher) Match(msg *Message) bool {
+	if m.nothing || msg == nil {
+		return false
+	}
+
+	for _, fm := range m.fields {
+		if !fm.match(msg) {
+			return false
+		}
+	}
+	return true
+}
+
+// KnownMatcherFields ensures that all of the RegistrationV2 Matcher fields
+// refer to fields that NewMatcher knows how to match against.
+func KnownMatcherFields() Option {
+	return knownMatcherFieldsOption{}
+}
+
+type knownMatcherFieldsOption struct{}
+
+func (knownMatcherFieldsOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have matcher fields to validate")
+	case *RegistrationV2:
+		var errs error
+		for i, fr := range r.Matcher {
+			if lookupField(fr.Field) == nil {
+				errs = errors.Join(errs, unknownField(i, fr.Field))
+			}
+		}
+		return errs
+	default:
+		return ErrUknownType
+	}
+}
+
+func (knownMatcherFieldsOption) String() string {
+	return "KnownMatcherFields()"
+}
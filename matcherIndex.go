@@ -0,0 +1,760 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxLiterals is the most alternative literals kept for a regular
+	// expression, such as the prefixes of ^(online|offline).
+	maxLiterals = 16
+
+	// maxSubstring is the longest required substring kept for a regular
+	// expression.  A value is checked for every substring length in use, so
+	// the lengths are kept few.
+	maxSubstring = 8
+)
+
+// indexProgram is a compiled regular expression shared by every registration
+// of a MatcherIndex that uses it, with the literals that prefilter it.
+type indexProgram struct {
+	re *regexp.Regexp
+
+	// prefixes holds the literals one of which begins every value the
+	// regular expression matches, or nil if it is not anchored or has no
+	// literal prefix.
+	prefixes []string
+
+	// substrings holds the literals one of which is in every value the
+	// regular expression matches, or nil if there are none.
+	substrings []string
+
+	refs int
+}
+
+// newIndexProgram compiles the regular expression and extracts its literals.
+func newIndexProgram(expr string) (*indexProgram, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := indexProgram{re: re}
+
+	// regexp.Compile uses the same flags, so a parse error cannot happen.
+	parsed, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return &p, nil
+	}
+	parsed = parsed.Simplify()
+
+	p.prefixes = anchoredPrefixes(parsed)
+	if p.prefixes == nil {
+		for _, s := range requiredLiterals(parsed) {
+			p.substrings = append(p.substrings, truncate(s, maxSubstring))
+		}
+		p.substrings = usable(p.substrings)
+	}
+	return &p, nil
+}
+
+// score ranks how well the literals of the program narrow down the values to
+// run it against: prefixes before substrings before nothing, and longer
+// literals before shorter ones.
+func (p *indexProgram) score() int {
+	switch {
+	case p.prefixes != nil:
+		return 1000 + shortest(p.prefixes)
+	case p.substrings != nil:
+		return shortest(p.substrings)
+	}
+	return 0
+}
+
+// anchoredPrefixes returns the literal prefixes of a regular expression that
+// is anchored at the beginning of the text, or nil.
+func anchoredPrefixes(re *syntax.Regexp) []string {
+	for re.Op == syntax.OpCapture {
+		re = re.Sub[0]
+	}
+	if re.Op != syntax.OpConcat || re.Sub[0].Op != syntax.OpBeginText {
+		return nil
+	}
+	set, _ := concatLiterals(re.Sub[1:])
+	return usable(set)
+}
+
+// requiredLiterals returns the literals one of which is in every match of the
+// regular expression, or nil if there are none.
+func requiredLiterals(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		set, _ := literalSet(re)
+		return set
+	case syntax.OpCapture, syntax.OpPlus:
+		return requiredLiterals(re.Sub[0])
+	case syntax.OpAlternate:
+		var set []string
+		for _, sub := range re.Sub {
+			lits := usable(requiredLiterals(sub))
+			if lits == nil || len(set)+len(lits) > maxLiterals {
+				return nil
+			}
+			set = append(set, lits...)
+		}
+		return set
+	case syntax.OpConcat:
+		var best []string
+		for i, sub := range re.Sub {
+			for _, set := range [][]string{requiredLiterals(sub), first(concatLiterals(re.Sub[i:]))} {
+				if set = usable(set); better(set, best) {
+					best = set
+				}
+			}
+		}
+		return best
+	}
+	return nil
+}
+
+// concatLiterals returns the literals one of which begins every match of the
+// concatenation of the regular expressions.  The bool is true if the matches
+// are exactly the literals.
+func concatLiterals(subs []*syntax.Regexp) ([]string, bool) {
+	set := []string{""}
+	for _, sub := range subs {
+		next, exact := literalSet(sub)
+		if next == nil || len(set)*len(next) > maxLiterals {
+			return set, false
+		}
+
+		joined := make([]string, 0, len(set)*len(next))
+		for _, s := range set {
+			for _, n := range next {
+				joined = append(joined, s+n)
+			}
+		}
+		set = joined
+		if !exact {
+			return set, false
+		}
+	}
+	return set, true
+}
+
+// literalSet returns the literals one of which begins every match of the
+// regular expression, or nil if it is not known.  The bool is true if the
+// matches are exactly the literals.
+func literalSet(re *syntax.Regexp) ([]string, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return []string{""}, true
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return nil, false
+		}
+		return []string{string(re.Rune)}, true
+	case syntax.OpCharClass:
+		var set []string
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			for r := re.Rune[i]; r <= re.Rune[i+1]; r++ {
+				if len(set) == 4 {
+					return nil, false
+				}
+				set = append(set, string(r))
+			}
+		}
+		return set, true
+	case syntax.OpCapture:
+		return literalSet(re.Sub[0])
+	case syntax.OpConcat:
+		return concatLiterals(re.Sub)
+	case syntax.OpQuest:
+		set, exact := literalSet(re.Sub[0])
+		if set == nil {
+			return nil, false
+		}
+		return append([]string{""}, set...), exact
+	case syntax.OpPlus:
+		set, _ := literalSet(re.Sub[0])
+		return set, false
+	case syntax.OpAlternate:
+		var set []string
+		all := true
+		for _, sub := range re.Sub {
+			lits, exact := literalSet(sub)
+			if lits == nil || len(set)+len(lits) > maxLiterals {
+				return nil, false
+			}
+			set = append(set, lits...)
+			all = all && exact
+		}
+		return set, all
+	}
+	return nil, false
+}
+
+// usable returns the distinct literals, or nil if one is empty and so does
+// not narrow anything down.
+func usable(set []string) []string {
+	if len(set) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(set))
+	list := make([]string, 0, len(set))
+	for _, s := range set {
+		if s == "" {
+			return nil
+		}
+		if !seen[s] {
+			seen[s] = true
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// better returns true if the literals narrow down more than the best so far:
+// a longer shortest literal, then fewer literals.
+func better(set, best []string) bool {
+	if set == nil {
+		return false
+	}
+	if best == nil {
+		return true
+	}
+	if a, b := shortest(set), shortest(best); a != b {
+		return a > b
+	}
+	return len(set) < len(best)
+}
+
+func shortest(set []string) int {
+	n := len(set[0])
+	for _, s := range set[1:] {
+		if len(s) < n {
+			n = len(s)
+		}
+	}
+	return n
+}
+
+func first(set []string, _ bool) []string {
+	return set
+}
+
+// truncate shortens the string to at most n bytes without splitting a rune.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// indexPosting is a program with the registrations whose anchor field
+// matches only if the program does.
+type indexPosting struct {
+	program *indexProgram
+	entries map[*indexEntry]struct{}
+}
+
+// literalTable files postings under literals and finds the ones filed under
+// the prefixes or substrings of a value.
+type literalTable struct {
+	postings map[string][]*indexPosting
+	counts   map[int]int
+
+	// lens is the sorted lengths of the literals.
+	lens []int
+}
+
+func newLiteralTable() literalTable {
+	return literalTable{
+		postings: make(map[string][]*indexPosting),
+		counts:   make(map[int]int),
+	}
+}
+
+func (lt *literalTable) add(lit string, p *indexPosting) {
+	if _, found := lt.postings[lit]; !found {
+		if lt.counts[len(lit)]++; lt.counts[len(lit)] == 1 {
+			i := sort.SearchInts(lt.lens, len(lit))
+			lt.lens = append(lt.lens[:i], append([]int{len(lit)}, lt.lens[i:]...)...)
+		}
+	}
+	lt.postings[lit] = append(lt.postings[lit], p)
+}
+
+func (lt *literalTable) remove(lit string, p *indexPosting) {
+	list := lt.postings[lit]
+	for i := range list {
+		if list[i] == p {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) > 0 {
+		lt.postings[lit] = list
+		return
+	}
+
+	delete(lt.postings, lit)
+	if lt.counts[len(lit)]--; lt.counts[len(lit)] == 0 {
+		delete(lt.counts, len(lit))
+		i := sort.SearchInts(lt.lens, len(lit))
+		lt.lens = append(lt.lens[:i], lt.lens[i+1:]...)
+	}
+}
+
+// prefixes calls fn with the postings filed under the prefixes of the value.
+func (lt *literalTable) prefixes(v string, fn func(*indexPosting)) {
+	for _, n := range lt.lens {
+		if n > len(v) {
+			return
+		}
+		for _, p := range lt.postings[v[:n]] {
+			fn(p)
+		}
+	}
+}
+
+// substrings calls fn with the postings filed under the substrings of the
+// value.
+func (lt *literalTable) substrings(v string, fn func(*indexPosting)) {
+	for _, n := range lt.lens {
+		for i := 0; i+n <= len(v); i++ {
+			for _, p := range lt.postings[v[i:i+n]] {
+				fn(p)
+			}
+		}
+	}
+}
+
+// fieldIndex holds the postings of the programs used by the anchor fields
+// with the same name.
+type fieldIndex struct {
+	values     fieldValues
+	postings   map[*indexProgram]*indexPosting
+	prefixes   literalTable
+	substrings literalTable
+
+	// scan holds the postings without literals, which are run against every
+	// value.
+	scan map[*indexPosting]struct{}
+}
+
+func newFieldIndex(values fieldValues) *fieldIndex {
+	return &fieldIndex{
+		values:     values,
+		postings:   make(map[*indexProgram]*indexPosting),
+		prefixes:   newLiteralTable(),
+		substrings: newLiteralTable(),
+		scan:       make(map[*indexPosting]struct{}),
+	}
+}
+
+func (fi *fieldIndex) add(p *indexProgram, e *indexEntry) {
+	posting, found := fi.postings[p]
+	if !found {
+		posting = &indexPosting{program: p, entries: make(map[*indexEntry]struct{})}
+		fi.postings[p] = posting
+		switch {
+		case p.prefixes != nil:
+			for _, lit := range p.prefixes {
+				fi.prefixes.add(lit, posting)
+			}
+		case p.substrings != nil:
+			for _, lit := range p.substrings {
+				fi.substrings.add(lit, posting)
+			}
+		default:
+			fi.scan[posting] = struct{}{}
+		}
+	}
+	posting.entries[e] = struct{}{}
+}
+
+func (fi *fieldIndex) remove(p *indexProgram, e *indexEntry) {
+	// A regular expression repeated in a field is removed more than once.
+	posting, found := fi.postings[p]
+	if !found {
+		return
+	}
+	delete(posting.entries, e)
+	if len(posting.entries) > 0 {
+		return
+	}
+
+	delete(fi.postings, p)
+	switch {
+	case p.prefixes != nil:
+		for _, lit := range p.prefixes {
+			fi.prefixes.remove(lit, posting)
+		}
+	case p.substrings != nil:
+		for _, lit := range p.substrings {
+			fi.substrings.remove(lit, posting)
+		}
+	default:
+		delete(fi.scan, posting)
+	}
+}
+
+// candidates calls fn with the postings that may match the value.  A value
+// that is not valid UTF-8 may match every posting, since the literals are
+// compared byte by byte but the regular expressions read each invalid byte
+// as U+FFFD.
+func (fi *fieldIndex) candidates(v string, fn func(*indexPosting)) {
+	if !utf8.ValidString(v) {
+		for _, p := range fi.postings {
+			fn(p)
+		}
+		return
+	}
+
+	fi.prefixes.prefixes(v, fn)
+	fi.substrings.substrings(v, fn)
+	for p := range fi.scan {
+		fn(p)
+	}
+}
+
+// indexEntry is a registration held by a MatcherIndex.
+type indexEntry struct {
+	key          RegistrationKey
+	registration Registration
+	matcher      *Matcher
+
+	// anchor is the field the registration is filed under, or nil if the
+	// registration matches every message or none.
+	anchor *fieldMatcher
+}
+
+// indexLookup is the state of matching one message, so each field is read
+// and each program is run against each value only once.
+type indexLookup struct {
+	msg     *Message
+	values  map[string][]string
+	results map[indexResult]bool
+}
+
+type indexResult struct {
+	re    *regexp.Regexp
+	value string
+}
+
+func (l *indexLookup) fieldValues(field string, values fieldValues) []string {
+	list, found := l.values[field]
+	if !found {
+		list = values(l.msg)
+		l.values[field] = list
+	}
+	return list
+}
+
+func (l *indexLookup) match(re *regexp.Regexp, v string) bool {
+	key := indexResult{re: re, value: v}
+	matched, found := l.results[key]
+	if !found {
+		matched = re.MatchString(v)
+		l.results[key] = matched
+	}
+	return matched
+}
+
+// matchField is fieldMatcher.match using the values and results of the
+// lookup.
+func (l *indexLookup) matchField(fm *fieldMatcher) bool {
+	for _, v := range l.fieldValues(fm.field, fm.values) {
+		for _, re := range fm.regexes {
+			if l.match(re, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MatcherIndex finds the registrations that match a message without running
+// the matchers of every registration.  It matches exactly as a Matcher
+// created from each registration would.
+//
+// Each registration is filed under one of its matcher fields, its anchor,
+// which must match for the registration to match.  The anchor is the field
+// whose regular expressions have the longest literals: a literal prefix of
+// an anchored regular expression such as ^mac:112233445566$, or a literal
+// substring every match contains.  A message value only runs the regular
+// expressions filed under its own prefixes and substrings, and the ones
+// without literals, while a value that is not valid UTF-8 runs all of them.
+// The other fields of a registration are only checked once its anchor
+// matches.  Compiled regular expressions are shared by the registrations
+// that use the same one.
+//
+// A MatcherIndex is safe for concurrent use.  The registrations it holds must
+// not be modified.
+type MatcherIndex struct {
+	mu       sync.RWMutex
+	programs map[string]*indexProgram
+	fields   map[string]*fieldIndex
+	entries  map[RegistrationKey]*indexEntry
+
+	// always holds the registrations that match every message.
+	always map[*indexEntry]struct{}
+}
+
+// NewMatcherIndex creates a MatcherIndex holding the registrations.  The
+// errors of registrations that cannot be added are returned together, and
+// the other registrations are added.
+func NewMatcherIndex(regs ...Registration) (*MatcherIndex, error) {
+	ix := MatcherIndex{
+		programs: make(map[string]*indexProgram),
+		fields:   make(map[string]*fieldIndex),
+		entries:  make(map[RegistrationKey]*indexEntry),
+		always:   make(map[*indexEntry]struct{}),
+	}
+
+	var errs error
+	for _, r := range regs {
+		if err := ix.Add(r); err != nil {
+			key, _ := KeyOf(r)
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return &ix, errs
+}
+
+// Add adds the registration, replacing any registration with the same key.
+// The registration is compiled as NewMatcher does, and an error is returned
+// if it cannot be.
+func (ix *MatcherIndex) Add(r Registration) error {
+	key, err := KeyOf(r)
+	if err != nil {
+		return err
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	var used []*indexProgram
+	m, err := compileMatcher(r, func(expr string) (*regexp.Regexp, error) {
+		p, err := ix.program(expr)
+		if err != nil {
+			return nil, err
+		}
+		used = append(used, p)
+		return p.re, nil
+	})
+	if err != nil {
+		for _, p := range used {
+			ix.release(p)
+		}
+		return err
+	}
+
+	if prev, found := ix.entries[key]; found {
+		ix.remove(prev)
+	}
+
+	e := &indexEntry{
+		key:          key,
+		registration: r,
+		matcher:      m,
+	}
+	ix.entries[key] = e
+
+	switch {
+	case m.nothing:
+	case len(m.fields) == 0:
+		ix.always[e] = struct{}{}
+	default:
+		e.anchor = ix.anchor(m)
+		fi, found := ix.fields[e.anchor.field]
+		if !found {
+			fi = newFieldIndex(e.anchor.values)
+			ix.fields[e.anchor.field] = fi
+		}
+		for _, re := range e.anchor.regexes {
+			fi.add(ix.programs[re.String()], e)
+		}
+	}
+	return nil
+}
+
+// Remove removes the registration with the key.  It returns false if there is
+// no registration with the key.
+func (ix *MatcherIndex) Remove(key RegistrationKey) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	e, found := ix.entries[key]
+	if found {
+		ix.remove(e)
+	}
+	return found
+}
+
+// Len returns the number of registrations in the MatcherIndex.
+func (ix *MatcherIndex) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	return len(ix.entries)
+}
+
+// Match returns the registrations that match the message, ordered by version
+// and then by ID.
+func (ix *MatcherIndex) Match(msg *Message) []Registration {
+	if msg == nil {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	l := indexLookup{
+		msg:     msg,
+		values:  make(map[string][]string),
+		results: make(map[indexResult]bool),
+	}
+
+	candidates := make(map[*indexEntry]struct{})
+	for field, fi := range ix.fields {
+		for _, v := range l.fieldValues(field, fi.values) {
+			fi.candidates(v, func(p *indexPosting) {
+				if l.match(p.program.re, v) {
+					for e := range p.entries {
+						candidates[e] = struct{}{}
+					}
+				}
+			})
+		}
+	}
+
+	matched := make([]*indexEntry, 0, len(candidates)+len(ix.always))
+	for e := range ix.always {
+		matched = append(matched, e)
+	}
+	for e := range candidates {
+		if l.matchEntry(e) {
+			matched = append(matched, e)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return keyLess(matched[i].key, matched[j].key)
+	})
+	list := make([]Registration, len(matched))
+	for i, e := range matched {
+		list[i] = e.registration
+	}
+	return list
+}
+
+// Follow keeps the MatcherIndex in step with the registrations of the
+// Registry until the returned cancel function is called.  The registrations
+// already in the Registry, and those added or updated later, are added and
+// expired and deleted ones are removed.  An updated registration that cannot
+// be added is removed, and the error is passed to onErr, if it is not nil.
+func (ix *MatcherIndex) Follow(r *Registry, onErr func(error)) (cancel func()) {
+	return r.Subscribe(func(e RegistryEvent) {
+		switch e.Type {
+		case RegistryAdded, RegistryUpdated:
+			if err := ix.Add(e.Registration); err != nil {
+				ix.Remove(e.Key)
+				if onErr != nil {
+					onErr(fmt.Errorf("%s: %w", e.Key, err))
+				}
+			}
+		case RegistryExpired, RegistryDeleted:
+			ix.Remove(e.Key)
+		}
+	}, ReplayExisting())
+}
+
+// matchEntry returns true if all of the fields of the entry match.
+func (l *indexLookup) matchEntry(e *indexEntry) bool {
+	for _, fm := range e.matcher.fields {
+		if fm != e.anchor && !l.matchField(fm) {
+			return false
+		}
+	}
+	return true
+}
+
+// anchor returns the field of the matcher to file the registration under.
+// mu must be held.
+func (ix *MatcherIndex) anchor(m *Matcher) *fieldMatcher {
+	var (
+		best      *fieldMatcher
+		bestScore int
+	)
+	for _, fm := range m.fields {
+		score := -1
+		for _, re := range fm.regexes {
+			if s := ix.programs[re.String()].score(); score < 0 || s < score {
+				score = s
+			}
+		}
+		if best == nil || score > bestScore {
+			best, bestScore = fm, score
+		}
+	}
+	return best
+}
+
+// program returns the cached program of the regular expression, compiling
+// it if needed, and holds a reference to it.  mu must be held.
+func (ix *MatcherIndex) program(expr string) (*indexProgram, error) {
+	p, found := ix.programs[expr]
+	if !found {
+		var err error
+		if p, err = newIndexProgram(expr); err != nil {
+			return nil, err
+		}
+		ix.programs[expr] = p
+	}
+	p.refs++
+	return p, nil
+}
+
+// release drops a reference to the program, removing it from the cache when
+// it is no longer used.  mu must be held.
+func (ix *MatcherIndex) release(p *indexProgram) {
+	if p.refs--; p.refs == 0 {
+		delete(ix.programs, p.re.String())
+	}
+}
+
+// remove removes the entry.  mu must be held.
+func (ix *MatcherIndex) remove(e *indexEntry) {
+	delete(ix.entries, e.key)
+	delete(ix.always, e)
+
+	if e.anchor != nil {
+		fi := ix.fields[e.anchor.field]
+		for _, re := range e.anchor.regexes {
+			fi.remove(ix.programs[re.String()], e)
+		}
+		if len(fi.postings) == 0 {
+			delete(ix.fields, e.anchor.field)
+		}
+	}
+
+	for _, fm := range e.matcher.fields {
+		for _, re := range fm.regexes {
+			ix.release(ix.programs[re.String()])
+		}
+	}
+}
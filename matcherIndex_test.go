@@ -0,0 +1,327 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIndexProgram(t *testing.T) {
+	tests := []struct {
+		expr       string
+		prefixes   []string
+		substrings []string
+	}{
+		{expr: "^mac:112233445566$", prefixes: []string{"mac:112233445566"}},
+		{expr: `\Adevice-status`, prefixes: []string{"device-status"}},
+		{expr: "^(online|offline)$", prefixes: []string{"online", "offline"}},
+		{expr: "^event:device-status/.*/online", prefixes: []string{"event:device-status/"}},
+		{expr: "^[ab]x", prefixes: []string{"ax", "bx"}},
+		{expr: "^a?b", prefixes: []string{"b", "ab"}},
+		{expr: "^.*online", substrings: []string{"online"}},
+		{expr: "device-status", substrings: []string{"device-s"}},
+		{expr: "a+bcd", substrings: []string{"bcd"}},
+		{expr: "x.*(online|offline)", substrings: []string{"online", "offline"}},
+		{expr: "(?i)^mac:"},
+		{expr: "(?m)^x", substrings: []string{"x"}},
+		{expr: "[0-9]+"},
+		{expr: ".*"},
+		{expr: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.expr, func(t *testing.T) {
+			assert := assert.New(t)
+
+			p, err := newIndexProgram(tc.expr)
+			require.NoError(t, err)
+			assert.ElementsMatch(tc.prefixes, p.prefixes)
+			assert.ElementsMatch(tc.substrings, p.substrings)
+		})
+	}
+
+	_, err := newIndexProgram("(")
+	assert.Error(t, err)
+}
+
+func TestMatcherIndex(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	online := &Message{
+		Source:      "mac:112233445566/service",
+		Destination: "event:device-status/mac:112233445566/online",
+		PartnerIDs:  []string{"comcast", "sky"},
+	}
+	offline := &Message{
+		Source:      "mac:665544332211/service",
+		Destination: "event:device-status/mac:665544332211/offline",
+		PartnerIDs:  []string{"comcast"},
+	}
+
+	status := &RegistrationV1{
+		Config: DeliveryConfig{ReceiverURL: "status"},
+		Events: []string{"^device-status"},
+	}
+	device := &RegistrationV1{
+		Config:  DeliveryConfig{ReceiverURL: "device"},
+		Events:  []string{".*"},
+		Matcher: MetadataMatcherConfig{DeviceID: []string{"^mac:112233445566$"}},
+	}
+	nothing := &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "nothing"}}
+	all := &RegistrationV2{CanonicalName: "all"}
+	sky := &RegistrationV2{
+		CanonicalName: "sky",
+		Matcher: []FieldRegex{
+			{Field: "partner_ids", Regex: "^sky$"},
+			{Field: "dest", Regex: "offline"},
+			{Field: "dest", Regex: "online"},
+		},
+	}
+
+	ix, err := NewMatcherIndex(status, device, nothing, all, sky)
+	require.NoError(err)
+	assert.Equal(5, ix.Len())
+
+	assert.Equal([]Registration{device, status, all, sky}, ix.Match(online))
+	assert.Equal([]Registration{status, all}, ix.Match(offline))
+	assert.Equal([]Registration{all}, ix.Match(&Message{}))
+	assert.Nil(ix.Match(nil))
+
+	// Replacing a registration files it under its new matchers.
+	moved := &RegistrationV1{
+		Config:  DeliveryConfig{ReceiverURL: "device"},
+		Events:  []string{".*"},
+		Matcher: MetadataMatcherConfig{DeviceID: []string{"^mac:665544332211$"}},
+	}
+	require.NoError(ix.Add(moved))
+	assert.Equal(5, ix.Len())
+	assert.Equal([]Registration{status, all, sky}, ix.Match(online))
+	assert.Equal([]Registration{moved, status, all}, ix.Match(offline))
+
+	assert.True(ix.Remove(RegistrationKey{Version: 2, ID: "all"}))
+	assert.True(ix.Remove(RegistrationKey{Version: 1, ID: "status"}))
+	assert.False(ix.Remove(RegistrationKey{Version: 1, ID: "status"}))
+	assert.Equal([]Registration{sky}, ix.Match(online))
+
+	// A registration that does not compile is rejected, and the one it
+	// would replace is kept.
+	err = ix.Add(&RegistrationV2{
+		CanonicalName: "sky",
+		Matcher:       []FieldRegex{{Field: "dest", Regex: "("}},
+	})
+	assert.ErrorIs(err, ErrInvalidInput)
+	assert.Equal([]Registration{sky}, ix.Match(online))
+
+	// Removing every registration empties the caches.
+	for _, key := range []string{"device", "nothing"} {
+		assert.True(ix.Remove(RegistrationKey{Version: 1, ID: key}))
+	}
+	assert.True(ix.Remove(RegistrationKey{Version: 2, ID: "sky"}))
+	assert.Zero(ix.Len())
+	assert.Empty(ix.programs)
+	assert.Empty(ix.fields)
+}
+
+func TestNewMatcherIndexErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	ok := &RegistrationV2{CanonicalName: "ok"}
+	ix, err := NewMatcherIndex(
+		ok,
+		&RegistrationV2{CanonicalName: "bad", Matcher: []FieldRegex{{Field: "nope", Regex: "x"}}},
+		&RegistrationV1{},
+	)
+	assert.ErrorIs(err, ErrInvalidInput)
+	assert.ErrorContains(err, "v2:bad")
+	assert.Equal([]Registration{ok}, ix.Match(&Message{}))
+}
+
+func TestMatcherIndexFollow(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var errs []error
+	r := NewRegistry(nil, newFakeClock())
+	ix, err := NewMatcherIndex()
+	require.NoError(err)
+
+	// The registrations added before Follow is called are added too.
+	existing := &RegistrationV2{CanonicalName: "existing"}
+	_, err = r.Upsert(existing)
+	require.NoError(err)
+	cancel := ix.Follow(r, func(err error) { errs = append(errs, err) })
+	assert.Equal([]Registration{existing}, ix.Match(&Message{}))
+	assert.True(r.Delete(RegistrationKey{Version: 2, ID: "existing"}))
+
+	reg := &RegistrationV2{
+		CanonicalName: "a",
+		Matcher:       []FieldRegex{{Field: FieldEvent, Regex: "^online$"}},
+	}
+	key, err := r.Upsert(reg)
+	require.NoError(err)
+	assert.Equal([]Registration{reg}, ix.Match(&Message{Destination: "event:online"}))
+
+	// The Registry does not check the matchers without KnownMatcherFields.
+	_, err = r.Upsert(&RegistrationV2{
+		CanonicalName: "a",
+		Matcher:       []FieldRegex{{Field: "nope", Regex: "x"}},
+	})
+	require.NoError(err)
+	assert.Zero(ix.Len())
+	require.Len(errs, 1)
+	assert.ErrorIs(errs[0], ErrInvalidInput)
+
+	_, err = r.Upsert(reg)
+	require.NoError(err)
+	assert.True(r.Delete(key))
+	assert.Zero(ix.Len())
+
+	cancel()
+	_, err = r.Upsert(reg)
+	require.NoError(err)
+	assert.Zero(ix.Len())
+}
+
+func TestMatcherIndexInvalidUTF8(t *testing.T) {
+	regs := []Registration{
+		&RegistrationV2{
+			CanonicalName: "prefix",
+			Matcher:       []FieldRegex{{Field: "dest", Regex: `^\x{FFFD}abc`}},
+		},
+		&RegistrationV2{
+			CanonicalName: "substring",
+			Matcher:       []FieldRegex{{Field: "dest", Regex: `x\x{FFFD}y`}},
+		},
+		&RegistrationV2{
+			CanonicalName: "valid",
+			Matcher:       []FieldRegex{{Field: "dest", Regex: `^abc`}},
+		},
+	}
+	ix, err := NewMatcherIndex(regs...)
+	require.NoError(t, err)
+
+	for _, dest := range []string{"\xffabc", "ax\xfeyz", "\ufffdabc", "abc\xff"} {
+		msg := &Message{Destination: dest}
+		var expected []Registration
+		for _, r := range regs {
+			m, err := NewMatcher(r)
+			require.NoError(t, err)
+			if m.Match(msg) {
+				expected = append(expected, r)
+			}
+		}
+		assert.NotEmpty(t, expected, "%q", dest)
+		assert.Equal(t, expected, ix.Match(msg), "%q", dest)
+	}
+}
+
+// fleet returns n registrations like the ones of a large deployment: most
+// follow a single device, some follow an event and a few look at other
+// fields.
+func fleet(n int) []Registration {
+	events := []string{"device-status", "node-change", "iot", "fully-manageable"}
+
+	regs := make([]Registration, 0, n)
+	for i := 0; i < n; i++ {
+		switch i % 10 {
+		case 0:
+			regs = append(regs, &RegistrationV1{
+				Config: DeliveryConfig{ReceiverURL: fmt.Sprintf("https://%d.example.com", i)},
+				Events: []string{"^" + events[i%len(events)] + "/"},
+			})
+		case 1:
+			regs = append(regs, &RegistrationV2{
+				CanonicalName: fmt.Sprintf("reg-%d", i),
+				Matcher: []FieldRegex{
+					{Field: "partner_ids", Regex: fmt.Sprintf("^partner-%d$", i%50)},
+					{Field: "dest", Regex: "online|offline"},
+				},
+			})
+		default:
+			regs = append(regs, &RegistrationV1{
+				Config:  DeliveryConfig{ReceiverURL: fmt.Sprintf("https://%d.example.com", i)},
+				Events:  []string{".*"},
+				Matcher: MetadataMatcherConfig{DeviceID: []string{fmt.Sprintf("^mac:%012x$", i)}},
+			})
+		}
+	}
+	return regs
+}
+
+// fleetMessage returns a message from the device of a fleet registration.
+func fleetMessage(rnd *rand.Rand, n int) *Message {
+	id := fmt.Sprintf("mac:%012x", rnd.Intn(n))
+	return &Message{
+		Source:      id + "/service",
+		Destination: "event:device-status/" + id + "/online",
+		PartnerIDs:  []string{fmt.Sprintf("partner-%d", rnd.Intn(60))},
+	}
+}
+
+func TestMatcherIndexMatchesMatcher(t *testing.T) {
+	const n = 1000
+
+	regs := fleet(n)
+	ix, err := NewMatcherIndex(regs...)
+	require.NoError(t, err)
+
+	matchers := make([]*Matcher, len(regs))
+	for i, r := range regs {
+		matchers[i], err = NewMatcher(r)
+		require.NoError(t, err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		msg := fleetMessage(rnd, n)
+
+		got := make(map[Registration]bool)
+		for _, r := range ix.Match(msg) {
+			got[r] = true
+		}
+		for j, m := range matchers {
+			require.Equal(t, m.Match(msg), got[regs[j]], "%+v %+v", msg, regs[j])
+		}
+	}
+}
+
+func BenchmarkMatch(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		regs := fleet(n)
+
+		b.Run(fmt.Sprintf("naive/%d", n), func(b *testing.B) {
+			matchers := make([]*Matcher, len(regs))
+			for i, r := range regs {
+				matchers[i], _ = NewMatcher(r)
+			}
+			rnd := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				msg := fleetMessage(rnd, n)
+				var list []Registration
+				for j, m := range matchers {
+					if m.Match(msg) {
+						list = append(list, regs[j])
+					}
+				}
+			}
+		})
+
+		b.Run(fmt.Sprintf("index/%d", n), func(b *testing.B) {
+			ix, _ := NewMatcherIndex(regs...)
+			rnd := rand.New(rand.NewSource(1))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				ix.Match(fleetMessage(rnd, n))
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatcher(t *testing.T) {
+	status := int64(200)
+	online := &Message{
+		Source:      "mac:112233445566/service",
+		Destination: "event:device-status/mac:112233445566/online",
+		ContentType: "application/json",
+		Status:      &status,
+		PartnerIDs:  []string{"comcast", "sky"},
+		Metadata:    map[string]string{"/hw-model": "xb7"},
+	}
+	request := &Message{
+		Type:        3,
+		Source:      "dns:talaria.example.com",
+		Destination: "mac:112233445566/config",
+	}
+
+	tests := []struct {
+		description string
+		in          Registration
+		matches     []*Message
+		misses      []*Message
+	}{
+		{
+			description: "v1 events only",
+			in:          &RegistrationV1{Events: []string{"device-status"}},
+			matches:     []*Message{online},
+			misses:      []*Message{request, {}, nil},
+		}, {
+			description: "v1 events and device ids",
+			in: &RegistrationV1{
+				Events:  []string{"iot", "device-status"},
+				Matcher: MetadataMatcherConfig{DeviceID: []string{"^mac:000000000000$", "^mac:112233445566$"}},
+			},
+			matches: []*Message{online},
+		}, {
+			description: "v1 device id does not match",
+			in: &RegistrationV1{
+				Events:  []string{".*"},
+				Matcher: MetadataMatcherConfig{DeviceID: []string{"^mac:000000000000$"}},
+			},
+			misses: []*Message{online},
+		}, {
+			description: "v1 without events matches nothing",
+			in:          &RegistrationV1{},
+			misses:      []*Message{online, request},
+		}, {
+			description: "v2 without matchers matches everything",
+			in:          &RegistrationV2{},
+			matches:     []*Message{online, request, {}},
+			misses:      []*Message{nil},
+		}, {
+			description: "v2 different fields must all match",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: "content_type", Regex: "json"},
+				{Field: "status", Regex: "^2"},
+				{Field: FieldMetadataPrefix + "/hw-model", Regex: "^xb"},
+			}},
+			matches: []*Message{online},
+			misses:  []*Message{request},
+		}, {
+			description: "v2 same field matches any",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: "dest", Regex: "^event:"},
+				{Field: "dest", Regex: "/config$"},
+			}},
+			matches: []*Message{online, request},
+		}, {
+			description: "v2 multi-valued field matches any value",
+			in:          &RegistrationV2{Matcher: []FieldRegex{{Field: "partner_ids", Regex: "^sky$"}}},
+			matches:     []*Message{online},
+			misses:      []*Message{request},
+		}, {
+			description: "v2 missing values never match",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: "rdr", Regex: ".*"},
+			}},
+			misses: []*Message{online, request},
+		}, {
+			description: "v2 missing metadata never matches",
+			in:          &RegistrationV2{Matcher: []FieldRegex{{Field: FieldMetadataPrefix + "missing", Regex: ".*"}}},
+			misses:      []*Message{online},
+		}, {
+			description: "v2 numeric fields",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: "msg_type", Regex: "^3$"},
+				{Field: "qos", Regex: "^0$"},
+			}},
+			matches: []*Message{request},
+			misses:  []*Message{online},
+		}, {
+			description: "v2 event and device id fields",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: FieldEvent, Regex: "^device-status/"},
+				{Field: FieldDeviceID, Regex: "^mac:112233445566$"},
+			}},
+			matches: []*Message{online},
+			misses:  []*Message{request},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			m, err := NewMatcher(tc.in)
+			require.NoError(t, err)
+			require.NotNil(t, m)
+
+			for _, msg := range tc.matches {
+				assert.True(t, m.Match(msg), "%+v", msg)
+			}
+			for _, msg := range tc.misses {
+				assert.False(t, m.Match(msg), "%+v", msg)
+			}
+		})
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	tests := []struct {
+		description string
+		in          Registration
+		expectedErr error
+		paths       []string
+	}{
+		{
+			description: "v1 invalid event regex",
+			in:          &RegistrationV1{Events: []string{".*", "("}},
+			expectedErr: ErrInvalidInput,
+			paths:       []string{"events[1]"},
+		}, {
+			description: "v1 invalid device id regex",
+			in: &RegistrationV1{
+				Events:  []string{".*"},
+				Matcher: MetadataMatcherConfig{DeviceID: []string{"mac:.*", "["}},
+			},
+			expectedErr: ErrInvalidInput,
+			paths:       []string{"matcher.device_id[1]"},
+		}, {
+			description: "v2 unknown field and invalid regex",
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: "canonical_name", Regex: "webpa"},
+				{Field: "source", Regex: "("},
+			}},
+			expectedErr: ErrInvalidInput,
+			paths:       []string{"matcher[0].field", "matcher[1].regex"},
+		}, {
+			description: "unknown registration type",
+			expectedErr: ErrUknownType,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			m, err := NewMatcher(tc.in)
+			assert.Nil(t, m)
+			assert.ErrorIs(t, err, tc.expectedErr)
+
+			var paths []string
+			for _, ve := range ValidationErrors(err) {
+				paths = append(paths, ve.Path)
+			}
+			assert.Equal(t, tc.paths, paths)
+		})
+	}
+}
+
+func TestKnownMatcherFields(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "known fields",
+			opt:         KnownMatcherFields(),
+			in: &RegistrationV2{Matcher: []FieldRegex{
+				{Field: FieldEvent, Regex: ".*"},
+				{Field: FieldMetadataPrefix + "/hw-model", Regex: ".*"},
+				{Field: "partner_ids", Regex: ".*"},
+			}},
+			str: "KnownMatcherFields()",
+		}, {
+			description: "unknown field",
+			opt:         KnownMatcherFields(),
+			in:          &RegistrationV2{Matcher: []FieldRegex{{Field: "address", Regex: ".*"}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         KnownMatcherFields(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         KnownMatcherFields(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
@@ -0,0 +1,62 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+// Message is the wrp message that registrations are matched against and that
+// is delivered to the sinks.  The fields and their json names mirror the
+// Message of github.com/xmidt-org/wrp-go so values can be copied across
+// directly.
+type Message struct {
+	// Type is the wrp message type.
+	Type int `json:"msg_type"`
+
+	// Source is the device or service that sent the message.
+	Source string `json:"source,omitempty"`
+
+	// Destination is where the message is addressed to.  For events this is
+	// `event:` followed by the event type.
+	Destination string `json:"dest,omitempty"`
+
+	// TransactionUUID is the id of the transaction the message is part of.
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+
+	// ContentType is the media type of the Payload.
+	ContentType string `json:"content_type,omitempty"`
+
+	// Accept is the media type the sender accepts in a response.
+	Accept string `json:"accept,omitempty"`
+
+	// Status is the optional status code of the message.
+	Status *int64 `json:"status,omitempty"`
+
+	// RequestDeliveryResponse is the optional request delivery response code.
+	RequestDeliveryResponse *int64 `json:"rdr,omitempty"`
+
+	// Headers is the list of additional headers of the message.
+	Headers []string `json:"headers,omitempty"`
+
+	// Metadata is the map of additional values describing the message.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Path is the path of the CRUD message.
+	Path string `json:"path,omitempty"`
+
+	// Payload is the body of the message.
+	Payload []byte `json:"payload,omitempty"`
+
+	// ServiceName is the name of the service the message is for.
+	ServiceName string `json:"service_name,omitempty"`
+
+	// URL is the url the message is addressed to.
+	URL string `json:"url,omitempty"`
+
+	// PartnerIDs is the list of partners the message belongs to.
+	PartnerIDs []string `json:"partner_ids,omitempty"`
+
+	// SessionID is the id of the device session that produced the message.
+	SessionID string `json:"session_id,omitempty"`
+
+	// QualityOfService is the quality of service value of the message.
+	QualityOfService int `json:"qos"`
+}
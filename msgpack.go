@@ -0,0 +1,167 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// msgpackWriter writes the subset of the msgpack format needed to encode a
+// Message.  The map keys match the json names of the Message fields, which
+// is also how github.com/xmidt-org/wrp-go encodes messages.
+type msgpackWriter struct {
+	buf []byte
+}
+
+// message writes the message as a map, leaving out the same empty fields the
+// json encoding does.
+func (w *msgpackWriter) message(m *Message) {
+	type field struct {
+		key   string
+		set   bool
+		write func()
+	}
+	fields := []field{
+		{"msg_type", true, func() { w.int(int64(m.Type)) }},
+		{"source", m.Source != "", func() { w.string(m.Source) }},
+		{"dest", m.Destination != "", func() { w.string(m.Destination) }},
+		{"transaction_uuid", m.TransactionUUID != "", func() { w.string(m.TransactionUUID) }},
+		{"content_type", m.ContentType != "", func() { w.string(m.ContentType) }},
+		{"accept", m.Accept != "", func() { w.string(m.Accept) }},
+		{"status", m.Status != nil, func() { w.int(deref(m.Status)) }},
+		{"rdr", m.RequestDeliveryResponse != nil, func() { w.int(deref(m.RequestDeliveryResponse)) }},
+		{"headers", len(m.Headers) > 0, func() { w.strings(m.Headers) }},
+		{"metadata", len(m.Metadata) > 0, func() { w.stringMap(m.Metadata) }},
+		{"path", m.Path != "", func() { w.string(m.Path) }},
+		{"payload", len(m.Payload) > 0, func() { w.bin(m.Payload) }},
+		{"service_name", m.ServiceName != "", func() { w.string(m.ServiceName) }},
+		{"url", m.URL != "", func() { w.string(m.URL) }},
+		{"partner_ids", len(m.PartnerIDs) > 0, func() { w.strings(m.PartnerIDs) }},
+		{"session_id", m.SessionID != "", func() { w.string(m.SessionID) }},
+		{"qos", true, func() { w.int(int64(m.QualityOfService)) }},
+	}
+
+	var n int
+	for _, f := range fields {
+		if f.set {
+			n++
+		}
+	}
+
+	w.mapHeader(n)
+	for _, f := range fields {
+		if f.set {
+			w.string(f.key)
+			f.write()
+		}
+	}
+}
+
+func deref(i *int64) int64 {
+	if i == nil {
+		return 0
+	}
+	return *i
+}
+
+func (w *msgpackWriter) header(fix byte, fixMax int, n int, c16, c32 byte) {
+	switch {
+	case n <= fixMax:
+		w.buf = append(w.buf, fix|byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, c16)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, c32)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	}
+}
+
+func (w *msgpackWriter) mapHeader(n int) {
+	w.header(0x80, 15, n, 0xde, 0xdf)
+}
+
+func (w *msgpackWriter) arrayHeader(n int) {
+	w.header(0x90, 15, n, 0xdc, 0xdd)
+}
+
+func (w *msgpackWriter) string(s string) {
+	switch n := len(s); {
+	case n <= 31:
+		w.buf = append(w.buf, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xda)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xdb)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, s...)
+}
+
+func (w *msgpackWriter) bin(b []byte) {
+	switch n := len(b); {
+	case n <= math.MaxUint8:
+		w.buf = append(w.buf, 0xc4, byte(n))
+	case n <= math.MaxUint16:
+		w.buf = append(w.buf, 0xc5)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(n))
+	default:
+		w.buf = append(w.buf, 0xc6)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(n))
+	}
+	w.buf = append(w.buf, b...)
+}
+
+// int writes the integer in the smallest signed format that holds it.
+func (w *msgpackWriter) int(i int64) {
+	switch {
+	case i >= 0 && i <= 127:
+		w.buf = append(w.buf, byte(i))
+	case i < 0 && i >= -32:
+		w.buf = append(w.buf, byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		w.buf = append(w.buf, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		w.buf = append(w.buf, 0xd1)
+		w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		w.buf = append(w.buf, 0xd2)
+		w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(i))
+	default:
+		w.buf = append(w.buf, 0xd3)
+		w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(i))
+	}
+}
+
+func (w *msgpackWriter) strings(list []string) {
+	w.arrayHeader(len(list))
+	for _, s := range list {
+		w.string(s)
+	}
+}
+
+// stringMap writes the map with the keys sorted so the output is stable.
+func (w *msgpackWriter) stringMap(m map[string]string) {
+	keys := sortedKeys(m)
+	w.mapHeader(len(keys))
+	for _, k := range keys {
+		w.string(k)
+		w.string(m[k])
+	}
+}
+
+// sortedKeys returns the keys of the map in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,200 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// minQuality is the smallest q-value that still marks a value as acceptable.
+const minQuality = 0.001
+
+// EncodingIdentity is the encoding that leaves the body unchanged.  It is
+// acceptable unless an AcceptEncoding value explicitly excludes it.
+const EncodingIdentity = "identity"
+
+var (
+	ErrNotAcceptable = errors.New("not acceptable")
+)
+
+// NotAcceptableError is returned when none of the supported values are
+// acceptable.  It maps to an HTTP 406 Not Acceptable response.
+type NotAcceptableError struct {
+	// Header is the header that was negotiated, either Accept or
+	// Accept-Encoding.
+	Header string
+
+	// Value is the value that was negotiated.
+	Value string
+
+	// Supported is the list of values that could have been chosen.
+	Supported []string
+}
+
+func (e *NotAcceptableError) Error() string {
+	return fmt.Sprintf("%s: %s '%s' does not allow any of: %s",
+		ErrNotAcceptable, e.Header, e.Value, strings.Join(e.Supported, ", "))
+}
+
+func (e *NotAcceptableError) Unwrap() error {
+	return ErrNotAcceptable
+}
+
+// StatusCode returns the HTTP status code for the error, 406.
+func (e *NotAcceptableError) StatusCode() int {
+	return http.StatusNotAcceptable
+}
+
+// acceptRange is a single entry of an Accept or Accept-Encoding list.
+type acceptRange struct {
+	value string
+	q     float64
+}
+
+// parseAcceptList parses a comma separated list of values with optional
+// q-values, for example `application/wrp+json;q=0.5, */*;q=0.1`.  Values are
+// lower cased, parameters other than q are dropped, and entries with an
+// invalid q-value are ignored.
+func parseAcceptList(list string) []acceptRange {
+	var ranges []acceptRange
+	for _, entry := range strings.Split(list, ",") {
+		value, params, _ := strings.Cut(entry, ";")
+		value = strings.ToLower(strings.TrimSpace(value))
+		if value == "" {
+			continue
+		}
+
+		r := acceptRange{value: value, q: 1}
+		valid := true
+		for _, param := range strings.Split(params, ";") {
+			k, v, _ := strings.Cut(param, "=")
+			if !strings.EqualFold(strings.TrimSpace(k), "q") {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil || q < 0 || q > 1 {
+				valid = false
+				break
+			}
+			r.q = q
+		}
+		if valid {
+			ranges = append(ranges, r)
+		}
+	}
+	return ranges
+}
+
+// NegotiateAccept chooses the supported media type the Accept value prefers.
+// The Accept value is a list of media ranges with optional q-values and
+// `type/*` or `*/*` wildcards.  The most specific range that matches a media
+// type provides its q-value, and ties are resolved by the order of the
+// supported list.  An empty Accept value accepts the first supported media
+// type.  If no media type is acceptable a *NotAcceptableError is returned.
+func NegotiateAccept(accept string, supported []string) (string, error) {
+	if strings.TrimSpace(accept) == "" && len(supported) > 0 {
+		return supported[0], nil
+	}
+	ranges := parseAcceptList(accept)
+
+	quality := func(mediaType string) float64 {
+		mediaType = strings.ToLower(mediaType)
+		major, _, _ := strings.Cut(mediaType, "/")
+
+		q, specificity := 0.0, -1
+		for _, r := range ranges {
+			s := -1
+			switch r.value {
+			case mediaType:
+				s = 2
+			case major + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s > specificity {
+				q, specificity = r.q, s
+			}
+		}
+		return q
+	}
+
+	if chosen, ok := best(supported, quality); ok {
+		return chosen, nil
+	}
+	return "", &NotAcceptableError{Header: "Accept", Value: accept, Supported: supported}
+}
+
+// NegotiateAcceptEncoding chooses the supported encoding the AcceptEncoding
+// value prefers.  The AcceptEncoding value is a list of encodings with
+// optional q-values and the `*` wildcard.  Ties are resolved by the order of
+// the supported list.  EncodingIdentity is considered after the supported
+// encodings.  If it is not listed, directly or by `*`, it is acceptable with
+// the lowest preference.  If no encoding is acceptable a *NotAcceptableError
+// is returned.
+func NegotiateAcceptEncoding(acceptEncoding string, supported []string) (string, error) {
+	ranges := parseAcceptList(acceptEncoding)
+
+	quality := func(encoding string) float64 {
+		encoding = strings.ToLower(encoding)
+		q, found := 0.0, false
+		for _, r := range ranges {
+			if r.value == encoding {
+				return r.q
+			}
+			if r.value == "*" {
+				q, found = r.q, true
+			}
+		}
+		if !found && encoding == EncodingIdentity {
+			return minQuality
+		}
+		return q
+	}
+
+	candidates := append([]string{}, supported...)
+	if !contains(candidates, EncodingIdentity) {
+		candidates = append(candidates, EncodingIdentity)
+	}
+
+	if chosen, ok := best(candidates, quality); ok {
+		return chosen, nil
+	}
+	return "", &NotAcceptableError{Header: "Accept-Encoding", Value: acceptEncoding, Supported: candidates}
+}
+
+// best returns the first candidate with the highest q-value, as long as that
+// q-value is larger than 0.
+func best(candidates []string, quality func(string) float64) (string, bool) {
+	var chosen string
+	var top float64
+	for _, c := range candidates {
+		if q := quality(c); q > top {
+			chosen, top = c, q
+		}
+	}
+	return chosen, top > 0
+}
+
+// NegotiateAccept chooses the media type for the webhook from its Accept
+// value, the way the NegotiateAccept function does.
+func (w Webhook) NegotiateAccept() (string, error) {
+	return NegotiateAccept(w.Accept, webhookAcceptTypes)
+}
+
+// NegotiateAcceptEncoding chooses the encoding for the webhook from its
+// AcceptEncoding value, the way the NegotiateAcceptEncoding function does.
+func (w Webhook) NegotiateAcceptEncoding() (string, error) {
+	return NegotiateAcceptEncoding(w.AcceptEncoding, acceptEncodings)
+}
+
+// NegotiateAccept chooses the media type for the kafka from its Accept
+// value, the way the NegotiateAccept function does.
+func (k Kafka) NegotiateAccept() (string, error) {
+	return NegotiateAccept(k.Accept, kafkaAcceptTypes)
+}
@@ -0,0 +1,164 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiateAccept(t *testing.T) {
+	supported := []string{MediaTypeWRPJSON, MediaTypeWRPMsgpack, MediaTypeWRPJSONL}
+	tests := []struct {
+		description string
+		accept      string
+		expected    string
+		expectedErr error
+	}{
+		{
+			description: "empty uses the first supported",
+			expected:    MediaTypeWRPJSON,
+		}, {
+			description: "exact",
+			accept:      MediaTypeWRPMsgpack,
+			expected:    MediaTypeWRPMsgpack,
+		}, {
+			description: "case insensitive",
+			accept:      "Application/WRP+Msgpack",
+			expected:    MediaTypeWRPMsgpack,
+		}, {
+			description: "highest q-value wins",
+			accept:      "application/wrp+json;q=0.5, application/wrp+jsonl;q=0.9",
+			expected:    MediaTypeWRPJSONL,
+		}, {
+			description: "ties use the supported order",
+			accept:      "application/wrp+jsonl, application/wrp+msgpack",
+			expected:    MediaTypeWRPMsgpack,
+		}, {
+			description: "type wildcard",
+			accept:      "text/plain, application/*;q=0.2",
+			expected:    MediaTypeWRPJSON,
+		}, {
+			description: "full wildcard",
+			accept:      "*/*",
+			expected:    MediaTypeWRPJSON,
+		}, {
+			description: "specific range overrides the wildcard",
+			accept:      "*/*;q=0.5, application/wrp+json;q=0",
+			expected:    MediaTypeWRPMsgpack,
+		}, {
+			description: "other parameters are ignored",
+			accept:      "application/wrp+jsonl;charset=utf-8;q=1",
+			expected:    MediaTypeWRPJSONL,
+		}, {
+			description: "invalid q-value is ignored",
+			accept:      "application/wrp+json;q=2, application/wrp+jsonl;q=0.1",
+			expected:    MediaTypeWRPJSONL,
+		}, {
+			description: "unsupported",
+			accept:      "application/json",
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "all excluded",
+			accept:      "*/*;q=0",
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "only invalid entries",
+			accept:      "application/wrp+json;q=abc",
+			expectedErr: ErrNotAcceptable,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := NegotiateAccept(tc.accept, supported)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestNegotiateAcceptEncoding(t *testing.T) {
+	supported := []string{"zz", EncodingGzip}
+	tests := []struct {
+		description    string
+		acceptEncoding string
+		expected       string
+		expectedErr    error
+	}{
+		{
+			description: "empty uses identity",
+			expected:    EncodingIdentity,
+		}, {
+			description:    "exact",
+			acceptEncoding: "gzip",
+			expected:       EncodingGzip,
+		}, {
+			description:    "highest q-value wins",
+			acceptEncoding: "zz;q=0.1, gzip;q=0.8",
+			expected:       EncodingGzip,
+		}, {
+			description:    "wildcard uses the supported order",
+			acceptEncoding: "*",
+			expected:       "zz",
+		}, {
+			description:    "identity preferred",
+			acceptEncoding: "gzip;q=0.5, identity",
+			expected:       EncodingIdentity,
+		}, {
+			description:    "unsupported falls back to identity",
+			acceptEncoding: "br",
+			expected:       EncodingIdentity,
+		}, {
+			description:    "identity excluded",
+			acceptEncoding: "br, identity;q=0",
+			expectedErr:    ErrNotAcceptable,
+		}, {
+			description:    "wildcard excludes identity",
+			acceptEncoding: "br, *;q=0",
+			expectedErr:    ErrNotAcceptable,
+		}, {
+			description:    "wildcard excludes everything else",
+			acceptEncoding: "gzip;q=0.5, *;q=0",
+			expected:       EncodingGzip,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := NegotiateAcceptEncoding(tc.acceptEncoding, supported)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestNotAcceptableError(t *testing.T) {
+	_, err := NegotiateAccept("text/html", []string{MediaTypeJSON})
+
+	var nae *NotAcceptableError
+	if assert.True(t, errors.As(err, &nae)) {
+		assert.Equal(t, http.StatusNotAcceptable, nae.StatusCode())
+		assert.Equal(t, "Accept", nae.Header)
+		assert.Equal(t, "text/html", nae.Value)
+		assert.Equal(t, "not acceptable: Accept 'text/html' does not allow any of: application/json", nae.Error())
+	}
+}
+
+func TestWebhookAndKafkaNegotiate(t *testing.T) {
+	w := Webhook{Accept: "application/wrp+*;q=0.5, application/wrp+msgpackl", AcceptEncoding: "gzip"}
+	got, err := w.NegotiateAccept()
+	assert.NoError(t, err)
+	assert.Equal(t, MediaTypeWRPMsgpackL, got)
+
+	got, err = w.NegotiateAcceptEncoding()
+	assert.NoError(t, err)
+	assert.Equal(t, EncodingGzip, got)
+
+	k := Kafka{Accept: "application/jsonl;q=0.4, application/msgpack;q=0.6"}
+	got, err = k.NegotiateAccept()
+	assert.NoError(t, err)
+	assert.Equal(t, MediaTypeMsgpack, got)
+}
@@ -5,6 +5,7 @@ package webhook
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/xmidt-org/urlegit"
@@ -57,7 +58,7 @@ func (atLeastOneEventOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.ValidateOneEvent()
 	case *RegistrationV2:
-		return fmt.Errorf("%w: RegistrationV2 does not have an events field to validate", ErrInvalidType)
+		return unsupported("RegistrationV2 does not have an events field to validate")
 	default:
 		return ErrUknownType
 	}
@@ -102,7 +103,7 @@ func (deviceIDRegexMustCompileOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.ValidateDeviceId()
 	case *RegistrationV2:
-		return fmt.Errorf("%w: RegistrationV2 does not use DeviceID directly, use `FieldRegex` instead", ErrInvalidType)
+		return unsupported("RegistrationV2 does not use DeviceID directly, use `FieldRegex` instead")
 	default:
 		return ErrUknownType
 	}
@@ -114,9 +115,10 @@ func (deviceIDRegexMustCompileOption) String() string {
 
 // ValidateRegistrationDuration ensures that the requsted registration duration
 // of a webhook is valid.  This option checks the values set in either the
-// Duration or Until fields of the webhook. If the ttl is less than or equal to
-// zero, this option will not boundary check the registration duration, but will
-// still ensure that the Duration or Until fields are set.
+// Duration or Until fields of a RegistrationV1, or the Expires field of a
+// RegistrationV2. If the ttl is less than or equal to zero, this option will
+// not boundary check the registration duration, but will still ensure that the
+// Duration, Until or Expires fields are set.
 func ValidateRegistrationDuration(ttl time.Duration) Option {
 	return validateRegistrationDurationOption{ttl: ttl}
 }
@@ -130,7 +132,7 @@ func (v validateRegistrationDurationOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.ValidateDuration(v.ttl)
 	case *RegistrationV2:
-		return r.ValidateDuration()
+		return r.ValidateDurationTTL(v.ttl)
 	default:
 		return ErrUknownType
 	}
@@ -154,6 +156,8 @@ func (p provideTimeNowFuncOption) Validate(i any) error {
 	switch r := i.(type) {
 	case *RegistrationV1:
 		r.SetNowFunc(p.nowFunc)
+	case *RegistrationV2:
+		r.SetNowFunc(p.nowFunc)
 	}
 
 	return nil
@@ -194,7 +198,14 @@ func (p provideFailureURLValidatorOption) Validate(i any) error {
 
 	if failureURL != "" {
 		if err := p.checker.Text(failureURL); err != nil {
-			return fmt.Errorf("%w: failure url is invalid", ErrInvalidInput)
+			return &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidURL,
+				Path:    "failure_url",
+				Value:   failureURL,
+				Message: "failure url is invalid",
+				Cause:   err,
+			}
 		}
 	}
 	return nil
@@ -258,7 +269,7 @@ func (p provideAlternativeURLValidatorOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.ValidateAltURL(p.checker)
 	case *RegistrationV2:
-		return fmt.Errorf("%w: RegistrationV2 does not have an alternative urls field. Use ProvideReceiverURLValidator() to validate all non-failure urls", ErrInvalidType)
+		return unsupported("RegistrationV2 does not have an alternative urls field. Use ProvideReceiverURLValidator() to validate all non-failure urls")
 	default:
 		return ErrUknownType
 	}
@@ -283,7 +294,7 @@ func (noUntilOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.ValidateNoUntil()
 	case *RegistrationV2:
-		return fmt.Errorf("%w: RegistrationV2 does not use an Until field", ErrInvalidType)
+		return unsupported("RegistrationV2 does not use an Until field")
 	default:
 		return ErrUknownType
 	}
@@ -293,6 +304,15 @@ func (noUntilOption) String() string {
 	return "NoUntil()"
 }
 
+// Until ensures that the Until field of a RegistrationV1 or the Expires field
+// of a RegistrationV2 is no later than the max duration plus the jitter from
+// now.  If now is nil, the clock provided by ProvideTimeNowFunc is used for a
+// RegistrationV2 and time.Now is used for a RegistrationV1.
+//
+// Earlier releases rejected every RegistrationV2 with ErrInvalidType because
+// it has no Until field.  A RegistrationV2 is now checked by its Expires
+// field, so validators that list Until for both versions start accepting
+// RegistrationV2s that expire in time.
 func Until(now func() time.Time, jitter, max time.Duration) Option {
 	return untilOption{
 		now:    now,
@@ -312,12 +332,474 @@ func (u untilOption) Validate(i any) error {
 	case *RegistrationV1:
 		return r.CheckUntil(u.now, u.jitter, u.max)
 	case *RegistrationV2:
-		return fmt.Errorf("%w: RegistrationV2 does not use an Until field", ErrInvalidType)
+		return r.CheckExpires(u.now, u.jitter, u.max)
 	default:
 		return ErrUknownType
 	}
 }
 
 func (u untilOption) String() string {
+	if u.now == nil {
+		return fmt.Sprintf("untilOption(%v, %v, nil)", u.jitter.String(), u.max.String())
+	}
 	return fmt.Sprintf("untilOption(%v, %v, %v)", u.jitter.String(), u.max.String(), u.now().String())
 }
+
+// ClampExpiration shortens registrations that are longer than the max duration
+// plus the jitter from now to the max duration, instead of rejecting them.
+// The Until and Duration fields of a RegistrationV1 and the Expires field of a
+// RegistrationV2 are clamped.  If now is nil, the clock provided by
+// ProvideTimeNowFunc is used.  A max of zero disables clamping.
+//
+// The registration is modified, so this option should come before the options
+// that check the registration duration.
+func ClampExpiration(now func() time.Time, jitter, max time.Duration) Option {
+	return clampExpirationOption{
+		now:    now,
+		jitter: jitter,
+		max:    max,
+	}
+}
+
+type clampExpirationOption struct {
+	now    func() time.Time
+	jitter time.Duration
+	max    time.Duration
+}
+
+func (c clampExpirationOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return r.ClampUntil(c.now, c.jitter, c.max)
+	case *RegistrationV2:
+		return r.ClampExpires(c.now, c.jitter, c.max)
+	default:
+		return ErrUknownType
+	}
+}
+
+func (c clampExpirationOption) String() string {
+	now := "nil"
+	if c.now != nil {
+		now = "func"
+	}
+	return fmt.Sprintf("ClampExpiration(%s, %v, %v)", now, c.jitter, c.max)
+}
+
+// ReceiverURLsOrDNSSrvRecord ensures that each webhook uses exactly one of
+// ReceiverURLs or DNSSrvRecord.
+func ReceiverURLsOrDNSSrvRecord() Option {
+	return receiverURLsOrDNSSrvRecordOption{}
+}
+
+type receiverURLsOrDNSSrvRecordOption struct{}
+
+func (receiverURLsOrDNSSrvRecordOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have webhooks to validate")
+	case *RegistrationV2:
+		return r.ValidateReceivers()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (receiverURLsOrDNSSrvRecordOption) String() string {
+	return "ReceiverURLsOrDNSSrvRecord()"
+}
+
+// SupportedAccept ensures that each webhook Accept value allows at least one
+// of the supported wrp media types.  The value may be a single media type or
+// a list with q-values and wildcards.  The errors wrap ErrNotAcceptable.
+func SupportedAccept() Option {
+	return supportedAcceptOption{}
+}
+
+type supportedAcceptOption struct{}
+
+func (supportedAcceptOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have an accept field, use content_type instead")
+	case *RegistrationV2:
+		return r.ValidateAccept()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (supportedAcceptOption) String() string {
+	return "SupportedAccept()"
+}
+
+// SupportedAcceptEncoding ensures that each webhook AcceptEncoding value
+// allows at least one of the supported encodings.  The value may be a single
+// encoding or a list with q-values and wildcards.  The errors wrap
+// ErrNotAcceptable.
+func SupportedAcceptEncoding() Option {
+	return supportedAcceptEncodingOption{}
+}
+
+type supportedAcceptEncodingOption struct{}
+
+func (supportedAcceptEncodingOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have an accept encoding field")
+	case *RegistrationV2:
+		return r.ValidateAcceptEncoding()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (supportedAcceptEncodingOption) String() string {
+	return "SupportedAcceptEncoding()"
+}
+
+// NegotiableAccept ensures that each webhook Accept and AcceptEncoding value,
+// and each kafka Accept value, allows at least one supported value.  It is
+// SupportedAccept, SupportedAcceptEncoding and SupportedKafkaAccept in one
+// option.  The errors wrap ErrNotAcceptable.
+func NegotiableAccept() Option {
+	return negotiableAcceptOption{}
+}
+
+type negotiableAcceptOption struct{}
+
+func (negotiableAcceptOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have an accept field, use content_type instead")
+	case *RegistrationV2:
+		return r.ValidateNegotiableAccept()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (negotiableAcceptOption) String() string {
+	return "NegotiableAccept()"
+}
+
+// SupportedCompressionLevel ensures that each webhook CompressionLevel is
+// valid for the encoding of the webhook.
+func SupportedCompressionLevel() Option {
+	return supportedCompressionLevelOption{}
+}
+
+type supportedCompressionLevelOption struct{}
+
+func (supportedCompressionLevelOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a compression level field")
+	case *RegistrationV2:
+		return r.ValidateCompressionLevel()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (supportedCompressionLevelOption) String() string {
+	return "SupportedCompressionLevel()"
+}
+
+// SupportedSecretHash ensures that each webhook SecretHash value is either
+// empty or one of sha256 or sha512.
+func SupportedSecretHash() Option {
+	return supportedSecretHashOption{}
+}
+
+type supportedSecretHashOption struct{}
+
+func (supportedSecretHashOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a secret hash field, sha1 is always used")
+	case *RegistrationV2:
+		return r.ValidateSecretHash()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (supportedSecretHashOption) String() string {
+	return "SupportedSecretHash()"
+}
+
+// ValidDNSSrvRecord ensures that the FQDNs of each webhook's DNSSrvRecord are
+// valid domain names and that the LoadBalancingScheme is supported.
+func ValidDNSSrvRecord() Option {
+	return validDNSSrvRecordOption{}
+}
+
+type validDNSSrvRecordOption struct{}
+
+func (validDNSSrvRecordOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a dns srv record field")
+	case *RegistrationV2:
+		return r.ValidateDNSSrvRecord()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (validDNSSrvRecordOption) String() string {
+	return "ValidDNSSrvRecord()"
+}
+
+// NonNegativeRetryHint ensures that the RetryHint values of each webhook are
+// not negative, and that the backoff values are consistent with each other.
+func NonNegativeRetryHint() Option {
+	return nonNegativeRetryHintOption{}
+}
+
+type nonNegativeRetryHintOption struct{}
+
+func (nonNegativeRetryHintOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a retry hint field")
+	case *RegistrationV2:
+		return r.ValidateRetryHint()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (nonNegativeRetryHintOption) String() string {
+	return "NonNegativeRetryHint()"
+}
+
+// AtLeastOneBootstrapServer ensures that each kafka has at least one bootstrap
+// server.
+func AtLeastOneBootstrapServer() Option {
+	return atLeastOneBootstrapServerOption{}
+}
+
+type atLeastOneBootstrapServerOption struct{}
+
+func (atLeastOneBootstrapServerOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateBootstrapServers()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (atLeastOneBootstrapServerOption) String() string {
+	return "AtLeastOneBootstrapServer()"
+}
+
+// BootstrapServerSyntax ensures that each kafka bootstrap server is in the
+// form host:port with a port in the range 1-65535.
+func BootstrapServerSyntax() Option {
+	return bootstrapServerSyntaxOption{}
+}
+
+type bootstrapServerSyntaxOption struct{}
+
+func (bootstrapServerSyntaxOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateBootstrapServerSyntax()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (bootstrapServerSyntaxOption) String() string {
+	return "BootstrapServerSyntax()"
+}
+
+// BootstrapServerPolicy ensures that each kafka bootstrap server is allowed by
+// the lists of allowed and denied servers.  See
+// RegistrationV2.ValidateBootstrapServerPolicy for how the lists are used.
+func BootstrapServerPolicy(allow, deny []string) Option {
+	return bootstrapServerPolicyOption{allow: allow, deny: deny}
+}
+
+type bootstrapServerPolicyOption struct {
+	allow []string
+	deny  []string
+}
+
+func (b bootstrapServerPolicyOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateBootstrapServerPolicy(b.allow, b.deny)
+	default:
+		return ErrUknownType
+	}
+}
+
+func (b bootstrapServerPolicyOption) String() string {
+	return "BootstrapServerPolicy([" + strings.Join(b.allow, ", ") + "], [" + strings.Join(b.deny, ", ") + "])"
+}
+
+// SupportedKafkaAccept ensures that each kafka Accept value allows at least
+// one of the supported media types.  The value may be a single media type or
+// a list with q-values and wildcards.  The errors wrap ErrNotAcceptable.
+func SupportedKafkaAccept() Option {
+	return supportedKafkaAcceptOption{}
+}
+
+type supportedKafkaAcceptOption struct{}
+
+func (supportedKafkaAcceptOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateKafkaAccept()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (supportedKafkaAcceptOption) String() string {
+	return "SupportedKafkaAccept()"
+}
+
+// KafkaRetryHintBounds ensures that the RetryHint values of each kafka are not
+// negative and are not larger than the maximums.  A maximum less than or equal
+// to zero is not enforced.
+func KafkaRetryHintBounds(maxRetryEachURL, maxRetry int) Option {
+	return kafkaRetryHintBoundsOption{maxRetryEachURL: maxRetryEachURL, maxRetry: maxRetry}
+}
+
+type kafkaRetryHintBoundsOption struct {
+	maxRetryEachURL int
+	maxRetry        int
+}
+
+func (k kafkaRetryHintBoundsOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateKafkaRetryHint(k.maxRetryEachURL, k.maxRetry)
+	default:
+		return ErrUknownType
+	}
+}
+
+func (k kafkaRetryHintBoundsOption) String() string {
+	return fmt.Sprintf("KafkaRetryHintBounds(%d, %d)", k.maxRetryEachURL, k.maxRetry)
+}
+
+// NonNegativeBatchHint ensures that the BatchHint values are not negative.
+func NonNegativeBatchHint() Option {
+	return nonNegativeBatchHintOption{}
+}
+
+type nonNegativeBatchHintOption struct{}
+
+func (nonNegativeBatchHintOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a batch hint field")
+	case *RegistrationV2:
+		return r.ValidateBatchHint()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (nonNegativeBatchHintOption) String() string {
+	return "NonNegativeBatchHint()"
+}
+
+// RetryBackoffBounds ensures that the backoff values of the RetryHint of each
+// webhook and kafka are within the server policy.  The InitialBackoff and
+// MaxBackoff must be between the minBackoff and the maxBackoff, and the
+// BackoffMultiplier must not be larger than the maxMultiplier.  A bound less
+// than or equal to zero is not enforced.  Use NonNegativeRetryHint to reject
+// negative values.
+func RetryBackoffBounds(minBackoff, maxBackoff time.Duration, maxMultiplier float64) Option {
+	return retryBackoffBoundsOption{
+		minBackoff:    minBackoff,
+		maxBackoff:    maxBackoff,
+		maxMultiplier: maxMultiplier,
+	}
+}
+
+type retryBackoffBoundsOption struct {
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	maxMultiplier float64
+}
+
+func (r retryBackoffBoundsOption) Validate(i any) error {
+	switch reg := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have a retry hint field")
+	case *RegistrationV2:
+		return reg.ValidateRetryBackoff(r.minBackoff, r.maxBackoff, r.maxMultiplier)
+	default:
+		return ErrUknownType
+	}
+}
+
+func (r retryBackoffBoundsOption) String() string {
+	return fmt.Sprintf("RetryBackoffBounds(%v, %v, %v)", r.minBackoff, r.maxBackoff, r.maxMultiplier)
+}
+
+// ValidKafkaProducer ensures that the KafkaProducer values of each kafka are
+// supported and do not contradict each other, for example an idempotent
+// producer without a RequiredAcks of all.
+func ValidKafkaProducer() Option {
+	return validKafkaProducerOption{}
+}
+
+type validKafkaProducerOption struct{}
+
+func (validKafkaProducerOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateKafkaProducer()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (validKafkaProducerOption) String() string {
+	return "ValidKafkaProducer()"
+}
+
+// SecureKafkaProducer ensures that the KafkaProducer of each kafka does not
+// send SASL credentials without TLS and does not skip the verification of
+// the broker certificates.
+func SecureKafkaProducer() Option {
+	return secureKafkaProducerOption{}
+}
+
+type secureKafkaProducerOption struct{}
+
+func (secureKafkaProducerOption) Validate(i any) error {
+	switch r := i.(type) {
+	case *RegistrationV1:
+		return unsupported("RegistrationV1 does not have kafkas to validate")
+	case *RegistrationV2:
+		return r.ValidateKafkaProducerSecurity()
+	default:
+		return ErrUknownType
+	}
+}
+
+func (secureKafkaProducerOption) String() string {
+	return "SecureKafkaProducer()"
+}
@@ -4,6 +4,7 @@
 package webhook
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -202,36 +203,36 @@ func TestValidateRegistrationDuration(t *testing.T) {
 		}, {
 			description: "success with until in bounds - V1",
 			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
-			in:          &RegistrationV1{Until: time.Date(2021, 1, 1, 0, 4, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 4, 0, 0, time.UTC)}},
 		}, {
 			description: "failure due to until being before now - V1",
 			opts:        []Option{ValidateRegistrationDuration(5 * time.Minute), ProvideTimeNowFunc(now)},
 			in: &RegistrationV1{
-				Until: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+				Until: CustomTime{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)},
 			},
 			expectedErr: ErrInvalidInput,
 		}, {
 			description: "success with until exactly in bounds - V1",
 			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
-			in:          &RegistrationV1{Until: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)}},
 		}, {
 			description: "failure due to the options being out of order - V1",
 			opts:        []Option{ValidateRegistrationDuration(5 * time.Minute), ProvideTimeNowFunc(now)},
-			in:          &RegistrationV1{Until: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)}},
 			expectedErr: ErrInvalidInput,
 		}, {
 			description: "failure with until out of bounds - V1",
 			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
-			in:          &RegistrationV1{Until: time.Date(2021, 1, 1, 0, 6, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 6, 0, 0, time.UTC)}},
 			expectedErr: ErrInvalidInput,
 		}, {
 			description: "success with until just needing to be present - V1",
 			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(0)},
-			in:          &RegistrationV1{Until: time.Date(2021, 1, 1, 0, 6, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 6, 0, 0, time.UTC)}},
 		}, {
 			description: "failure, both expirations set - V1",
 			opt:         ValidateRegistrationDuration(5 * time.Minute),
-			in:          &RegistrationV1{Duration: CustomDuration(1 * time.Minute), Until: time.Date(2021, 1, 1, 0, 4, 0, 0, time.UTC)},
+			in:          &RegistrationV1{Duration: CustomDuration(1 * time.Minute), Until: CustomTime{Time: time.Date(2021, 1, 1, 0, 4, 0, 0, time.UTC)}},
 			expectedErr: ErrInvalidInput,
 		}, {
 			description: "failure, no expiration set - V1",
@@ -240,9 +241,36 @@ func TestValidateRegistrationDuration(t *testing.T) {
 			expectedErr: ErrInvalidInput,
 		}, {
 			description: "failure, exipred - V2",
-			in:          &RegistrationV2{Expires: now()},
+			in:          &RegistrationV2{Expires: CustomTime{Time: now()}},
 			opt:         ValidateRegistrationDuration(0),
 			expectedErr: ErrInvalidInput,
+		}, {
+			description: "success with expires in bounds - V2",
+			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
+			in:          &RegistrationV2{Expires: CustomTime{Time: time.Date(2021, 1, 1, 0, 4, 0, 0, time.UTC)}},
+		}, {
+			description: "success with expires exactly in bounds - V2",
+			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
+			in:          &RegistrationV2{Expires: CustomTime{Time: time.Date(2021, 1, 1, 0, 5, 0, 0, time.UTC)}},
+		}, {
+			description: "failure with expires out of bounds - V2",
+			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
+			in:          &RegistrationV2{Expires: CustomTime{Time: time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "success with expires just needing to be present - V2",
+			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(0)},
+			in:          &RegistrationV2{Expires: CustomTime{Time: time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		}, {
+			description: "failure due to expires being before now - V2",
+			opts:        []Option{ProvideTimeNowFunc(now), ValidateRegistrationDuration(5 * time.Minute)},
+			in:          &RegistrationV2{Expires: CustomTime{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "failure, no expiration set - V2",
+			opt:         ValidateRegistrationDuration(5 * time.Minute),
+			in:          &RegistrationV2{},
+			expectedErr: ErrInvalidInput,
 		},
 		{
 			description: "default case - unknown",
@@ -252,6 +280,20 @@ func TestValidateRegistrationDuration(t *testing.T) {
 	})
 }
 
+func TestRegistrationV2ValidateDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	v2 := RegistrationV2{Expires: CustomTime{Time: time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)}}
+	v2.SetNowFunc(func() time.Time { return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC) })
+
+	// ValidateDuration does not limit how far away Expires is.
+	assert.NoError(v2.ValidateDuration())
+	assert.ErrorIs(v2.ValidateDurationTTL(5*time.Minute), ErrInvalidInput)
+
+	v2.Expires = CustomTime{Time: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	assert.ErrorIs(v2.ValidateDuration(), ErrInvalidInput)
+}
+
 func TestProvideTimeNowFunc(t *testing.T) {
 	now := func() time.Time {
 		return time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -266,8 +308,20 @@ func TestProvideTimeNowFunc(t *testing.T) {
 			description: "success as nil",
 			opt:         ProvideTimeNowFunc(nil),
 			str:         "ProvideTimeNowFunc(nil)",
+		}, {
+			description: "success - V1",
+			opt:         ProvideTimeNowFunc(now),
+			in:          &RegistrationV1{},
+		}, {
+			description: "success - V2",
+			opt:         ProvideTimeNowFunc(now),
+			in:          &RegistrationV2{},
 		},
 	})
+
+	v2 := &RegistrationV2{}
+	assert.NoError(t, ProvideTimeNowFunc(now).Validate(v2))
+	assert.Equal(t, now(), v2.now())
 }
 
 func TestProvideFailureURLValidator(t *testing.T) {
@@ -400,7 +454,7 @@ func TestNoUntil(t *testing.T) {
 			description: "detect until set",
 			opt:         NoUntil(),
 			in: &RegistrationV1{
-				Until: time.Now(),
+				Until: CustomTime{Time: time.Now()},
 			},
 			expectedErr: ErrInvalidInput,
 		},
@@ -423,10 +477,1028 @@ func TestUntilOption(t *testing.T) {
 		{
 			description: "success, until",
 			in: &RegistrationV1{
-				Until: mockNow(),
+				Until: CustomTime{Time: mockNow()},
 			},
 			opt: Until(time.Now, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
 		},
+		{
+			description: "success, nil now",
+			in:          &RegistrationV1{},
+			opt:         Until(nil, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+			str:         "untilOption(1m0s, 5m0s, nil)",
+		},
+		{
+			description: "failure, until out of bounds",
+			in:          &RegistrationV1{Until: CustomTime{Time: mockNow().Add(7 * time.Minute)}},
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+			expectedErr: errInvalidUntil,
+		},
+		{
+			description: "failure, provided clock",
+			in:          &RegistrationV1{Until: CustomTime{Time: mockNow().Add(7 * time.Minute)}},
+			opts:        []Option{ProvideTimeNowFunc(mockNow), Until(nil, time.Duration(1*time.Minute), time.Duration(5*time.Minute))},
+			expectedErr: errInvalidUntil,
+		},
+		{
+			description: "success, expires - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow().Add(6 * time.Minute)}},
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+		},
+		{
+			description: "success, no expires - V2",
+			in:          &RegistrationV2{},
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+		},
+		{
+			description: "success, provided clock - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow().Add(6 * time.Minute)}},
+			opts:        []Option{ProvideTimeNowFunc(mockNow), Until(nil, time.Duration(1*time.Minute), time.Duration(5*time.Minute))},
+		},
+		{
+			description: "failure, ten years - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow().AddDate(10, 0, 0)}},
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+			expectedErr: errInvalidExpires,
+		},
+		{
+			description: "failure, negative ttl - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow()}},
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(-5*time.Minute)),
+			expectedErr: errInvalidTTL,
+		},
+		{
+			description: "failure, negative jitter - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow()}},
+			opt:         Until(mockNow, time.Duration(-1*time.Minute), time.Duration(5*time.Minute)),
+			expectedErr: errInvalidJitter,
+		},
+		{
+			description: "default case - unknown",
+			opt:         Until(mockNow, time.Duration(1*time.Minute), time.Duration(5*time.Minute)),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestClampExpiration(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "success - V1",
+			opt:         ClampExpiration(mockNow, time.Minute, 5*time.Minute),
+			in:          &RegistrationV1{Until: CustomTime{Time: mockNow().AddDate(10, 0, 0)}},
+			str:         "ClampExpiration(func, 1m0s, 5m0s)",
+		}, {
+			description: "success - V2",
+			opt:         ClampExpiration(nil, time.Minute, 5*time.Minute),
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow().AddDate(10, 0, 0)}},
+			str:         "ClampExpiration(nil, 1m0s, 5m0s)",
+		}, {
+			description: "failure, negative ttl - V1",
+			opt:         ClampExpiration(mockNow, time.Minute, -5*time.Minute),
+			in:          &RegistrationV1{},
+			expectedErr: errInvalidTTL,
+		}, {
+			description: "failure, negative jitter - V2",
+			opt:         ClampExpiration(mockNow, -time.Minute, 5*time.Minute),
+			in:          &RegistrationV2{},
+			expectedErr: errInvalidJitter,
+		}, {
+			description: "default case - unknown",
+			opt:         ClampExpiration(mockNow, time.Minute, 5*time.Minute),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestClampExpirationValues(t *testing.T) {
+	max := 5 * time.Minute
+	limit := mockNow().Add(max)
+	tests := []struct {
+		description string
+		in          any
+		expected    any
+	}{
+		{
+			description: "until clamped - V1",
+			in:          &RegistrationV1{Until: CustomTime{Time: mockNow().AddDate(10, 0, 0)}},
+			expected:    &RegistrationV1{Until: CustomTime{Time: limit}},
+		}, {
+			description: "until within jitter - V1",
+			in:          &RegistrationV1{Until: CustomTime{Time: limit.Add(time.Minute)}},
+			expected:    &RegistrationV1{Until: CustomTime{Time: limit.Add(time.Minute)}},
+		}, {
+			description: "duration clamped - V1",
+			in:          &RegistrationV1{Duration: CustomDuration(24 * time.Hour)},
+			expected:    &RegistrationV1{Duration: CustomDuration(max)},
+		}, {
+			description: "duration within jitter - V1",
+			in:          &RegistrationV1{Duration: CustomDuration(6 * time.Minute)},
+			expected:    &RegistrationV1{Duration: CustomDuration(6 * time.Minute)},
+		}, {
+			description: "expires clamped - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: mockNow().AddDate(10, 0, 0)}},
+			expected:    &RegistrationV2{Expires: CustomTime{Time: limit}},
+		}, {
+			description: "expires within jitter - V2",
+			in:          &RegistrationV2{Expires: CustomTime{Time: limit.Add(time.Minute)}},
+			expected:    &RegistrationV2{Expires: CustomTime{Time: limit.Add(time.Minute)}},
+		}, {
+			description: "expires not set - V2",
+			in:          &RegistrationV2{},
+			expected:    &RegistrationV2{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.NoError(ClampExpiration(mockNow, time.Minute, max).Validate(tc.in))
+			assert.Equal(tc.expected, tc.in)
+
+			// a clamped registration passes the duration checks
+			assert.NoError(Until(mockNow, time.Minute, max).Validate(tc.in))
+		})
+	}
+
+	// a max of zero disables clamping
+	r := &RegistrationV2{Expires: CustomTime{Time: mockNow().AddDate(10, 0, 0)}}
+	assert.NoError(t, ClampExpiration(mockNow, time.Minute, 0).Validate(r))
+	assert.Equal(t, mockNow().AddDate(10, 0, 0), r.Expires.Time)
+}
+
+func TestReceiverURLsOrDNSSrvRecord(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "receiver urls",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{ReceiverURLs: []string{"https://example.com"}}}},
+			str:         "ReceiverURLsOrDNSSrvRecord()",
+		}, {
+			description: "dns srv record",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{DNSSrvRecord: DNSSrvRecord{FQDNs: []string{"_http._tcp.example.com"}, LoadBalancingScheme: "weight"}},
+			}},
+		}, {
+			description: "no webhooks",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in:          &RegistrationV2{},
+		}, {
+			description: "both used",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in: &RegistrationV2{Webhooks: []Webhook{{
+				ReceiverURLs: []string{"https://example.com"},
+				DNSSrvRecord: DNSSrvRecord{FQDNs: []string{"_http._tcp.example.com"}},
+			}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "receiver urls with a load balancing scheme",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in: &RegistrationV2{Webhooks: []Webhook{{
+				ReceiverURLs: []string{"https://example.com"},
+				DNSSrvRecord: DNSSrvRecord{LoadBalancingScheme: LoadBalancingWeight},
+			}}},
+		}, {
+			description: "only a load balancing scheme",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in: &RegistrationV2{Webhooks: []Webhook{{
+				DNSSrvRecord: DNSSrvRecord{LoadBalancingScheme: LoadBalancingWeight},
+			}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "neither used",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{ReceiverURLs: []string{"https://example.com"}}, {}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         ReceiverURLsOrDNSSrvRecord(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSupportedAccept(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "supported accepts",
+			opt:         SupportedAccept(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{Accept: MediaTypeWRPJSON},
+				{Accept: MediaTypeWRPMsgpack},
+				{Accept: MediaTypeWRPOctetStream},
+				{Accept: MediaTypeWRPJSONL},
+				{Accept: MediaTypeWRPMsgpackL},
+				{},
+			}},
+			str: "SupportedAccept()",
+		}, {
+			description: "negotiable accepts",
+			opt:         SupportedAccept(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{Accept: "application/*"},
+				{Accept: "text/plain, application/wrp+msgpack;q=0.5"},
+			}},
+		}, {
+			description: "unsupported accept",
+			opt:         SupportedAccept(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{Accept: MediaTypeWRPJSON}, {Accept: "text/plain"}}},
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SupportedAccept(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SupportedAccept(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSupportedAcceptEncoding(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "supported encodings",
+			opt:         SupportedAcceptEncoding(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{AcceptEncoding: EncodingGzip},
+				{AcceptEncoding: EncodingZstd},
+				{AcceptEncoding: EncodingBrotli},
+				{AcceptEncoding: EncodingDeflate},
+				{},
+			}},
+			str: "SupportedAcceptEncoding()",
+		}, {
+			description: "negotiable encodings",
+			opt:         SupportedAcceptEncoding(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{AcceptEncoding: "br;q=1, gzip;q=0.5"},
+				{AcceptEncoding: "*"},
+				// identity is acceptable unless it is excluded
+				{AcceptEncoding: "lzma"},
+			}},
+		}, {
+			description: "unsupported encoding",
+			opt:         SupportedAcceptEncoding(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{AcceptEncoding: "lzma, identity;q=0"}}},
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SupportedAcceptEncoding(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SupportedAcceptEncoding(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSupportedSecretHash(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "supported hashes",
+			opt:         SupportedSecretHash(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{SecretHash: SecretHashSHA256},
+				{SecretHash: SecretHashSHA512},
+				{},
+			}},
+			str: "SupportedSecretHash()",
+		}, {
+			description: "unsupported hash",
+			opt:         SupportedSecretHash(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{SecretHash: "sha1"}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SupportedSecretHash(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SupportedSecretHash(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestValidDNSSrvRecord(t *testing.T) {
+	srv := func(d DNSSrvRecord) *RegistrationV2 {
+		return &RegistrationV2{Webhooks: []Webhook{{DNSSrvRecord: d}}}
+	}
+
+	run_tests(t, []optionTest{
+		{
+			description: "success",
+			opt:         ValidDNSSrvRecord(),
+			in:          srv(DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com."}, LoadBalancingScheme: LoadBalancingWeight}),
+			str:         "ValidDNSSrvRecord()",
+		}, {
+			description: "success, default scheme",
+			opt:         ValidDNSSrvRecord(),
+			in:          srv(DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}}),
+		}, {
+			description: "success, no dns srv record",
+			opt:         ValidDNSSrvRecord(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{ReceiverURLs: []string{"https://example.com"}}}},
+		}, {
+			description: "failure, invalid fqdn",
+			opt:         ValidDNSSrvRecord(),
+			in:          srv(DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com", "https://example.com"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "failure, invalid scheme",
+			opt:         ValidDNSSrvRecord(),
+			in:          srv(DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}, LoadBalancingScheme: "round-robin"}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "failure - V1",
+			opt:         ValidDNSSrvRecord(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         ValidDNSSrvRecord(),
+			expectedErr: ErrUknownType,
+		},
+	})
+
+	err := ValidDNSSrvRecord().Validate(srv(DNSSrvRecord{FQDNs: []string{"ok.example.com", "bad..example.com"}, LoadBalancingScheme: "x"}))
+	var paths []string
+	for _, ve := range ValidationErrors(err) {
+		paths = append(paths, ve.Path)
+	}
+	assert.Equal(t, []string{"webhooks[0].dns_srv_record.fqdns[1]", "webhooks[0].dns_srv_record.load_balancing_scheme"}, paths)
+}
+
+func TestNonNegativeRetryHint(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "valid retry hints",
+			opt:         NonNegativeRetryHint(),
+			in: &RegistrationV2{Webhooks: []Webhook{
+				{RetryHint: RetryHint{RetryEachUrl: 1, MaxRetry: 3}},
+				{},
+			}},
+			str: "NonNegativeRetryHint()",
+		}, {
+			description: "negative retry each url",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{RetryEachUrl: -1}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative max retry",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{MaxRetry: -1}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "valid backoff",
+			opt:         NonNegativeRetryHint(),
+			in: &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{
+				InitialBackoff:    CustomDuration(time.Second),
+				BackoffMultiplier: 1,
+				MaxBackoff:        CustomDuration(time.Second),
+				BackoffJitter:     1,
+				HonorRetryAfter:   true,
+			}}}},
+		}, {
+			description: "negative initial backoff",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{InitialBackoff: CustomDuration(-time.Second)}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative max backoff",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{MaxBackoff: CustomDuration(-time.Second)}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "max backoff shorter than initial backoff",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{InitialBackoff: CustomDuration(time.Minute), MaxBackoff: CustomDuration(time.Second)}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "multiplier less than one",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{BackoffMultiplier: 0.5}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "multiplier not a number",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{BackoffMultiplier: math.NaN()}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "jitter too large",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{BackoffJitter: 1.5}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative jitter",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{RetryHint: RetryHint{BackoffJitter: -0.5}}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         NonNegativeRetryHint(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         NonNegativeRetryHint(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSupportedCompressionLevel(t *testing.T) {
+	webhook := func(encoding string, level int) *RegistrationV2 {
+		return &RegistrationV2{Webhooks: []Webhook{{AcceptEncoding: encoding, CompressionLevel: level}}}
+	}
+	run_tests(t, []optionTest{
+		{
+			description: "gzip level",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingGzip, 9),
+			str:         "SupportedCompressionLevel()",
+		}, {
+			description: "zstd level",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingZstd, 22),
+		}, {
+			description: "brotli level",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingBrotli, 11),
+		}, {
+			description: "default level",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingDeflate, 0),
+		}, {
+			description: "negotiated encoding",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook("deflate;q=0.5, zstd", 15),
+		}, {
+			description: "not negotiable is ignored",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook("identity;q=0", 15),
+		}, {
+			description: "gzip level too large",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingGzip, 10),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative level",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook(EncodingZstd, -1),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "level without an encoding",
+			opt:         SupportedCompressionLevel(),
+			in:          webhook("", 1),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SupportedCompressionLevel(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SupportedCompressionLevel(),
+			expectedErr: ErrUknownType,
+		},
+	})
+
+	err := SupportedCompressionLevel().Validate(webhook(EncodingBrotli, 12))
+	if ves := ValidationErrors(err); assert.Len(t, ves, 1) {
+		assert.Equal(t, CodeOutOfRange, ves[0].Code)
+		assert.Equal(t, "webhooks[0].compression_level", ves[0].Path)
+	}
+}
+
+func TestNegotiableAccept(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "negotiable",
+			opt:         NegotiableAccept(),
+			in: &RegistrationV2{
+				Webhooks: []Webhook{{Accept: "application/*", AcceptEncoding: "gzip;q=0.5, *;q=0"}},
+				Kafkas:   []Kafka{{Accept: "application/json;q=0.1, text/plain"}},
+			},
+			str: "NegotiableAccept()",
+		}, {
+			description: "empty values",
+			opt:         NegotiableAccept(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{}}, Kafkas: []Kafka{{}}},
+		}, {
+			description: "webhook accept not acceptable",
+			opt:         NegotiableAccept(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{Accept: "text/plain"}}},
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "webhook accept encoding not acceptable",
+			opt:         NegotiableAccept(),
+			in:          &RegistrationV2{Webhooks: []Webhook{{AcceptEncoding: "identity;q=0"}}},
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "kafka accept not acceptable",
+			opt:         NegotiableAccept(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{Accept: MediaTypeWRPJSON}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         NegotiableAccept(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         NegotiableAccept(),
+			expectedErr: ErrUknownType,
+		},
+	})
+
+	err := NegotiableAccept().Validate(&RegistrationV2{Webhooks: []Webhook{{}, {Accept: "text/plain", AcceptEncoding: "*;q=0"}}})
+	var codes, paths []string
+	for _, ve := range ValidationErrors(err) {
+		codes = append(codes, ve.Code)
+		paths = append(paths, ve.Path)
+	}
+	assert.Equal(t, []string{CodeNotAcceptable, CodeNotAcceptable}, codes)
+	assert.Equal(t, []string{"webhooks[1].accept", "webhooks[1].accept_encoding"}, paths)
+}
+
+func TestNonNegativeBatchHint(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "valid hint",
+			opt:         NonNegativeBatchHint(),
+			in:          &RegistrationV2{BatchHint: BatchHint{MaxLingerDuration: time.Second, MaxMesasges: 10}},
+			str:         "NonNegativeBatchHint()",
+		}, {
+			description: "batching disabled",
+			opt:         NonNegativeBatchHint(),
+			in:          &RegistrationV2{},
+		}, {
+			description: "negative linger",
+			opt:         NonNegativeBatchHint(),
+			in:          &RegistrationV2{BatchHint: BatchHint{MaxLingerDuration: -time.Second}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative messages",
+			opt:         NonNegativeBatchHint(),
+			in:          &RegistrationV2{BatchHint: BatchHint{MaxMesasges: -1}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         NonNegativeBatchHint(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         NonNegativeBatchHint(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestRetryBackoffBounds(t *testing.T) {
+	webhook := func(rh RetryHint) *RegistrationV2 {
+		return &RegistrationV2{Webhooks: []Webhook{{RetryHint: rh}}}
+	}
+	kafka := func(rh RetryHint) *RegistrationV2 {
+		return &RegistrationV2{Kafkas: []Kafka{{RetryHint: rh}}}
+	}
+	opt := RetryBackoffBounds(time.Second, time.Minute, 3)
+
+	run_tests(t, []optionTest{
+		{
+			description: "within bounds",
+			opt:         opt,
+			in:          webhook(RetryHint{InitialBackoff: CustomDuration(time.Second), MaxBackoff: CustomDuration(time.Minute), BackoffMultiplier: 3}),
+			str:         "RetryBackoffBounds(1s, 1m0s, 3)",
+		}, {
+			description: "not set",
+			opt:         opt,
+			in:          webhook(RetryHint{MaxRetry: 3}),
+		}, {
+			description: "unbounded",
+			opt:         RetryBackoffBounds(0, 0, 0),
+			in:          webhook(RetryHint{InitialBackoff: CustomDuration(time.Nanosecond), MaxBackoff: CustomDuration(24 * time.Hour), BackoffMultiplier: 100}),
+		}, {
+			description: "initial backoff too short",
+			opt:         opt,
+			in:          webhook(RetryHint{InitialBackoff: CustomDuration(time.Millisecond)}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "max backoff too long",
+			opt:         opt,
+			in:          webhook(RetryHint{MaxBackoff: CustomDuration(time.Hour)}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "multiplier too large",
+			opt:         opt,
+			in:          webhook(RetryHint{BackoffMultiplier: 4}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid hint is left to NonNegativeRetryHint",
+			opt:         RetryBackoffBounds(0, 0, 0),
+			in:          webhook(RetryHint{BackoffJitter: 2}),
+		}, {
+			description: "kafka out of bounds",
+			opt:         opt,
+			in:          kafka(RetryHint{InitialBackoff: CustomDuration(time.Hour)}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         opt,
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         opt,
+			expectedErr: ErrUknownType,
+		},
+	})
+
+	err := opt.Validate(kafka(RetryHint{InitialBackoff: CustomDuration(time.Hour), MaxBackoff: CustomDuration(time.Hour)}))
+	var paths []string
+	for _, ve := range ValidationErrors(err) {
+		paths = append(paths, ve.Path)
+	}
+	assert.Equal(t, []string{"kafkas[0].retry_hint.initial_backoff", "kafkas[0].retry_hint.max_backoff"}, paths)
+
+	// A negative value is only reported once when both options are used.
+	err = Validators{NonNegativeRetryHint(), opt}.Validate(webhook(RetryHint{InitialBackoff: CustomDuration(-time.Second)}))
+	paths = nil
+	for _, ve := range ValidationErrors(err) {
+		paths = append(paths, ve.Path)
+	}
+	assert.Equal(t, []string{"webhooks[0].retry_hint.initial_backoff"}, paths)
+}
+
+func TestAtLeastOneBootstrapServer(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "has servers",
+			opt:         AtLeastOneBootstrapServer(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{BootstrapServers: []string{"localhost:9092"}}}},
+			str:         "AtLeastOneBootstrapServer()",
+		}, {
+			description: "no kafkas",
+			opt:         AtLeastOneBootstrapServer(),
+			in:          &RegistrationV2{},
+		}, {
+			description: "no servers",
+			opt:         AtLeastOneBootstrapServer(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{BootstrapServers: []string{"localhost:9092"}}, {}}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         AtLeastOneBootstrapServer(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         AtLeastOneBootstrapServer(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestBootstrapServerSyntax(t *testing.T) {
+	invalid := []string{
+		"localhost",
+		"localhost:notaport",
+		"localhost:0",
+		"localhost:65536",
+		":9092",
+		"",
+	}
+
+	tests := []optionTest{
+		{
+			description: "valid servers",
+			opt:         BootstrapServerSyntax(),
+			in: &RegistrationV2{Kafkas: []Kafka{{BootstrapServers: []string{
+				"localhost:9092",
+				"kafka.example.com:1",
+				"10.0.0.1:65535",
+				"[::1]:9092",
+			}}}},
+			str: "BootstrapServerSyntax()",
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         BootstrapServerSyntax(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         BootstrapServerSyntax(),
+			expectedErr: ErrUknownType,
+		},
+	}
+	for _, server := range invalid {
+		tests = append(tests, optionTest{
+			description: "invalid server '" + server + "'",
+			opt:         BootstrapServerSyntax(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{BootstrapServers: []string{"localhost:9092", server}}}},
+			expectedErr: ErrInvalidInput,
+		})
+	}
+
+	run_tests(t, tests)
+}
+
+func TestBootstrapServerPolicy(t *testing.T) {
+	kafka := func(servers ...string) *RegistrationV2 {
+		return &RegistrationV2{Kafkas: []Kafka{{BootstrapServers: servers}}}
+	}
+
+	run_tests(t, []optionTest{
+		{
+			description: "no lists",
+			opt:         BootstrapServerPolicy(nil, nil),
+			in:          kafka("kafka.example.com:9092"),
+			str:         "BootstrapServerPolicy([], [])",
+		}, {
+			description: "allowed host",
+			opt:         BootstrapServerPolicy([]string{"kafka.example.com"}, nil),
+			in:          kafka("kafka.example.com:9092", "KAFKA.example.com:9093"),
+			str:         "BootstrapServerPolicy([kafka.example.com], [])",
+		}, {
+			description: "allowed host and port",
+			opt:         BootstrapServerPolicy([]string{"kafka.example.com:9092"}, nil),
+			in:          kafka("kafka.example.com:9092"),
+		}, {
+			description: "not allowed port",
+			opt:         BootstrapServerPolicy([]string{"kafka.example.com:9092"}, nil),
+			in:          kafka("kafka.example.com:9093"),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "allowed wildcard",
+			opt:         BootstrapServerPolicy([]string{"*.example.com"}, nil),
+			in:          kafka("a.example.com:9092", "b.c.example.com:9092"),
+		}, {
+			description: "wildcard does not match the domain itself",
+			opt:         BootstrapServerPolicy([]string{"*.example.com"}, nil),
+			in:          kafka("example.com:9092"),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "not allowed host",
+			opt:         BootstrapServerPolicy([]string{"*.example.com"}, nil),
+			in:          kafka("a.example.com:9092", "evil.com:9092"),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "denied host",
+			opt:         BootstrapServerPolicy(nil, []string{"localhost", "127.0.0.1"}),
+			in:          kafka("localhost:9092"),
+			str:         "BootstrapServerPolicy([], [localhost, 127.0.0.1])",
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "deny takes precedence",
+			opt:         BootstrapServerPolicy([]string{"*.example.com"}, []string{"internal.example.com"}),
+			in:          kafka("internal.example.com:9092"),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid syntax is ignored",
+			opt:         BootstrapServerPolicy([]string{"*.example.com"}, nil),
+			in:          kafka("localhost"),
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         BootstrapServerPolicy(nil, nil),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         BootstrapServerPolicy(nil, nil),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSupportedKafkaAccept(t *testing.T) {
+	run_tests(t, []optionTest{
+		{
+			description: "supported accepts",
+			opt:         SupportedKafkaAccept(),
+			in: &RegistrationV2{Kafkas: []Kafka{
+				{Accept: MediaTypeOctetStream},
+				{Accept: MediaTypeJSON},
+				{Accept: MediaTypeJSONL},
+				{Accept: MediaTypeMsgpack},
+				{},
+			}},
+			str: "SupportedKafkaAccept()",
+		}, {
+			description: "negotiable accept",
+			opt:         SupportedKafkaAccept(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{Accept: "application/wrp+json, application/json;q=0.5"}}},
+		}, {
+			description: "unsupported accept",
+			opt:         SupportedKafkaAccept(),
+			in:          &RegistrationV2{Kafkas: []Kafka{{Accept: MediaTypeWRPJSON}}},
+			expectedErr: ErrNotAcceptable,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SupportedKafkaAccept(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SupportedKafkaAccept(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestKafkaRetryHintBounds(t *testing.T) {
+	kafka := func(rh RetryHint) *RegistrationV2 {
+		return &RegistrationV2{Kafkas: []Kafka{{RetryHint: rh}}}
+	}
+
+	run_tests(t, []optionTest{
+		{
+			description: "within bounds",
+			opt:         KafkaRetryHintBounds(2, 5),
+			in:          kafka(RetryHint{RetryEachUrl: 2, MaxRetry: 5}),
+			str:         "KafkaRetryHintBounds(2, 5)",
+		}, {
+			description: "unbounded",
+			opt:         KafkaRetryHintBounds(0, 0),
+			in:          kafka(RetryHint{RetryEachUrl: 200, MaxRetry: 500}),
+		}, {
+			description: "negative",
+			opt:         KafkaRetryHintBounds(0, 0),
+			in:          kafka(RetryHint{MaxRetry: -1}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "retry each url too large",
+			opt:         KafkaRetryHintBounds(2, 5),
+			in:          kafka(RetryHint{RetryEachUrl: 3}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "max retry too large",
+			opt:         KafkaRetryHintBounds(2, 5),
+			in:          kafka(RetryHint{MaxRetry: 6}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         KafkaRetryHintBounds(2, 5),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         KafkaRetryHintBounds(2, 5),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestValidKafkaProducer(t *testing.T) {
+	producer := func(kp KafkaProducer) *RegistrationV2 {
+		return &RegistrationV2{Kafkas: []Kafka{{KafkaProducer: kp}}}
+	}
+	caCert := "-----BEGIN CERTIFICATE-----\nMA==\n-----END CERTIFICATE-----\n"
+
+	run_tests(t, []optionTest{
+		{
+			description: "defaults",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{}),
+			str:         "ValidKafkaProducer()",
+		}, {
+			description: "all values",
+			opt:         ValidKafkaProducer(),
+			in: producer(KafkaProducer{
+				Compression:      KafkaCompressionZstd,
+				CompressionLevel: 22,
+				RequiredAcks:     KafkaAcksAll,
+				Idempotent:       true,
+				Linger:           CustomDuration(5 * time.Millisecond),
+				MaxMessageBytes:  1000000,
+				SASL:             KafkaSASL{Mechanism: KafkaSASLScramSHA512, User: "user", Password: "password"},
+				TLS:              KafkaTLS{Enable: true, ServerName: "kafka.example.com", CACert: caCert},
+			}),
+		}, {
+			description: "unknown compression",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Compression: "brotli"}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "compression level without levels",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Compression: KafkaCompressionSnappy, CompressionLevel: 3}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "compression level out of range",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Compression: KafkaCompressionGzip, CompressionLevel: 10}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "unknown acks",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{RequiredAcks: "-1"}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "idempotent with default acks",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Idempotent: true}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "idempotent with acks local",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Idempotent: true, RequiredAcks: KafkaAcksLocal}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative linger",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{Linger: CustomDuration(-time.Second)}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative max message bytes",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{MaxMessageBytes: -1}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "unknown sasl mechanism",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{SASL: KafkaSASL{Mechanism: "GSSAPI", User: "user", Password: "password"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "sasl mechanism without credentials",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{SASL: KafkaSASL{Mechanism: KafkaSASLPlain, User: "user"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "sasl credentials without mechanism",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{SASL: KafkaSASL{User: "user", Password: "password"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "tls settings without tls",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{TLS: KafkaTLS{ServerName: "kafka.example.com"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid ca cert",
+			opt:         ValidKafkaProducer(),
+			in:          producer(KafkaProducer{TLS: KafkaTLS{Enable: true, CACert: "not a cert"}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         ValidKafkaProducer(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         ValidKafkaProducer(),
+			expectedErr: ErrUknownType,
+		},
+	})
+}
+
+func TestSecureKafkaProducer(t *testing.T) {
+	producer := func(kp KafkaProducer) *RegistrationV2 {
+		return &RegistrationV2{Kafkas: []Kafka{{KafkaProducer: kp}}}
+	}
+	sasl := KafkaSASL{Mechanism: KafkaSASLPlain, User: "user", Password: "password"}
+
+	run_tests(t, []optionTest{
+		{
+			description: "no credentials",
+			opt:         SecureKafkaProducer(),
+			in:          producer(KafkaProducer{}),
+			str:         "SecureKafkaProducer()",
+		}, {
+			description: "credentials with tls",
+			opt:         SecureKafkaProducer(),
+			in:          producer(KafkaProducer{SASL: sasl, TLS: KafkaTLS{Enable: true}}),
+		}, {
+			description: "credentials without tls",
+			opt:         SecureKafkaProducer(),
+			in:          producer(KafkaProducer{SASL: sasl}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "insecure skip verify",
+			opt:         SecureKafkaProducer(),
+			in:          producer(KafkaProducer{TLS: KafkaTLS{Enable: true, InsecureSkipVerify: true}}),
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid type - RegistrationV1",
+			opt:         SecureKafkaProducer(),
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidType,
+		}, {
+			description: "default case - unknown",
+			opt:         SecureKafkaProducer(),
+			expectedErr: ErrUknownType,
+		},
 	})
 }
 
@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// RedactedValue replaces the value of credentials in redacted output.
+const RedactedValue = "[REDACTED]"
+
+// Credentials are marked with the `redact:"true"` struct tag.  Any string
+// field with the tag is replaced with RedactedValue (unless it is empty) when
+// a value is redacted, so new credentials only need to be tagged.
+const redactTag = "redact"
+
+// MarshalRedacted returns the json encoding of v with all of the credentials
+// replaced by RedactedValue.  Use it instead of json.Marshal when the output
+// is logged or returned to a client.
+func MarshalRedacted(v any) ([]byte, error) {
+	if v == nil {
+		return json.Marshal(v)
+	}
+	return json.Marshal(redactValue(reflect.ValueOf(v)).Interface())
+}
+
+// redact returns a copy of v with all of the credentials replaced.
+func redact[T any](v T) T {
+	return redactValue(reflect.ValueOf(&v).Elem()).Interface().(T)
+}
+
+// redactValue returns a deep copy of v with all of the string fields tagged
+// with the redact tag replaced with RedactedValue.
+func redactValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		p := reflect.New(v.Type().Elem())
+		p.Elem().Set(redactValue(v.Elem()))
+		return p
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		i := reflect.New(v.Type()).Elem()
+		i.Set(redactValue(v.Elem()))
+		return i
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		s := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			s.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return s
+	case reflect.Array:
+		a := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			a.Index(i).Set(redactValue(v.Index(i)))
+		}
+		return a
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		m := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m.SetMapIndex(iter.Key(), redactValue(iter.Value()))
+		}
+		return m
+	case reflect.Struct:
+		s := reflect.New(v.Type()).Elem()
+		s.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Type().Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			if f.Tag.Get(redactTag) == "true" && f.Type.Kind() == reflect.String {
+				if v.Field(i).Len() > 0 {
+					s.Field(i).SetString(RedactedValue)
+				}
+				continue
+			}
+			s.Field(i).Set(redactValue(v.Field(i)))
+		}
+		return s
+	}
+
+	return v
+}
+
+// Redacted returns a copy of the DeliveryConfig with the Secret redacted.
+func (dc DeliveryConfig) Redacted() DeliveryConfig {
+	return redact(dc)
+}
+
+// Redacted returns a copy of the Webhook with the Secret redacted.
+func (w Webhook) Redacted() Webhook {
+	return redact(w)
+}
+
+// Redacted returns a copy of the Kafka with all credentials redacted.
+func (k Kafka) Redacted() Kafka {
+	return redact(k)
+}
+
+// Redacted returns a copy of the KafkaProducer with the SASL Password
+// redacted.
+func (kp KafkaProducer) Redacted() KafkaProducer {
+	return redact(kp)
+}
+
+// Redacted returns a copy of the KafkaSASL with the Password redacted.
+func (ks KafkaSASL) Redacted() KafkaSASL {
+	return redact(ks)
+}
+
+// Redacted returns a copy of the RegistrationV1 with all credentials redacted.
+func (v1 RegistrationV1) Redacted() RegistrationV1 {
+	return redact(v1)
+}
+
+// Redacted returns a copy of the RegistrationV2 with all credentials redacted.
+func (v2 RegistrationV2) Redacted() RegistrationV2 {
+	return redact(v2)
+}
+
+// Format formats the DeliveryConfig with the Secret redacted.
+func (dc DeliveryConfig) Format(f fmt.State, verb rune) {
+	type deliveryConfig DeliveryConfig
+	fmt.Fprintf(f, fmt.FormatString(f, verb), deliveryConfig(dc.Redacted()))
+}
+
+// Format formats the Webhook with the Secret redacted.
+func (w Webhook) Format(f fmt.State, verb rune) {
+	type webhook Webhook
+	fmt.Fprintf(f, fmt.FormatString(f, verb), webhook(w.Redacted()))
+}
+
+// Format formats the Kafka with all credentials redacted.
+func (k Kafka) Format(f fmt.State, verb rune) {
+	type kafka Kafka
+	fmt.Fprintf(f, fmt.FormatString(f, verb), kafka(k.Redacted()))
+}
+
+// Format formats the KafkaProducer with the SASL Password redacted.
+func (kp KafkaProducer) Format(f fmt.State, verb rune) {
+	type kafkaProducer KafkaProducer
+	fmt.Fprintf(f, fmt.FormatString(f, verb), kafkaProducer(kp.Redacted()))
+}
+
+// Format formats the KafkaSASL with the Password redacted.
+func (ks KafkaSASL) Format(f fmt.State, verb rune) {
+	type kafkaSASL KafkaSASL
+	fmt.Fprintf(f, fmt.FormatString(f, verb), kafkaSASL(ks.Redacted()))
+}
+
+// Format formats the RegistrationV1 with all credentials redacted.
+func (v1 RegistrationV1) Format(f fmt.State, verb rune) {
+	type registrationV1 RegistrationV1
+	fmt.Fprintf(f, fmt.FormatString(f, verb), registrationV1(v1.Redacted()))
+}
+
+// Format formats the RegistrationV2 with all credentials redacted.
+func (v2 RegistrationV2) Format(f fmt.State, verb rune) {
+	type registrationV2 RegistrationV2
+	fmt.Fprintf(f, fmt.FormatString(f, verb), registrationV2(v2.Redacted()))
+}
+
+func (dc DeliveryConfig) String() string {
+	return fmt.Sprintf("%+v", dc)
+}
+
+func (w Webhook) String() string {
+	return fmt.Sprintf("%+v", w)
+}
+
+func (k Kafka) String() string {
+	return fmt.Sprintf("%+v", k)
+}
+
+func (kp KafkaProducer) String() string {
+	return fmt.Sprintf("%+v", kp)
+}
+
+func (ks KafkaSASL) String() string {
+	return fmt.Sprintf("%+v", ks)
+}
+
+func (v1 RegistrationV1) String() string {
+	return fmt.Sprintf("%+v", v1)
+}
+
+func (v2 RegistrationV2) String() string {
+	return fmt.Sprintf("%+v", v2)
+}
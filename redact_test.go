@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/urlegit"
+)
+
+const testSecret = "super-secret-value"
+
+func secretRegistrations() (*RegistrationV1, *RegistrationV2) {
+	v1 := &RegistrationV1{
+		Config: DeliveryConfig{
+			ReceiverURL: "https://example.com",
+			Secret:      testSecret,
+		},
+		Events: []string{".*"},
+	}
+	v2 := &RegistrationV2{
+		CanonicalName: "example",
+		Webhooks: []Webhook{
+			{ReceiverURLs: []string{"http://example.com"}, Secret: testSecret},
+			{ReceiverURLs: []string{"https://example.com"}},
+		},
+		Kafkas: []Kafka{{
+			BootstrapServers: []string{"localhost:9092"},
+			KafkaProducer: KafkaProducer{
+				SASL: KafkaSASL{Mechanism: KafkaSASLPlain, User: "user", Password: testSecret},
+			},
+		}},
+	}
+	return v1, v2
+}
+
+func TestFormatRedacts(t *testing.T) {
+	v1, v2 := secretRegistrations()
+
+	values := []any{
+		v1, *v1, v1.Config,
+		v2, *v2, v2.Webhooks[0], v2.Webhooks,
+		v2.Kafkas, v2.Kafkas[0], &v2.Kafkas[0], v2.Kafkas[0].KafkaProducer, v2.Kafkas[0].KafkaProducer.SASL,
+	}
+	verbs := []string{"%v", "%+v", "%#v", "%s"}
+
+	for _, v := range values {
+		for _, verb := range verbs {
+			out := fmt.Sprintf(verb, v)
+			assert.NotContains(t, out, testSecret, "%s %T", verb, v)
+			assert.Contains(t, out, RedactedValue, "%s %T", verb, v)
+		}
+	}
+
+	assert.NotContains(t, v1.String(), testSecret)
+	assert.NotContains(t, v1.Config.String(), testSecret)
+	assert.NotContains(t, v2.String(), testSecret)
+	assert.NotContains(t, v2.Webhooks[0].String(), testSecret)
+	assert.Contains(t, v2.String(), "https://example.com")
+	assert.NotContains(t, v2.Kafkas[0].String(), testSecret)
+	assert.NotContains(t, v2.Kafkas[0].KafkaProducer.SASL.String(), testSecret)
+	assert.Contains(t, v2.Kafkas[0].KafkaProducer.SASL.String(), "user")
+
+	// the originals are not modified
+	assert.Equal(t, testSecret, v1.Config.Secret)
+	assert.Equal(t, testSecret, v2.Webhooks[0].Secret)
+	assert.Equal(t, testSecret, v2.Kafkas[0].KafkaProducer.SASL.Password)
+}
+
+func TestRedacted(t *testing.T) {
+	v1, v2 := secretRegistrations()
+
+	r1 := v1.Redacted()
+	assert.Equal(t, RedactedValue, r1.Config.Secret)
+	assert.Equal(t, v1.Config.ReceiverURL, r1.Config.ReceiverURL)
+
+	r2 := v2.Redacted()
+	assert.Equal(t, RedactedValue, r2.Webhooks[0].Secret)
+	assert.Empty(t, r2.Webhooks[1].Secret, "empty values stay empty")
+	assert.Equal(t, RedactedValue, r2.Kafkas[0].KafkaProducer.SASL.Password)
+	assert.Equal(t, "user", r2.Kafkas[0].KafkaProducer.SASL.User)
+
+	// the copy does not share memory with the original
+	r2.Webhooks[1].ReceiverURLs[0] = "changed"
+	assert.Equal(t, "https://example.com", v2.Webhooks[1].ReceiverURLs[0])
+	assert.Equal(t, testSecret, v2.Webhooks[0].Secret)
+}
+
+func TestMarshalRedacted(t *testing.T) {
+	v1, v2 := secretRegistrations()
+
+	for _, v := range []any{v1, *v1, v2, *v2, []any{v2}, map[string]any{"r": v2}} {
+		b, err := MarshalRedacted(v)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), testSecret, "%T", v)
+		assert.Contains(t, string(b), RedactedValue, "%T", v)
+	}
+
+	b, err := MarshalRedacted(nil)
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(b))
+
+	// the regular json encoding keeps the secrets so they can be stored
+	b, err = json.Marshal(v2)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), testSecret)
+
+	var nilV2 *RegistrationV2
+	b, err = MarshalRedacted(nilV2)
+	require.NoError(t, err)
+	assert.Equal(t, "null", string(b))
+}
+
+func TestValidationErrorsRedact(t *testing.T) {
+	checker, err := urlegit.New(urlegit.OnlyAllowSchemes("https"))
+	require.NoError(t, err)
+
+	_, v2 := secretRegistrations()
+	err = ProvideReceiverURLValidator(checker).Validate(v2)
+	require.ErrorIs(t, err, ErrInvalidInput)
+	assert.NotContains(t, err.Error(), testSecret)
+}
@@ -0,0 +1,440 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RegistrationKey identifies a registration in a Registry.  A RegistrationV1
+// is identified by its receiver URL and a RegistrationV2 by its
+// CanonicalName.
+type RegistrationKey struct {
+	// Version is the version of the registration, either 1 or 2.
+	Version int
+
+	// ID is the receiver URL of a RegistrationV1 or the CanonicalName of a
+	// RegistrationV2.
+	ID string
+}
+
+func (k RegistrationKey) String() string {
+	return fmt.Sprintf("v%d:%s", k.Version, k.ID)
+}
+
+// KeyOf returns the RegistrationKey of the registration.
+func KeyOf(r Registration) (RegistrationKey, error) {
+	switch r := r.(type) {
+	case *RegistrationV1:
+		if r.Config.ReceiverURL == "" {
+			return RegistrationKey{}, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeRequired,
+				Path:    "config.url",
+				Message: "a receiver url is required",
+			}
+		}
+		return RegistrationKey{Version: 1, ID: r.Config.ReceiverURL}, nil
+	case *RegistrationV2:
+		if r.CanonicalName == "" {
+			return RegistrationKey{}, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeRequired,
+				Path:    "canonical_name",
+				Message: "a canonical name is required",
+			}
+		}
+		return RegistrationKey{Version: 2, ID: r.CanonicalName}, nil
+	default:
+		return RegistrationKey{}, ErrUknownType
+	}
+}
+
+// expiresAt returns when the registration expires if it was added at the
+// time passed in, or the zero time if it never expires.  A RegistrationV1
+// expires at its Until time, or its Duration after it was added.  A
+// RegistrationV2 expires at its Expires time.
+func expiresAt(r Registration, added time.Time) time.Time {
+	switch r := r.(type) {
+	case *RegistrationV1:
+		if !r.Until.IsZero() {
+			return r.Until.Time
+		}
+		if r.Duration > 0 {
+			return added.Add(time.Duration(r.Duration))
+		}
+	case *RegistrationV2:
+		return r.Expires.Time
+	}
+	return time.Time{}
+}
+
+// RegistryEventType is the kind of change described by a RegistryEvent.
+type RegistryEventType int
+
+const (
+	// RegistryAdded means a registration with a new key was added.
+	RegistryAdded RegistryEventType = iota + 1
+
+	// RegistryUpdated means a registration replaced the registration with the
+	// same key.
+	RegistryUpdated
+
+	// RegistryExpired means a registration was removed because it expired.
+	RegistryExpired
+
+	// RegistryDeleted means a registration was deleted.
+	RegistryDeleted
+)
+
+func (t RegistryEventType) String() string {
+	switch t {
+	case RegistryAdded:
+		return "added"
+	case RegistryUpdated:
+		return "updated"
+	case RegistryExpired:
+		return "expired"
+	case RegistryDeleted:
+		return "deleted"
+	default:
+		return fmt.Sprintf("RegistryEventType(%d)", int(t))
+	}
+}
+
+// RegistryEvent describes a change to a Registry.
+type RegistryEvent struct {
+	// Type is the kind of change.
+	Type RegistryEventType
+
+	// Key is the key of the registration that changed.
+	Key RegistrationKey
+
+	// Registration is the registration that was added, updated, expired or
+	// deleted.
+	Registration Registration
+
+	// Previous is the registration that was replaced by an update, otherwise
+	// it is nil.
+	Previous Registration
+
+	// ExpiresAt is when the Registration expires, or the zero time if it
+	// never expires.
+	ExpiresAt time.Time
+}
+
+// registryEntry is a registration held by a Registry.
+type registryEntry struct {
+	key          RegistrationKey
+	registration Registration
+	expiresAt    time.Time
+	timer        Timer
+}
+
+// expired returns true if the entry has expired by now, even if its timer has
+// not fired yet.
+func (e *registryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !e.expiresAt.After(now)
+}
+
+// Registry is an in memory store of RegistrationV1 and RegistrationV2
+// registrations.  Adding a registration with the same key as an existing one
+// replaces it, so reusing a CanonicalName overrides the earlier
+// registration.  Registrations are removed once they expire.
+//
+// A Registry is safe for concurrent use.  The registrations it holds must
+// not be modified.
+type Registry struct {
+	validators Validators
+	clock      Clock
+
+	seq sequencer
+
+	mu          sync.Mutex
+	entries     map[RegistrationKey]*registryEntry
+	subscribers map[int]func(RegistryEvent)
+	nextID      int
+}
+
+// NewRegistry creates an empty Registry that validates the registrations
+// with the validators before they are added.  If the clock is nil, the
+// system clock is used.
+func NewRegistry(validators Validators, clock Clock) *Registry {
+	return &Registry{
+		validators:  validators,
+		clock:       clockOrSystem(clock),
+		entries:     make(map[RegistrationKey]*registryEntry),
+		subscribers: make(map[int]func(RegistryEvent)),
+	}
+}
+
+// Upsert validates the registration and adds it, replacing any registration
+// with the same key.  A registration that has already expired is rejected.
+//
+// The registration is given the clock of the Registry with SetNowFunc before
+// it is validated, so the validators and the expiration agree on the time.
+func (r *Registry) Upsert(reg Registration) (RegistrationKey, error) {
+	key, err := KeyOf(reg)
+	if err != nil {
+		return RegistrationKey{}, err
+	}
+
+	switch reg := reg.(type) {
+	case *RegistrationV1:
+		reg.SetNowFunc(r.clock.Now)
+	case *RegistrationV2:
+		reg.SetNowFunc(r.clock.Now)
+	}
+	if err = r.validators.Validate(reg); err != nil {
+		return RegistrationKey{}, err
+	}
+
+	r.mu.Lock()
+	now := r.clock.Now()
+	e := &registryEntry{
+		key:          key,
+		registration: reg,
+		expiresAt:    expiresAt(reg, now),
+	}
+	if e.expired(now) {
+		r.mu.Unlock()
+		return RegistrationKey{}, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeExpired,
+			Value:   e.expiresAt,
+			Message: "the registration has already expired",
+		}
+	}
+
+	r.put(e, now)
+	return key, nil
+}
+
+// put adds the entry, replacing the entry with the same key, and releases mu.
+// mu must be held.
+func (r *Registry) put(e *registryEntry, now time.Time) {
+	event := RegistryEvent{
+		Type:         RegistryAdded,
+		Key:          e.key,
+		Registration: e.registration,
+		ExpiresAt:    e.expiresAt,
+	}
+	if prev := r.entries[e.key]; prev != nil {
+		r.stop(prev)
+		event.Type = RegistryUpdated
+		event.Previous = prev.registration
+	}
+
+	r.entries[e.key] = e
+	if !e.expiresAt.IsZero() {
+		e.timer = r.clock.AfterFunc(e.expiresAt.Sub(now), func() {
+			r.expire(e)
+		})
+	}
+	r.release(event)
+}
+
+// Get returns the registration with the key, if it has not expired.
+func (r *Registry) Get(key RegistrationKey) (Registration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, found := r.entries[key]
+	if !found || e.expired(r.clock.Now()) {
+		return nil, false
+	}
+	return e.registration, true
+}
+
+// ExpiresAt returns when the registration with the key expires, or the zero
+// time if it never expires.  The bool is false if there is no registration
+// with the key.
+func (r *Registry) ExpiresAt(key RegistrationKey) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, found := r.entries[key]
+	if !found || e.expired(r.clock.Now()) {
+		return time.Time{}, false
+	}
+	return e.expiresAt, true
+}
+
+// Delete removes the registration with the key.  It returns false if there
+// is no registration with the key.
+func (r *Registry) Delete(key RegistrationKey) bool {
+	r.mu.Lock()
+	e, found := r.entries[key]
+	if !found {
+		r.mu.Unlock()
+		return false
+	}
+
+	r.stop(e)
+	delete(r.entries, key)
+	r.release(RegistryEvent{
+		Type:         RegistryDeleted,
+		Key:          key,
+		Registration: e.registration,
+		ExpiresAt:    e.expiresAt,
+	})
+	return true
+}
+
+// List returns the registrations that have not expired, ordered by version
+// and then by ID.
+func (r *Registry) List() []Registration {
+	r.mu.Lock()
+	now := r.clock.Now()
+	entries := make([]*registryEntry, 0, len(r.entries))
+	for _, e := range r.entries {
+		if !e.expired(now) {
+			entries = append(entries, e)
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(entries, func(i, j int) bool {
+		return keyLess(entries[i].key, entries[j].key)
+	})
+
+	list := make([]Registration, len(entries))
+	for i, e := range entries {
+		list[i] = e.registration
+	}
+	return list
+}
+
+// Len returns the number of registrations that have not expired.
+func (r *Registry) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.clock.Now()
+	var n int
+	for _, e := range r.entries {
+		if !e.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// SubscribeOption changes how Registry.Subscribe delivers the events.
+type SubscribeOption func(*subscribeConfig)
+
+type subscribeConfig struct {
+	replay bool
+}
+
+// ReplayExisting makes Subscribe first deliver a RegistryAdded event for
+// each registration already in the Registry, ordered by version and then by
+// ID.  The registrations are read and the subscription is made at once, so
+// the following events pick up exactly where the replayed ones leave off.
+// A registration that has expired but has not been removed yet is replayed
+// too, and its RegistryExpired event follows.
+func ReplayExisting() SubscribeOption {
+	return func(c *subscribeConfig) {
+		c.replay = true
+	}
+}
+
+// Subscribe calls fn with each change to the Registry, in the order the
+// changes happen, until the returned cancel function is called.  The
+// function may read the Registry, but must not add, update or delete
+// registrations.
+func (r *Registry) Subscribe(fn func(RegistryEvent), opts ...SubscribeOption) (cancel func()) {
+	var c subscribeConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	r.mu.Lock()
+	id := r.nextID
+	r.nextID++
+	r.subscribers[id] = fn
+
+	var replay []RegistryEvent
+	if c.replay {
+		replay = make([]RegistryEvent, 0, len(r.entries))
+		for _, e := range r.entries {
+			replay = append(replay, RegistryEvent{
+				Type:         RegistryAdded,
+				Key:          e.key,
+				Registration: e.registration,
+				ExpiresAt:    e.expiresAt,
+			})
+		}
+		sort.Slice(replay, func(i, j int) bool {
+			return keyLess(replay[i].Key, replay[j].Key)
+		})
+	}
+	t := r.seq.ticket()
+	r.mu.Unlock()
+
+	r.seq.run(t, func() {
+		for _, event := range replay {
+			fn(event)
+		}
+	})
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			delete(r.subscribers, id)
+		})
+	}
+}
+
+// expire removes the entry if it is still in the Registry.
+func (r *Registry) expire(e *registryEntry) {
+	r.mu.Lock()
+	if r.entries[e.key] != e {
+		r.mu.Unlock()
+		return
+	}
+
+	delete(r.entries, e.key)
+	r.release(RegistryEvent{
+		Type:         RegistryExpired,
+		Key:          e.key,
+		Registration: e.registration,
+		ExpiresAt:    e.expiresAt,
+	})
+}
+
+// stop stops the expiration timer of the entry.  mu must be held.
+func (r *Registry) stop(e *registryEntry) {
+	if e.timer != nil {
+		e.timer.Stop()
+		e.timer = nil
+	}
+}
+
+// release unlocks mu and passes the event to the subscribers.  mu must be
+// held.
+func (r *Registry) release(event RegistryEvent) {
+	subscribers := make([]func(RegistryEvent), 0, len(r.subscribers))
+	ids := make([]int, 0, len(r.subscribers))
+	for id := range r.subscribers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		subscribers = append(subscribers, r.subscribers[id])
+	}
+
+	t := r.seq.ticket()
+	r.mu.Unlock()
+	r.seq.run(t, func() {
+		for _, fn := range subscribers {
+			fn(event)
+		}
+	})
+}
@@ -0,0 +1,483 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// eventRecorder records the events of a Registry.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []RegistryEvent
+}
+
+func (er *eventRecorder) record(e RegistryEvent) {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	er.events = append(er.events, e)
+}
+
+// types returns the types of the recorded events and forgets them.
+func (er *eventRecorder) types() []RegistryEventType {
+	er.mu.Lock()
+	defer er.mu.Unlock()
+	var list []RegistryEventType
+	for _, e := range er.events {
+		list = append(list, e.Type)
+	}
+	er.events = nil
+	return list
+}
+
+func TestKeyOf(t *testing.T) {
+	tests := []struct {
+		description string
+		in          Registration
+		expected    RegistrationKey
+		expectedErr error
+	}{
+		{
+			description: "v1",
+			in:          &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "https://a.example.com"}},
+			expected:    RegistrationKey{Version: 1, ID: "https://a.example.com"},
+		}, {
+			description: "v2",
+			in:          &RegistrationV2{CanonicalName: "a"},
+			expected:    RegistrationKey{Version: 2, ID: "a"},
+		}, {
+			description: "v1 without url",
+			in:          &RegistrationV1{},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "v2 without name",
+			in:          &RegistrationV2{},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "unknown",
+			expectedErr: ErrUknownType,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			got, err := KeyOf(tc.in)
+			assert.ErrorIs(t, err, tc.expectedErr)
+			assert.Equal(t, tc.expected, got)
+		})
+	}
+
+	assert.Equal(t, "v2:a", RegistrationKey{Version: 2, ID: "a"}.String())
+	assert.Equal(t, "expired", RegistryExpired.String())
+	assert.Equal(t, "RegistryEventType(0)", RegistryEventType(0).String())
+}
+
+func TestRegistryOverride(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var rec eventRecorder
+	r := NewRegistry(nil, newFakeClock())
+	cancel := r.Subscribe(rec.record)
+
+	first := &RegistrationV2{CanonicalName: "a", FailureURL: "first"}
+	second := &RegistrationV2{CanonicalName: "a", FailureURL: "second"}
+	v1 := &RegistrationV1{Config: DeliveryConfig{ReceiverURL: "a"}}
+
+	key, err := r.Upsert(first)
+	require.NoError(err)
+	assert.Equal(RegistrationKey{Version: 2, ID: "a"}, key)
+
+	_, err = r.Upsert(second)
+	require.NoError(err)
+
+	// A V1 registration with the same ID does not collide with the V2 one.
+	_, err = r.Upsert(v1)
+	require.NoError(err)
+
+	got, found := r.Get(key)
+	assert.True(found)
+	assert.Same(second, got)
+	assert.Equal([]Registration{v1, second}, r.List())
+	assert.Equal(2, r.Len())
+
+	rec.mu.Lock()
+	require.Len(rec.events, 3)
+	assert.Same(first, rec.events[1].Previous)
+	assert.Same(second, rec.events[1].Registration)
+	rec.mu.Unlock()
+	assert.Equal([]RegistryEventType{RegistryAdded, RegistryUpdated, RegistryAdded}, rec.types())
+
+	assert.True(r.Delete(key))
+	assert.False(r.Delete(key))
+	_, found = r.Get(key)
+	assert.False(found)
+	assert.Equal([]RegistryEventType{RegistryDeleted}, rec.types())
+
+	cancel()
+	cancel()
+	_, err = r.Upsert(first)
+	require.NoError(err)
+	assert.Empty(rec.types())
+}
+
+func TestRegistryValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	var rec eventRecorder
+	clock := newFakeClock()
+	r := NewRegistry(Validators{AtLeastOneEvent()}, clock)
+	r.Subscribe(rec.record)
+
+	_, err := r.Upsert(&RegistrationV1{Config: DeliveryConfig{ReceiverURL: "a"}})
+	assert.ErrorIs(err, ErrInvalidInput)
+
+	_, err = r.Upsert(&RegistrationV1{})
+	assert.ErrorIs(err, ErrInvalidInput)
+
+	_, err = r.Upsert(&RegistrationV1{
+		Config: DeliveryConfig{ReceiverURL: "a"},
+		Events: []string{".*"},
+		Until:  CustomTime{Time: clock.Now()},
+	})
+	if ves := ValidationErrors(err); assert.Len(ves, 1) {
+		assert.Equal(CodeExpired, ves[0].Code)
+	}
+
+	assert.Zero(r.Len())
+	assert.Empty(rec.types())
+}
+
+func TestRegistryValidationClock(t *testing.T) {
+	assert := assert.New(t)
+
+	// The fake clock is far from time.Now, so the validators only agree with
+	// the expiration if they use the clock of the Registry.
+	clock := newFakeClock()
+	r := NewRegistry(Validators{ValidateRegistrationDuration(time.Hour)}, clock)
+
+	_, err := r.Upsert(&RegistrationV1{
+		Config: DeliveryConfig{ReceiverURL: "until"},
+		Events: []string{".*"},
+		Until:  CustomTime{Time: clock.Now().Add(30 * time.Minute)},
+	})
+	assert.NoError(err)
+
+	_, err = r.Upsert(&RegistrationV2{
+		CanonicalName: "expires",
+		Expires:       CustomTime{Time: clock.Now().Add(30 * time.Minute)},
+	})
+	assert.NoError(err)
+
+	_, err = r.Upsert(&RegistrationV2{
+		CanonicalName: "too long",
+		Expires:       CustomTime{Time: clock.Now().Add(2 * time.Hour)},
+	})
+	assert.ErrorIs(err, ErrInvalidInput)
+
+	// A relative time is resolved against the clock of the Registry.
+	var v2 RegistrationV2
+	require.NoError(t, json.Unmarshal([]byte(`{"canonical_name":"relative","expires":"+30m"}`), &v2))
+	_, err = r.Upsert(&v2)
+	assert.NoError(err)
+	at, found := r.ExpiresAt(RegistrationKey{Version: 2, ID: "relative"})
+	assert.True(found)
+	assert.Equal(clock.Now().Add(30*time.Minute), at)
+
+	assert.Equal(3, r.Len())
+}
+
+func TestRegistryExpiration(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var rec eventRecorder
+	clock := newFakeClock()
+	r := NewRegistry(nil, clock)
+	r.Subscribe(rec.record)
+
+	until := &RegistrationV1{
+		Config: DeliveryConfig{ReceiverURL: "until"},
+		Until:  CustomTime{Time: clock.Now().Add(time.Hour)},
+	}
+	duration := &RegistrationV1{
+		Config:   DeliveryConfig{ReceiverURL: "duration"},
+		Duration: CustomDuration(2 * time.Hour),
+	}
+	expires := &RegistrationV2{
+		CanonicalName: "expires",
+		Expires:       CustomTime{Time: clock.Now().Add(3 * time.Hour)},
+	}
+	forever := &RegistrationV2{CanonicalName: "forever"}
+
+	for _, reg := range []Registration{until, duration, expires, forever} {
+		_, err := r.Upsert(reg)
+		require.NoError(err)
+	}
+	assert.Equal(4, r.Len())
+	rec.types()
+
+	at, found := r.ExpiresAt(RegistrationKey{Version: 1, ID: "duration"})
+	assert.True(found)
+	assert.Equal(clock.Now().Add(2*time.Hour), at)
+	at, found = r.ExpiresAt(RegistrationKey{Version: 2, ID: "forever"})
+	assert.True(found)
+	assert.True(at.IsZero())
+	_, found = r.ExpiresAt(RegistrationKey{Version: 2, ID: "missing"})
+	assert.False(found)
+
+	clock.Advance(time.Hour)
+	assert.Equal([]Registration{duration, expires, forever}, r.List())
+	assert.Equal([]RegistryEventType{RegistryExpired}, rec.types())
+
+	// Extending the registration replaces its expiration.
+	extended := &RegistrationV2{
+		CanonicalName: "expires",
+		Expires:       CustomTime{Time: clock.Now().Add(5 * time.Hour)},
+	}
+	_, err := r.Upsert(extended)
+	require.NoError(err)
+	rec.types()
+
+	clock.Advance(3 * time.Hour)
+	assert.Equal([]Registration{extended, forever}, r.List())
+	assert.Equal([]RegistryEventType{RegistryExpired}, rec.types())
+
+	clock.Advance(24 * time.Hour)
+	assert.Equal([]Registration{forever}, r.List())
+	assert.Equal([]RegistryEventType{RegistryExpired}, rec.types())
+}
+
+func TestRegistryConcurrent(t *testing.T) {
+	r := NewRegistry(nil, nil)
+
+	var mu sync.Mutex
+	counts := make(map[RegistryEventType]int)
+	r.Subscribe(func(e RegistryEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		counts[e.Type]++
+	})
+
+	names := []string{"a", "b", "c", "d"}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				_, err := r.Upsert(&RegistrationV2{CanonicalName: names[j%len(names)]})
+				assert.NoError(t, err)
+				r.List()
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, len(names), r.Len())
+	assert.Equal(t, len(names), counts[RegistryAdded])
+	assert.Equal(t, 8*50-len(names), counts[RegistryUpdated])
+}
+
+func TestRegistrySubscriberReads(t *testing.T) {
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		found    []bool
+		upserted = make(chan struct{})
+	)
+	r := NewRegistry(nil, nil)
+	r.Subscribe(func(e RegistryEvent) {
+		if e.Key.ID == "a" {
+			// Another registration is added, and waits for its event to be
+			// delivered, while this one is being delivered.
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := r.Upsert(&RegistrationV2{CanonicalName: "b"})
+				assert.NoError(t, err)
+				close(upserted)
+			}()
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		_, ok := r.Get(e.Key)
+		mu.Lock()
+		defer mu.Unlock()
+		found = append(found, ok)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := r.Upsert(&RegistrationV2{CanonicalName: "a"})
+		assert.NoError(t, err)
+		wg.Wait()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the subscriber deadlocked with Upsert")
+	}
+	<-upserted
+	assert.Equal(t, []bool{true, true}, found)
+}
+
+func TestRegistrySubscribeReplay(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := newFakeClock()
+	r := NewRegistry(nil, clock)
+	for _, name := range []string{"b", "a"} {
+		_, err := r.Upsert(&RegistrationV2{
+			CanonicalName: name,
+			Expires:       CustomTime{Time: clock.Now().Add(time.Hour)},
+		})
+		require.NoError(err)
+	}
+	_, err := r.Upsert(&RegistrationV1{Config: DeliveryConfig{ReceiverURL: "c"}})
+	require.NoError(err)
+
+	var rec eventRecorder
+	cancel := r.Subscribe(rec.record, ReplayExisting())
+	defer cancel()
+
+	require.Len(rec.events, 3)
+	var keys []RegistrationKey
+	for _, e := range rec.events {
+		assert.Equal(RegistryAdded, e.Type)
+		keys = append(keys, e.Key)
+	}
+	assert.Equal([]RegistrationKey{{Version: 1, ID: "c"}, {Version: 2, ID: "a"}, {Version: 2, ID: "b"}}, keys)
+	assert.Equal(clock.Now().Add(time.Hour), rec.events[1].ExpiresAt)
+	rec.types()
+
+	// The later changes follow the replayed registrations.
+	assert.True(r.Delete(RegistrationKey{Version: 1, ID: "c"}))
+	clock.Advance(time.Hour)
+	assert.Equal([]RegistryEventType{RegistryDeleted, RegistryExpired, RegistryExpired}, rec.types())
+
+	// Without the option nothing is replayed.
+	_, err = r.Upsert(&RegistrationV2{CanonicalName: "a"})
+	require.NoError(err)
+	var other eventRecorder
+	r.Subscribe(other.record)
+	assert.Empty(other.types())
+}
+
+func TestRegistryLoadAndPersist(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	clock := newFakeClock()
+	store := NewMemoryStore()
+	require.NoError(store.Upsert(ctx, &RegistrationV2{CanonicalName: "a"}))
+	require.NoError(store.Upsert(ctx, &RegistrationV2{CanonicalName: "old", Expires: CustomTime{Time: clock.Now().Add(-time.Hour)}}))
+
+	r := NewRegistry(nil, clock)
+	err := r.Load(ctx, store)
+	assert.ErrorIs(err, ErrInvalidInput)
+	assert.Contains(err.Error(), "v2:old")
+	assert.Equal(1, r.Len())
+
+	var errs []error
+	cancel := r.Persist(ctx, store, func(err error) {
+		errs = append(errs, err)
+	})
+	defer cancel()
+
+	_, err = r.Upsert(&RegistrationV2{CanonicalName: "b", Expires: CustomTime{Time: clock.Now().Add(time.Hour)}})
+	require.NoError(err)
+	_, err = store.Get(ctx, RegistrationKey{Version: 2, ID: "b"})
+	assert.NoError(err)
+
+	clock.Advance(time.Hour)
+	_, err = store.Get(ctx, RegistrationKey{Version: 2, ID: "b"})
+	assert.ErrorIs(err, ErrNotFound)
+
+	// The registration is already missing from the store.
+	require.NoError(store.Delete(ctx, RegistrationKey{Version: 2, ID: "a"}))
+	assert.True(r.Delete(RegistrationKey{Version: 2, ID: "a"}))
+	assert.Empty(errs)
+
+	list, err := store.List(ctx)
+	require.NoError(err)
+	assert.Len(list, 1)
+
+	// Errors from the store are reported.
+	_, err = r.Upsert(&RegistrationV1{Config: DeliveryConfig{ReceiverURL: "c"}})
+	require.NoError(err)
+	assert.Empty(errs)
+	r.Persist(ctx, failingStore{store}, func(err error) {
+		errs = append(errs, err)
+	})
+	// c is replayed.
+	assert.Len(errs, 1)
+	_, err = r.Upsert(&RegistrationV1{Config: DeliveryConfig{ReceiverURL: "d"}})
+	require.NoError(err)
+	assert.Len(errs, 2)
+}
+
+func TestRegistryPersistRestart(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	ctx := context.Background()
+
+	clock := newFakeClock()
+	start := clock.Now()
+	store := NewMemoryStore()
+
+	// The registrations added before Persist is called are stored too.
+	r := NewRegistry(nil, clock)
+	v1 := &RegistrationV1{
+		Config:   DeliveryConfig{ReceiverURL: "v1"},
+		Duration: CustomDuration(time.Hour),
+	}
+	key, err := r.Upsert(v1)
+	require.NoError(err)
+	cancel := r.Persist(ctx, store, func(err error) {
+		assert.NoError(err)
+	})
+	defer cancel()
+
+	got, err := store.Get(ctx, key)
+	require.NoError(err)
+	assert.Equal(start.Add(time.Hour), got.(*RegistrationV1).Until.Time)
+	assert.Zero(got.(*RegistrationV1).Duration)
+
+	// The registration held by the Registry is not changed.
+	assert.Equal(CustomDuration(time.Hour), v1.Duration)
+	assert.True(v1.Until.IsZero())
+
+	// After a restart the registration keeps its expiration, instead of
+	// starting its Duration over.
+	clock.Advance(30 * time.Minute)
+	restarted := NewRegistry(nil, clock)
+	require.NoError(restarted.Load(ctx, store))
+	at, found := restarted.ExpiresAt(key)
+	require.True(found)
+	assert.Equal(start.Add(time.Hour), at)
+
+	clock.Advance(30 * time.Minute)
+	assert.Zero(restarted.Len())
+}
+
+// failingStore is a Store that fails every upsert.
+type failingStore struct {
+	Store
+}
+
+func (failingStore) Upsert(context.Context, Registration) error {
+	return errors.New("upsert failed")
+}
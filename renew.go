@@ -0,0 +1,261 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrRenewalDenied = errors.New("renewal denied")
+)
+
+// RenewPolicy is the expiration policy applied when a registration is renewed
+// or extended.  It is the same policy the ClampExpiration and Until options
+// apply when a registration is validated: the new expiration is clamped, if
+// Clamp is set, and is then checked by RegistrationV1.CheckUntil or
+// RegistrationV2.CheckExpires.
+//
+// The zero value allows any renewal.
+type RenewPolicy struct {
+	// MaxTTL is the longest a registration may last from now.  Zero means
+	// there is no limit, as with ValidateRegistrationDuration, and Jitter and
+	// Clamp are then ignored.
+	MaxTTL time.Duration
+
+	// Jitter is how far past MaxTTL the new expiration may be before it is
+	// too long.
+	Jitter time.Duration
+
+	// Clamp shortens a new expiration that is too long to now plus MaxTTL,
+	// like ClampExpiration, instead of denying the renewal.
+	Clamp bool
+
+	// Now returns the current time.  If nil, the clock of the registration
+	// is used.
+	Now func() time.Time
+}
+
+// check checks the policy and the renewal duration.  The path is the json
+// path of the expiration field.
+func (p RenewPolicy) check(d time.Duration, path string) error {
+	if p.MaxTTL < 0 {
+		return errInvalidTTL
+	} else if p.Jitter < 0 {
+		return errInvalidJitter
+	}
+	if d <= 0 {
+		return &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path,
+			Value:   d.String(),
+			Message: "the renewal duration must be positive",
+		}
+	}
+	return nil
+}
+
+// denied wraps the error of a check that rejected the new expiration.
+func denied(err error) error {
+	return fmt.Errorf("%w: %w", ErrRenewalDenied, err)
+}
+
+// extendFrom returns the time an extension starts from: the current
+// expiration, or now if the registration has no expiration or has already
+// expired.
+func extendFrom(now, expires time.Time) time.Time {
+	if expires.IsZero() || expires.Before(now) {
+		return now
+	}
+	return expires
+}
+
+// Renew sets Until to the duration from now, subject to the policy, and
+// returns the new Until.  Duration is cleared since only one of Duration or
+// Until may be set.  If the policy forbids the new Until, an error wrapping
+// ErrRenewalDenied is returned and the registration is not changed.
+func (v1 *RegistrationV1) Renew(d time.Duration, p RenewPolicy) (time.Time, error) {
+	now := v1.policyNow(p)
+	return v1.renewFrom(now, now, d, p)
+}
+
+// Extend moves Until the duration past the current expiration, subject to
+// the policy, and returns the new Until.  The current expiration is Until, or
+// now if Until is not set or has passed.  Duration is cleared since only one
+// of Duration or Until may be set.  If the policy forbids the new Until, an
+// error wrapping ErrRenewalDenied is returned and the registration is not
+// changed.
+//
+// A registration that expires a Duration after it is added has no current
+// expiration of its own, so it cannot be extended: Renew it instead, or use
+// Registry.Extend, which knows when it was added.
+func (v1 *RegistrationV1) Extend(d time.Duration, p RenewPolicy) (time.Time, error) {
+	if v1.Until.IsZero() && v1.Duration > 0 {
+		return time.Time{}, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeConflict,
+			Path:    "duration",
+			Value:   v1.Duration,
+			Message: "a registration with a duration has no expiration to extend",
+		}
+	}
+
+	now := v1.policyNow(p)
+	return v1.renewFrom(now, extendFrom(now, v1.Until.Time), d, p)
+}
+
+// renewFrom sets Until to the duration after from, subject to the policy.
+func (v1 *RegistrationV1) renewFrom(now, from time.Time, d time.Duration, p RenewPolicy) (time.Time, error) {
+	if err := p.check(d, "until"); err != nil {
+		return time.Time{}, err
+	}
+
+	clock := func() time.Time { return now }
+	renewed := *v1
+	renewed.Until = CustomTime{Time: from.Add(d)}
+	renewed.Duration = 0
+	if p.MaxTTL > 0 {
+		if p.Clamp {
+			if err := renewed.ClampUntil(clock, p.Jitter, p.MaxTTL); err != nil {
+				return time.Time{}, err
+			}
+		}
+		if err := renewed.CheckUntil(clock, p.Jitter, p.MaxTTL); err != nil {
+			return time.Time{}, denied(err)
+		}
+	}
+
+	v1.Until = renewed.Until
+	v1.Duration = renewed.Duration
+	return v1.Until.Time, nil
+}
+
+func (v1 *RegistrationV1) policyNow(p RenewPolicy) time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return v1.now()
+}
+
+// Renew sets Expires to the duration from now, subject to the policy, and
+// returns the new Expires.  If the policy forbids the new Expires, an error
+// wrapping ErrRenewalDenied is returned and the registration is not changed.
+func (v2 *RegistrationV2) Renew(d time.Duration, p RenewPolicy) (time.Time, error) {
+	now := v2.policyNow(p)
+	return v2.renewFrom(now, now, d, p)
+}
+
+// Extend moves Expires the duration past the current expiration, subject to
+// the policy, and returns the new Expires.  The current expiration is
+// Expires, or now if Expires is not set or has passed.  If the policy forbids
+// the new Expires, an error wrapping ErrRenewalDenied is returned and the
+// registration is not changed.
+func (v2 *RegistrationV2) Extend(d time.Duration, p RenewPolicy) (time.Time, error) {
+	now := v2.policyNow(p)
+	return v2.renewFrom(now, extendFrom(now, v2.Expires.Time), d, p)
+}
+
+// renewFrom sets Expires to the duration after from, subject to the policy.
+func (v2 *RegistrationV2) renewFrom(now, from time.Time, d time.Duration, p RenewPolicy) (time.Time, error) {
+	if err := p.check(d, "expires"); err != nil {
+		return time.Time{}, err
+	}
+
+	clock := func() time.Time { return now }
+	renewed := *v2
+	renewed.Expires = CustomTime{Time: from.Add(d)}
+	if p.MaxTTL > 0 {
+		if p.Clamp {
+			if err := renewed.ClampExpires(clock, p.Jitter, p.MaxTTL); err != nil {
+				return time.Time{}, err
+			}
+		}
+		if err := renewed.CheckExpires(clock, p.Jitter, p.MaxTTL); err != nil {
+			return time.Time{}, denied(err)
+		}
+	}
+
+	v2.Expires = renewed.Expires
+	return v2.Expires.Time, nil
+}
+
+func (v2 *RegistrationV2) policyNow(p RenewPolicy) time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return v2.now()
+}
+
+// Renew renews the registration with the key for the duration from now, as
+// RegistrationV1.Renew and RegistrationV2.Renew do, without running the
+// validators again.  The registration is replaced by a renewed copy and a
+// RegistryUpdated event is published.  If the policy has no Now function,
+// the clock of the Registry is used.
+func (r *Registry) Renew(key RegistrationKey, d time.Duration, p RenewPolicy) (time.Time, error) {
+	return r.renew(key, d, p, false)
+}
+
+// Extend extends the registration with the key by the duration past its
+// current expiration, as RegistrationV1.Extend and RegistrationV2.Extend do,
+// without running the validators again.  The current expiration is the one
+// the Registry holds, so a RegistrationV1 with a Duration is extended from
+// its Duration after it was added.  The registration is replaced by an
+// extended copy and a RegistryUpdated event is published.  If the policy has
+// no Now function, the clock of the Registry is used.
+func (r *Registry) Extend(key RegistrationKey, d time.Duration, p RenewPolicy) (time.Time, error) {
+	return r.renew(key, d, p, true)
+}
+
+// renew replaces the registration with the key by a copy that expires the
+// duration after now, or after its current expiration if extend is set.
+func (r *Registry) renew(key RegistrationKey, d time.Duration, p RenewPolicy, extend bool) (time.Time, error) {
+	r.mu.Lock()
+	now := r.clock.Now()
+	e, found := r.entries[key]
+	if !found || e.expired(now) {
+		r.mu.Unlock()
+		return time.Time{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+
+	policyNow := now
+	if p.Now != nil {
+		policyNow = p.Now()
+	}
+	from := policyNow
+	if extend {
+		from = extendFrom(policyNow, e.expiresAt)
+	}
+
+	var (
+		renewed Registration
+		expires time.Time
+		err     error
+	)
+	switch reg := e.registration.(type) {
+	case *RegistrationV1:
+		c := *reg
+		expires, err = c.renewFrom(policyNow, from, d, p)
+		renewed = &c
+	case *RegistrationV2:
+		c := *reg
+		expires, err = c.renewFrom(policyNow, from, d, p)
+		renewed = &c
+	default:
+		err = ErrUknownType
+	}
+	if err != nil {
+		r.mu.Unlock()
+		return time.Time{}, err
+	}
+
+	r.put(&registryEntry{
+		key:          key,
+		registration: renewed,
+		expiresAt:    expires,
+	}, now)
+	return expires, nil
+}
@@ -0,0 +1,325 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewPolicy(t *testing.T) {
+	now := mockNow()
+	tests := []struct {
+		description string
+		policy      RenewPolicy
+		from        time.Time
+		d           time.Duration
+		expected    time.Time
+		expectedErr error
+	}{
+		{
+			description: "within the max ttl",
+			policy:      RenewPolicy{MaxTTL: time.Hour},
+			from:        now,
+			d:           time.Hour,
+			expected:    now.Add(time.Hour),
+		}, {
+			description: "within the jitter",
+			policy:      RenewPolicy{MaxTTL: time.Hour, Jitter: time.Minute},
+			from:        now,
+			d:           time.Hour + time.Minute,
+			expected:    now.Add(time.Hour + time.Minute),
+		}, {
+			description: "extended past the max ttl",
+			policy:      RenewPolicy{MaxTTL: time.Hour},
+			from:        now.Add(30 * time.Minute),
+			d:           time.Hour,
+			expectedErr: ErrRenewalDenied,
+		}, {
+			description: "beyond the max ttl",
+			policy:      RenewPolicy{MaxTTL: time.Hour, Jitter: time.Minute},
+			from:        now,
+			d:           2 * time.Hour,
+			expectedErr: ErrRenewalDenied,
+		}, {
+			description: "clamped to the max ttl",
+			policy:      RenewPolicy{MaxTTL: time.Hour, Jitter: time.Minute, Clamp: true},
+			from:        now,
+			d:           2 * time.Hour,
+			expected:    now.Add(time.Hour),
+		}, {
+			description: "zero max ttl ignores the jitter",
+			policy:      RenewPolicy{Jitter: time.Hour},
+			from:        now,
+			d:           24 * time.Hour,
+			expected:    now.Add(24 * time.Hour),
+		}, {
+			description: "zero max ttl",
+			from:        now,
+			d:           time.Hour,
+			expected:    now.Add(time.Hour),
+		}, {
+			description: "zero max ttl is not clamped",
+			policy:      RenewPolicy{Clamp: true},
+			from:        now,
+			d:           time.Hour,
+			expected:    now.Add(time.Hour),
+		}, {
+			description: "zero duration",
+			policy:      RenewPolicy{MaxTTL: time.Hour},
+			from:        now,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative duration",
+			policy:      RenewPolicy{MaxTTL: time.Hour},
+			from:        now,
+			d:           -time.Hour,
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "negative max ttl",
+			policy:      RenewPolicy{MaxTTL: -time.Hour},
+			from:        now,
+			d:           time.Hour,
+			expectedErr: errInvalidTTL,
+		}, {
+			description: "negative jitter",
+			policy:      RenewPolicy{MaxTTL: time.Hour, Jitter: -time.Minute},
+			from:        now,
+			d:           time.Hour,
+			expectedErr: errInvalidJitter,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+
+			v2 := RegistrationV2{Expires: CustomTime{Time: tc.from}}
+			got, err := v2.renewFrom(now, tc.from, tc.d, tc.policy)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(err, tc.expectedErr)
+				assert.True(got.IsZero())
+				assert.Equal(tc.from, v2.Expires.Time)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.expected, got)
+			assert.Equal(tc.expected, v2.Expires.Time)
+		})
+	}
+}
+
+func TestRenewPolicyZeroValue(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := mockNow()
+	var p RenewPolicy
+
+	v1 := RegistrationV1{Until: CustomTime{Time: now.Add(time.Hour)}}
+	v1.SetNowFunc(mockNow)
+	until, err := v1.Renew(365*24*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(365*24*time.Hour), until)
+
+	v2 := RegistrationV2{Expires: CustomTime{Time: now.Add(time.Hour)}}
+	v2.SetNowFunc(mockNow)
+	expires, err := v2.Extend(time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(2*time.Hour), expires)
+
+	// Only the renewal duration is checked.
+	_, err = v2.Renew(0, p)
+	assert.ErrorIs(err, ErrInvalidInput)
+	assert.NotErrorIs(err, ErrRenewalDenied)
+}
+
+func TestRenewalErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := mockNow()
+	p := RenewPolicy{MaxTTL: time.Hour, Now: mockNow}
+	v1 := RegistrationV1{}
+
+	_, err := v1.Renew(2*time.Hour, p)
+	var ve *ValidationError
+	require.True(errors.As(err, &ve))
+	assert.ErrorIs(err, ErrRenewalDenied)
+	assert.ErrorIs(err, errInvalidUntil)
+	assert.Equal(CodeOutOfRange, ve.Code)
+	assert.Equal("until", ve.Path)
+	assert.Equal(now.Add(2*time.Hour), ve.Value)
+
+	v2 := RegistrationV2{}
+	_, err = v2.Renew(0, p)
+	require.True(errors.As(err, &ve))
+	assert.Equal("expires", ve.Path)
+	assert.NotErrorIs(err, ErrRenewalDenied)
+}
+
+func TestRegistrationV1Renew(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := mockNow()
+	p := RenewPolicy{MaxTTL: 4 * time.Hour, Now: mockNow}
+	v1 := RegistrationV1{Duration: CustomDuration(time.Hour)}
+
+	// Without an Until there is nothing to extend.
+	_, err := v1.Extend(time.Hour, p)
+	var ve *ValidationError
+	require.True(errors.As(err, &ve))
+	assert.Equal("duration", ve.Path)
+	assert.Equal(CustomDuration(time.Hour), v1.Duration)
+
+	until, err := v1.Renew(2*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(2*time.Hour), until)
+	assert.Equal(until, v1.Until.Time)
+	assert.Zero(v1.Duration)
+
+	until, err = v1.Extend(time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(3*time.Hour), until)
+	assert.Equal(until, v1.Until.Time)
+
+	// A denied extension leaves the registration as it was.
+	_, err = v1.Extend(2*time.Hour, p)
+	assert.ErrorIs(err, ErrRenewalDenied)
+	assert.Equal(now.Add(3*time.Hour), v1.Until.Time)
+
+	// An expired registration is extended from now.
+	v1.Until = CustomTime{Time: now.Add(-time.Hour)}
+	until, err = v1.Extend(time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(time.Hour), until)
+}
+
+func TestRegistrationV2Renew(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := mockNow()
+	p := RenewPolicy{MaxTTL: 4 * time.Hour, Clamp: true, Now: mockNow}
+	v2 := RegistrationV2{Expires: CustomTime{Time: now.Add(time.Hour)}}
+
+	expires, err := v2.Extend(2*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(3*time.Hour), expires)
+	assert.Equal(expires, v2.Expires.Time)
+
+	// Extending past the max ttl is clamped.
+	expires, err = v2.Extend(2*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(4*time.Hour), expires)
+
+	expires, err = v2.Renew(30*time.Minute, p)
+	require.NoError(err)
+	assert.Equal(now.Add(30*time.Minute), expires)
+	assert.Equal(expires, v2.Expires.Time)
+
+	// A registration that never expires is extended from now.
+	v2.Expires = CustomTime{}
+	expires, err = v2.Extend(time.Hour, p)
+	require.NoError(err)
+	assert.Equal(now.Add(time.Hour), expires)
+
+	_, err = v2.Renew(0, p)
+	assert.ErrorIs(err, ErrInvalidInput)
+	assert.Equal(now.Add(time.Hour), v2.Expires.Time)
+}
+
+func TestRegistryRenew(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var rec eventRecorder
+	clock := newFakeClock()
+	r := NewRegistry(nil, clock)
+	r.Subscribe(rec.record)
+
+	v1 := &RegistrationV1{
+		Config:   DeliveryConfig{ReceiverURL: "v1"},
+		Duration: CustomDuration(time.Hour),
+	}
+	v2 := &RegistrationV2{
+		CanonicalName: "v2",
+		Expires:       CustomTime{Time: clock.Now().Add(time.Hour)},
+	}
+	k1, err := r.Upsert(v1)
+	require.NoError(err)
+	k2, err := r.Upsert(v2)
+	require.NoError(err)
+	rec.types()
+
+	p := RenewPolicy{MaxTTL: 3 * time.Hour}
+
+	until, err := r.Renew(k1, 2*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(clock.Now().Add(2*time.Hour), until)
+	expires, err := r.Extend(k2, 2*time.Hour, p)
+	require.NoError(err)
+	assert.Equal(clock.Now().Add(3*time.Hour), expires)
+	assert.Equal([]RegistryEventType{RegistryUpdated, RegistryUpdated}, rec.types())
+
+	// The registrations held by the caller are not changed.
+	assert.Equal(CustomDuration(time.Hour), v1.Duration)
+	assert.True(v1.Until.IsZero())
+	assert.Equal(clock.Now().Add(time.Hour), v2.Expires.Time)
+
+	got, found := r.Get(k1)
+	require.True(found)
+	assert.Equal(until, got.(*RegistrationV1).Until.Time)
+	at, found := r.ExpiresAt(k2)
+	require.True(found)
+	assert.Equal(expires, at)
+
+	// A denied renewal leaves the registration in place.
+	_, err = r.Extend(k2, time.Hour, p)
+	assert.ErrorIs(err, ErrRenewalDenied)
+	assert.Empty(rec.types())
+
+	// The expirations follow the renewals.
+	clock.Advance(time.Hour)
+	assert.Equal(2, r.Len())
+	clock.Advance(time.Hour)
+	_, found = r.Get(k1)
+	assert.False(found)
+	assert.Equal(1, r.Len())
+	assert.Equal([]RegistryEventType{RegistryExpired}, rec.types())
+
+	_, err = r.Renew(k1, time.Hour, p)
+	assert.ErrorIs(err, ErrNotFound)
+	clock.Advance(time.Hour)
+	_, err = r.Extend(k2, time.Hour, p)
+	assert.ErrorIs(err, ErrNotFound)
+}
+
+func TestRegistryExtendDuration(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	clock := newFakeClock()
+	r := NewRegistry(nil, clock)
+	key, err := r.Upsert(&RegistrationV1{
+		Config:   DeliveryConfig{ReceiverURL: "v1"},
+		Duration: CustomDuration(time.Hour),
+	})
+	require.NoError(err)
+
+	// The extension starts from when the Duration runs out, not from now.
+	clock.Advance(30 * time.Minute)
+	until, err := r.Extend(key, time.Hour, RenewPolicy{MaxTTL: 3 * time.Hour})
+	require.NoError(err)
+	assert.Equal(mockNow().Add(2*time.Hour), until)
+
+	got, found := r.Get(key)
+	require.True(found)
+	assert.Equal(until, got.(*RegistrationV1).Until.Time)
+	assert.Zero(got.(*RegistrationV1).Duration)
+}
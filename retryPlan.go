@@ -0,0 +1,183 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// RetryPlan yields the sequence of URLs to attempt when delivering a request.
+// The first URL is attempted, then retried RetryEachUrl times before moving on
+// to the next URL.  After the last URL the plan starts over with the first
+// URL.  At most MaxRetry retries are made, so the request is attempted at most
+// MaxRetry+1 times in total.
+//
+// A RetryPlan is not safe for concurrent use.
+type RetryPlan struct {
+	urls     []string
+	hint     RetryHint
+	each     int
+	max      int
+	current  int
+	tries    int
+	attempts int
+}
+
+// NewRetryPlan creates a RetryPlan for the urls.  The hint is combined with
+// the server defaults using WithDefaults.
+func NewRetryPlan(urls []string, hint, defaults RetryHint) *RetryPlan {
+	hint = hint.WithDefaults(defaults)
+
+	p := RetryPlan{
+		urls: append([]string{}, urls...),
+		hint: hint,
+	}
+	if hint.RetryEachUrl > 0 {
+		p.each = hint.RetryEachUrl
+	}
+	if hint.MaxRetry > 0 {
+		p.max = hint.MaxRetry
+	}
+	return &p
+}
+
+// RetryPlan returns the RetryPlan for the ReceiverURLs of the webhook.  Use
+// NewRetryPlan with the URLs from a SRVResolver for webhooks that use a
+// DNSSrvRecord.
+func (w Webhook) RetryPlan(defaults RetryHint) *RetryPlan {
+	return NewRetryPlan(w.ReceiverURLs, w.RetryHint, defaults)
+}
+
+// RetryPlan returns the RetryPlan for the ReceiverURL followed by the
+// AlternativeURLs of the registration.  RegistrationV1 does not have a
+// RetryHint, so the server defaults are always used.
+func (v1 *RegistrationV1) RetryPlan(defaults RetryHint) *RetryPlan {
+	var urls []string
+	if v1.Config.ReceiverURL != "" {
+		urls = append(urls, v1.Config.ReceiverURL)
+	}
+	urls = append(urls, v1.Config.AlternativeURLs...)
+	return NewRetryPlan(urls, RetryHint{}, defaults)
+}
+
+// Next returns the next URL to attempt.  If the plan is exhausted, false is
+// returned.
+func (p *RetryPlan) Next() (string, bool) {
+	if p.Exhausted() {
+		return "", false
+	}
+
+	if p.tries > p.each {
+		p.current = (p.current + 1) % len(p.urls)
+		p.tries = 0
+	}
+
+	p.tries++
+	p.attempts++
+	return p.urls[p.current], true
+}
+
+// Exhausted returns true if there are no more attempts left.
+func (p *RetryPlan) Exhausted() bool {
+	return len(p.urls) == 0 || p.attempts > p.max
+}
+
+// Attempts returns the number of attempts returned by Next so far.
+func (p *RetryPlan) Attempts() int {
+	return p.attempts
+}
+
+// Delay returns how long to wait before the next attempt returned by Next.
+// The retryAfter is the Retry-After duration of the last response, or zero.
+// See RetryHint.Delay.
+func (p *RetryPlan) Delay(retryAfter time.Duration) time.Duration {
+	return p.hint.Delay(p.attempts+1, retryAfter, nil)
+}
+
+// Remaining returns the number of attempts left.
+func (p *RetryPlan) Remaining() int {
+	if len(p.urls) == 0 {
+		return 0
+	}
+	return p.max + 1 - p.attempts
+}
+
+// WithDefaults returns the RetryHint with each value that is zero or negative
+// replaced with the value from the server defaults.  HonorRetryAfter is set if
+// it is set in either.
+func (rh RetryHint) WithDefaults(defaults RetryHint) RetryHint {
+	if rh.RetryEachUrl <= 0 {
+		rh.RetryEachUrl = defaults.RetryEachUrl
+	}
+	if rh.MaxRetry <= 0 {
+		rh.MaxRetry = defaults.MaxRetry
+	}
+	if rh.InitialBackoff <= 0 {
+		rh.InitialBackoff = defaults.InitialBackoff
+	}
+	if !(rh.BackoffMultiplier > 0) {
+		rh.BackoffMultiplier = defaults.BackoffMultiplier
+	}
+	if rh.MaxBackoff <= 0 {
+		rh.MaxBackoff = defaults.MaxBackoff
+	}
+	if !(rh.BackoffJitter > 0) {
+		rh.BackoffJitter = defaults.BackoffJitter
+	}
+	rh.HonorRetryAfter = rh.HonorRetryAfter || defaults.HonorRetryAfter
+	return rh
+}
+
+// Delay returns how long to wait before the nth attempt of a request, where
+// the first attempt is 1 and is not delayed.  The delay before the second
+// attempt is the InitialBackoff, and each following delay is BackoffMultiplier
+// times longer, up to the MaxBackoff.  The jitter then removes a random
+// fraction of up to BackoffJitter from the delay.
+//
+// If HonorRetryAfter is set and the retryAfter duration from the last response
+// is longer than the delay, the retryAfter duration is returned.  The caller is
+// responsible for limiting the retryAfter duration it accepts.
+//
+// The random function returns a pseudo-random number in [0.0,1.0).  If it is
+// nil, math/rand.Float64 is used.
+func (rh RetryHint) Delay(n int, retryAfter time.Duration, random func() float64) time.Duration {
+	if n <= 1 {
+		return 0
+	}
+
+	limit := time.Duration(math.MaxInt64)
+	if rh.MaxBackoff > 0 {
+		limit = time.Duration(rh.MaxBackoff)
+	}
+
+	multiplier := rh.BackoffMultiplier
+	if !(multiplier >= 1) {
+		multiplier = 1
+	}
+
+	// Without an InitialBackoff there is no delay, which also keeps 0 times
+	// an overflowed +Inf multiplier from becoming NaN.
+	var delay time.Duration
+	if rh.InitialBackoff > 0 {
+		delay = limit
+		d := float64(rh.InitialBackoff) * math.Pow(multiplier, float64(n-2))
+		if !math.IsNaN(d) && !math.IsInf(d, 0) && d < float64(limit) {
+			delay = time.Duration(d)
+		}
+	}
+
+	if jitter := math.Min(rh.BackoffJitter, 1); jitter > 0 {
+		if random == nil {
+			random = rand.Float64
+		}
+		delay -= time.Duration(float64(delay) * jitter * random())
+	}
+
+	if rh.HonorRetryAfter && retryAfter > delay {
+		return retryAfter
+	}
+	return delay
+}
@@ -0,0 +1,304 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// attempts returns all of the URLs the plan yields.
+func attempts(p *RetryPlan) []string {
+	var list []string
+	for {
+		url, ok := p.Next()
+		if !ok {
+			return list
+		}
+		list = append(list, url)
+	}
+}
+
+func TestRetryPlan(t *testing.T) {
+	tests := []struct {
+		description string
+		urls        []string
+		hint        RetryHint
+		defaults    RetryHint
+		expected    []string
+	}{
+		{
+			description: "no retries",
+			urls:        []string{"a", "b"},
+			expected:    []string{"a"},
+		}, {
+			description: "retry each url only",
+			urls:        []string{"a", "b"},
+			hint:        RetryHint{RetryEachUrl: 2},
+			expected:    []string{"a"},
+		}, {
+			description: "max retry only moves to the next url",
+			urls:        []string{"a", "b", "c"},
+			hint:        RetryHint{MaxRetry: 4},
+			expected:    []string{"a", "b", "c", "a", "b"},
+		}, {
+			description: "both limits",
+			urls:        []string{"a", "b"},
+			hint:        RetryHint{RetryEachUrl: 1, MaxRetry: 4},
+			expected:    []string{"a", "a", "b", "b", "a"},
+		}, {
+			description: "max retry ends within a url",
+			urls:        []string{"a", "b"},
+			hint:        RetryHint{RetryEachUrl: 3, MaxRetry: 5},
+			expected:    []string{"a", "a", "a", "a", "b", "b"},
+		}, {
+			description: "single url",
+			urls:        []string{"a"},
+			hint:        RetryHint{RetryEachUrl: 1, MaxRetry: 3},
+			expected:    []string{"a", "a", "a", "a"},
+		}, {
+			description: "server defaults",
+			urls:        []string{"a", "b"},
+			defaults:    RetryHint{RetryEachUrl: 1, MaxRetry: 2},
+			expected:    []string{"a", "a", "b"},
+		}, {
+			description: "hint overrides the defaults",
+			urls:        []string{"a", "b"},
+			hint:        RetryHint{MaxRetry: 1},
+			defaults:    RetryHint{RetryEachUrl: 1, MaxRetry: 5},
+			expected:    []string{"a", "a"},
+		}, {
+			description: "negative values use the defaults",
+			urls:        []string{"a", "b"},
+			hint:        RetryHint{RetryEachUrl: -1, MaxRetry: -1},
+			defaults:    RetryHint{MaxRetry: 1},
+			expected:    []string{"a", "b"},
+		}, {
+			description: "negative defaults",
+			urls:        []string{"a", "b"},
+			defaults:    RetryHint{RetryEachUrl: -1, MaxRetry: -1},
+			expected:    []string{"a"},
+		}, {
+			description: "no urls",
+			hint:        RetryHint{RetryEachUrl: 1, MaxRetry: 3},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			p := NewRetryPlan(tc.urls, tc.hint, tc.defaults)
+			assert.Equal(len(tc.expected), p.Remaining())
+			assert.Equal(tc.expected, attempts(p))
+
+			assert.True(p.Exhausted())
+			assert.Equal(len(tc.expected), p.Attempts())
+			assert.Zero(p.Remaining())
+
+			// exhaustion is permanent
+			url, ok := p.Next()
+			assert.False(ok)
+			assert.Empty(url)
+		})
+	}
+}
+
+func TestRetryPlanProgress(t *testing.T) {
+	assert := assert.New(t)
+	p := NewRetryPlan([]string{"a", "b"}, RetryHint{RetryEachUrl: 1, MaxRetry: 2}, RetryHint{})
+
+	assert.False(p.Exhausted())
+	url, ok := p.Next()
+	assert.True(ok)
+	assert.Equal("a", url)
+	assert.Equal(1, p.Attempts())
+	assert.Equal(2, p.Remaining())
+
+	attempts(p)
+	assert.True(p.Exhausted())
+}
+
+func TestRetryPlanCopiesURLs(t *testing.T) {
+	urls := []string{"a", "b"}
+	p := NewRetryPlan(urls, RetryHint{MaxRetry: 1}, RetryHint{})
+	urls[1] = "changed"
+	assert.Equal(t, []string{"a", "b"}, attempts(p))
+}
+
+func TestWebhookRetryPlan(t *testing.T) {
+	w := Webhook{
+		ReceiverURLs: []string{"https://a.example.com", "https://b.example.com"},
+		RetryHint:    RetryHint{RetryEachUrl: 1, MaxRetry: 2},
+	}
+	assert.Equal(t, []string{
+		"https://a.example.com",
+		"https://a.example.com",
+		"https://b.example.com",
+	}, attempts(w.RetryPlan(RetryHint{MaxRetry: 10})))
+}
+
+func TestRegistrationV1RetryPlan(t *testing.T) {
+	v1 := &RegistrationV1{
+		Config: DeliveryConfig{
+			ReceiverURL:     "https://a.example.com",
+			AlternativeURLs: []string{"https://b.example.com", "https://c.example.com"},
+		},
+	}
+	assert.Equal(t, []string{
+		"https://a.example.com",
+		"https://b.example.com",
+		"https://c.example.com",
+		"https://a.example.com",
+	}, attempts(v1.RetryPlan(RetryHint{MaxRetry: 3})))
+
+	// only the alternative urls
+	v1.Config.ReceiverURL = ""
+	assert.Equal(t, []string{
+		"https://b.example.com",
+		"https://c.example.com",
+	}, attempts(v1.RetryPlan(RetryHint{MaxRetry: 1})))
+
+	assert.Empty(t, attempts((&RegistrationV1{}).RetryPlan(RetryHint{MaxRetry: 1})))
+}
+
+func TestRetryHintDelay(t *testing.T) {
+	half := func() float64 { return 0.5 }
+	backoff := RetryHint{
+		InitialBackoff:    CustomDuration(time.Second),
+		BackoffMultiplier: 2,
+		MaxBackoff:        CustomDuration(10 * time.Second),
+	}
+
+	tests := []struct {
+		description string
+		hint        RetryHint
+		n           int
+		retryAfter  time.Duration
+		expected    time.Duration
+	}{
+		{description: "first attempt", hint: backoff, n: 1},
+		{description: "invalid attempt", hint: backoff, n: 0},
+		{description: "second attempt", hint: backoff, n: 2, expected: time.Second},
+		{description: "third attempt", hint: backoff, n: 3, expected: 2 * time.Second},
+		{description: "fourth attempt", hint: backoff, n: 4, expected: 4 * time.Second},
+		{description: "capped", hint: backoff, n: 6, expected: 10 * time.Second},
+		{description: "capped without overflow", hint: backoff, n: 10000, expected: 10 * time.Second},
+		{
+			description: "no max backoff without overflow",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second), BackoffMultiplier: 2},
+			n:           10000,
+			expected:    time.Duration(math.MaxInt64),
+		}, {
+			description: "constant without a multiplier",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second)},
+			n:           5,
+			expected:    time.Second,
+		}, {
+			description: "multiplier less than one is constant",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second), BackoffMultiplier: 0.5},
+			n:           5,
+			expected:    time.Second,
+		}, {
+			description: "no backoff",
+			n:           3,
+		}, {
+			description: "no backoff without overflow",
+			hint:        RetryHint{BackoffMultiplier: 2, MaxBackoff: CustomDuration(10 * time.Second)},
+			n:           1100,
+		}, {
+			description: "no backoff honors retry after",
+			hint:        RetryHint{BackoffMultiplier: 2, HonorRetryAfter: true},
+			n:           1100,
+			retryAfter:  time.Minute,
+			expected:    time.Minute,
+		}, {
+			description: "jitter",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second), BackoffJitter: 0.5},
+			n:           2,
+			expected:    750 * time.Millisecond,
+		}, {
+			description: "jitter after the cap",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Minute), MaxBackoff: CustomDuration(time.Second), BackoffJitter: 1},
+			n:           2,
+			expected:    500 * time.Millisecond,
+		}, {
+			description: "retry after ignored",
+			hint:        backoff,
+			n:           2,
+			retryAfter:  time.Minute,
+			expected:    time.Second,
+		}, {
+			description: "retry after honored",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second), HonorRetryAfter: true},
+			n:           2,
+			retryAfter:  time.Minute,
+			expected:    time.Minute,
+		}, {
+			description: "shorter retry after",
+			hint:        RetryHint{InitialBackoff: CustomDuration(time.Second), HonorRetryAfter: true},
+			n:           2,
+			retryAfter:  time.Millisecond,
+			expected:    time.Second,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.hint.Delay(tc.n, tc.retryAfter, half))
+		})
+	}
+}
+
+func TestRetryHintDelayJitterRange(t *testing.T) {
+	hint := RetryHint{InitialBackoff: CustomDuration(time.Second), BackoffJitter: 0.25}
+	for i := 0; i < 100; i++ {
+		d := hint.Delay(2, 0, nil)
+		assert.GreaterOrEqual(t, d, 750*time.Millisecond)
+		assert.LessOrEqual(t, d, time.Second)
+	}
+}
+
+func TestRetryHintWithDefaults(t *testing.T) {
+	defaults := RetryHint{
+		RetryEachUrl:      1,
+		MaxRetry:          3,
+		InitialBackoff:    CustomDuration(time.Second),
+		BackoffMultiplier: 2,
+		MaxBackoff:        CustomDuration(time.Minute),
+		BackoffJitter:     0.1,
+		HonorRetryAfter:   true,
+	}
+
+	assert.Equal(t, defaults, RetryHint{}.WithDefaults(defaults))
+	assert.Equal(t, defaults, RetryHint{MaxRetry: -1, InitialBackoff: -1}.WithDefaults(defaults))
+
+	hint := RetryHint{
+		RetryEachUrl:      2,
+		MaxRetry:          5,
+		InitialBackoff:    CustomDuration(2 * time.Second),
+		BackoffMultiplier: 1.5,
+		MaxBackoff:        CustomDuration(time.Hour),
+		BackoffJitter:     0.5,
+	}
+	expected := hint
+	expected.HonorRetryAfter = true
+	assert.Equal(t, expected, hint.WithDefaults(defaults))
+}
+
+func TestRetryPlanDelay(t *testing.T) {
+	p := NewRetryPlan([]string{"a"}, RetryHint{MaxRetry: 3, BackoffMultiplier: 3}, RetryHint{InitialBackoff: CustomDuration(time.Second)})
+
+	var delays []time.Duration
+	for {
+		d := p.Delay(0)
+		if _, ok := p.Next(); !ok {
+			break
+		}
+		delays = append(delays, d)
+	}
+	assert.Equal(t, []time.Duration{0, time.Second, 3 * time.Second, 9 * time.Second}, delays)
+}
@@ -0,0 +1,246 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+//go:generate go run ./cmd/schemagen -out schemas
+
+// SchemaDraft is the JSON Schema dialect produced by GenerateSchema.
+const SchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaProvider is implemented by types that describe their own json schema
+// instead of having it derived from their Go type.
+type schemaProvider interface {
+	jsonSchema() map[string]any
+}
+
+// schemaExtender is implemented by struct types that add constraints to the
+// json schema derived from their Go type.
+type schemaExtender interface {
+	extendSchema(map[string]any)
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// GenerateSchema generates the JSON Schema (draft 2020-12) document for the
+// registration passed in, which is normally a RegistrationV1 or a
+// RegistrationV2.  The schema is derived from the Go types and their json
+// tags, along with the constraints documented on the types.
+func GenerateSchema(registration any) ([]byte, error) {
+	t := reflect.TypeOf(registration)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: a schema can only be generated for a struct, not %v", ErrInvalidType, t)
+	}
+
+	s := typeSchema(t)
+	s["$schema"] = SchemaDraft
+	s["title"] = t.Name()
+
+	return json.MarshalIndent(s, "", "  ")
+}
+
+// typeSchema returns the json schema for the Go type.
+func typeSchema(t reflect.Type) map[string]any {
+	if p, ok := reflect.Zero(t).Interface().(schemaProvider); ok {
+		return p.jsonSchema()
+	}
+
+	switch t {
+	case timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]any{
+			"type":        "integer",
+			"description": "duration in nanoseconds",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Pointer:
+		s := typeSchema(t.Elem())
+		return map[string]any{"anyOf": []any{s, map[string]any{"type": "null"}}}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]any{
+			"type":  []string{"array", "null"},
+			"items": typeSchema(t.Elem()),
+		}
+	case reflect.Map:
+		return map[string]any{
+			"type":                 []string{"object", "null"},
+			"additionalProperties": typeSchema(t.Elem()),
+		}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return map[string]any{}
+}
+
+// structSchema returns the json schema for a struct, using the json tags of
+// the exported fields as the property names.
+func structSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+
+		properties[name] = typeSchema(f.Type)
+	}
+
+	s := map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+
+	if e, ok := reflect.Zero(t).Interface().(schemaExtender); ok {
+		e.extendSchema(s)
+	}
+
+	return s
+}
+
+// property returns the schema of the named property of an object schema.
+func property(s map[string]any, name string) map[string]any {
+	return s["properties"].(map[string]any)[name].(map[string]any)
+}
+
+// enum returns the list of allowed values, including the empty string which
+// means the value is not set.
+func enum(values []string) []any {
+	list := []any{""}
+	for _, v := range values {
+		list = append(list, v)
+	}
+	return list
+}
+
+// negotiable returns the description of an Accept or Accept-Encoding value.
+// The value is not an enum because it may be a list with q-values and
+// wildcards, as long as it allows one of the supported values.
+func negotiable(kind string, supported []string) string {
+	return "a " + kind + ", or a list with q-values and wildcards, that allows one of: " + strings.Join(supported, ", ")
+}
+
+func (cd CustomDuration) jsonSchema() map[string]any {
+	return map[string]any{
+		"oneOf": []any{
+			map[string]any{
+				"type":        "string",
+				"description": "duration parsable by Go's time.ParseDuration with the additional units 'd' and 'w', an ISO 8601 duration without years or months, or a number of seconds, for example '5m', '1d' or 'P7D'",
+			},
+			map[string]any{
+				"type":        "number",
+				"description": "duration in seconds",
+			},
+		},
+	}
+}
+
+func (ct CustomTime) jsonSchema() map[string]any {
+	return map[string]any{
+		"oneOf": []any{
+			map[string]any{
+				"type":        "string",
+				"description": "RFC 3339 or RFC 1123 time, Unix epoch, or '+' and a duration after the current time, for example '2023-01-02T15:04:05Z' or '+24h'",
+			},
+			map[string]any{
+				"type":        "integer",
+				"description": "Unix epoch in seconds, or milliseconds if 100000000000 or larger",
+			},
+		},
+	}
+}
+
+func (sd StyledDuration) jsonSchema() map[string]any {
+	return sd.Duration.jsonSchema()
+}
+
+func (w Webhook) extendSchema(s map[string]any) {
+	property(s, "accept")["description"] = negotiable("media type", webhookAcceptTypes)
+	property(s, "accept_encoding")["description"] = negotiable("encoding", append(append([]string{}, acceptEncodings...), EncodingIdentity))
+	property(s, "secret_hash")["enum"] = enum(secretHashes)
+
+	// Either receiver_urls or dns_srv_record must be used, but not both.
+	s["oneOf"] = []any{
+		map[string]any{
+			"required": []string{"receiver_urls"},
+			"properties": map[string]any{
+				"receiver_urls": map[string]any{"type": "array", "minItems": 1},
+				"dns_srv_record": map[string]any{
+					"properties": map[string]any{
+						"fqdns": map[string]any{"maxItems": 0},
+					},
+				},
+			},
+		},
+		map[string]any{
+			"required": []string{"dns_srv_record"},
+			"properties": map[string]any{
+				"receiver_urls": map[string]any{"maxItems": 0},
+				"dns_srv_record": map[string]any{
+					"required": []string{"fqdns"},
+					"properties": map[string]any{
+						"fqdns": map[string]any{"type": "array", "minItems": 1},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (d DNSSrvRecord) extendSchema(s map[string]any) {
+	property(s, "load_balancing_scheme")["enum"] = enum(loadBalancingSchemes)
+}
+
+func (k Kafka) extendSchema(s map[string]any) {
+	property(s, "accept")["description"] = negotiable("media type", kafkaAcceptTypes)
+}
+
+func (kp KafkaProducer) extendSchema(s map[string]any) {
+	property(s, "compression")["enum"] = enum(kafkaCompressions)
+	property(s, "required_acks")["enum"] = enum(kafkaAcks)
+}
+
+func (ks KafkaSASL) extendSchema(s map[string]any) {
+	property(s, "mechanism")["enum"] = enum(kafkaSASLMechanisms)
+}
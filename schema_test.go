@@ -0,0 +1,147 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateSchema(t *testing.T, registration any) map[string]any {
+	b, err := GenerateSchema(registration)
+	require.NoError(t, err)
+
+	var s map[string]any
+	require.NoError(t, json.Unmarshal(b, &s))
+	return s
+}
+
+// lookup walks the schema following the keys given.
+func lookup(t *testing.T, s any, keys ...string) any {
+	for _, key := range keys {
+		m, ok := s.(map[string]any)
+		require.True(t, ok, "%s is not an object", key)
+		s, ok = m[key]
+		require.True(t, ok, "%s is not present", key)
+	}
+	return s
+}
+
+func TestGenerateSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	v1 := generateSchema(t, &RegistrationV1{})
+	assert.Equal(SchemaDraft, v1["$schema"])
+	assert.Equal("RegistrationV1", v1["title"])
+	assert.Equal("object", v1["type"])
+	assert.Equal("string", lookup(t, v1, "properties", "config", "properties", "url", "type"))
+	assert.Len(lookup(t, v1, "properties", "until", "oneOf"), 2)
+	assert.Equal([]any{"array", "null"}, lookup(t, v1, "properties", "events", "type"))
+	assert.Len(lookup(t, v1, "properties", "duration", "oneOf"), 2)
+
+	// unexported fields are not part of the schema
+	assert.Len(lookup(t, v1, "properties"), 7)
+
+	v2 := generateSchema(t, RegistrationV2{})
+	assert.Equal("RegistrationV2", v2["title"])
+
+	webhook := lookup(t, v2, "properties", "webhooks", "items")
+	// accept values may be negotiable lists, so they are not enums
+	assert.NotContains(lookup(t, webhook, "properties", "accept"), "enum")
+	assert.Contains(lookup(t, webhook, "properties", "accept", "description"), MediaTypeWRPMsgpackL)
+	assert.Contains(lookup(t, webhook, "properties", "accept_encoding", "description"), EncodingIdentity)
+	assert.Equal([]any{"", SecretHashSHA256, SecretHashSHA512},
+		lookup(t, webhook, "properties", "secret_hash", "enum"))
+	assert.Len(lookup(t, webhook, "oneOf"), 2)
+
+	kafka := lookup(t, v2, "properties", "kafkas", "items")
+	assert.NotContains(lookup(t, kafka, "properties", "accept"), "enum")
+	assert.Contains(lookup(t, kafka, "properties", "accept", "description"), MediaTypeMsgpack)
+	assert.Equal("integer", lookup(t, v2, "properties", "batch_hints", "properties", "max_linger_duration", "type"))
+
+	producer := lookup(t, kafka, "properties", "kafka_producer")
+	assert.Equal([]any{"", KafkaAcksNone, KafkaAcksLocal, KafkaAcksAll},
+		lookup(t, producer, "properties", "required_acks", "enum"))
+	assert.Contains(lookup(t, producer, "properties", "compression", "enum"), KafkaCompressionZstd)
+	assert.Contains(lookup(t, producer, "properties", "sasl", "properties", "mechanism", "enum"), KafkaSASLScramSHA512)
+}
+
+func TestGenerateSchemaErrors(t *testing.T) {
+	var nilPtr *RegistrationV1
+	tests := []struct {
+		description string
+		in          any
+	}{
+		{description: "nil"},
+		{description: "string", in: "foo"},
+		{description: "pointer to string", in: new(string)},
+		{description: "nil pointer", in: nilPtr},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			b, err := GenerateSchema(tc.in)
+			if tc.in == any(nilPtr) {
+				// a nil pointer still describes the type
+				assert.NoError(t, err)
+				assert.NotEmpty(t, b)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidType)
+			assert.Nil(t, b)
+		})
+	}
+}
+
+func TestTypeSchema(t *testing.T) {
+	type inner struct {
+		Name string `json:"name"`
+	}
+	type sample struct {
+		Skipped  string `json:"-"`
+		NoTag    bool
+		Float    float64           `json:"float,omitempty"`
+		Ptr      *inner            `json:"ptr"`
+		Bytes    []byte            `json:"bytes"`
+		Labels   map[string]string `json:"labels"`
+		Anything any               `json:"anything"`
+	}
+
+	s := typeSchema(reflect.TypeOf(sample{}))
+	assert := assert.New(t)
+	properties := s["properties"].(map[string]any)
+	assert.Len(properties, 6)
+	assert.Equal(map[string]any{"type": "boolean"}, properties["NoTag"])
+	assert.Equal(map[string]any{"type": "number"}, properties["float"])
+	assert.Equal("string", properties["bytes"].(map[string]any)["type"])
+	assert.Equal(map[string]any{"type": "string"}, properties["labels"].(map[string]any)["additionalProperties"])
+	assert.Equal(map[string]any{}, properties["anything"])
+	assert.Len(properties["ptr"].(map[string]any)["anyOf"], 2)
+}
+
+// TestCheckedInSchemas makes sure the schema documents in the repository are
+// up to date.  Run `go generate` to update them.
+func TestCheckedInSchemas(t *testing.T) {
+	files := map[string]any{
+		"registration_v1.schema.json": RegistrationV1{},
+		"registration_v2.schema.json": RegistrationV2{},
+	}
+
+	for name, registration := range files {
+		t.Run(name, func(t *testing.T) {
+			expected, err := GenerateSchema(registration)
+			require.NoError(t, err)
+
+			actual, err := os.ReadFile(filepath.Join("schemas", name))
+			require.NoError(t, err)
+			assert.Equal(t, string(expected)+"\n", string(actual), "run `go generate` to update the schema")
+		})
+	}
+}
@@ -0,0 +1,53 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import "sync"
+
+// sequencer delivers events in the order they happened without holding the
+// lock that orders them.  A ticket is taken while the lock is held, and the
+// delivery runs after the lock is released, once every earlier ticket has
+// run.  The callbacks are therefore free to take the lock, for example to
+// read the state that changed, while another goroutine waits for its turn.
+//
+// The zero value is ready to use.
+type sequencer struct {
+	mu   sync.Mutex
+	cond sync.Cond
+	next uint64
+	turn uint64
+}
+
+// ticket reserves the next turn.  It must be called while holding the lock
+// that orders the events, and every ticket must be passed to run.
+func (s *sequencer) ticket() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := s.next
+	s.next++
+	return t
+}
+
+// run waits for the turn of the ticket, calls fn and then passes the turn
+// on, even if fn panics.  It must be called after releasing the lock the
+// ticket was taken under.
+func (s *sequencer) run(t uint64, fn func()) {
+	s.mu.Lock()
+	if s.cond.L == nil {
+		s.cond.L = &s.mu
+	}
+	for s.turn != t {
+		s.cond.Wait()
+	}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.turn++
+		s.cond.Broadcast()
+		s.mu.Unlock()
+	}()
+	fn()
+}
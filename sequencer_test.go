@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSequencer(t *testing.T) {
+	const n = 100
+
+	var (
+		seq  sequencer
+		mu   sync.Mutex
+		last int
+		got  []int
+		wg   sync.WaitGroup
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			mu.Lock()
+			last++
+			event := last
+			ticket := seq.ticket()
+			mu.Unlock()
+
+			seq.run(ticket, func() {
+				// The lock that ordered the events is free to use.
+				mu.Lock()
+				defer mu.Unlock()
+				got = append(got, event)
+			})
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, got, n)
+	for i, event := range got {
+		assert.Equal(t, i+1, event)
+	}
+}
+
+func TestSequencerPanic(t *testing.T) {
+	var seq sequencer
+
+	assert.Panics(t, func() {
+		seq.run(seq.ticket(), func() { panic("boom") })
+	})
+
+	ran := false
+	seq.run(seq.ticket(), func() { ran = true })
+	assert.True(t, ran)
+}
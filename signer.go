@@ -0,0 +1,127 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // required by the RegistrationV1 schema
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// SignatureHeader is the http header that carries the signature of the body.
+const SignatureHeader = "X-Webpa-Signature"
+
+// SecretHashSHA1 is the hash algorithm used with the DeliveryConfig Secret.
+// It is only supported for backwards compatibility and cannot be used as the
+// Webhook SecretHash value.
+const SecretHashSHA1 = "sha1"
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+var signatureHashes = map[string]func() hash.Hash{
+	SecretHashSHA1:   sha1.New,
+	SecretHashSHA256: sha256.New,
+	SecretHashSHA512: sha512.New,
+}
+
+// Signer produces and verifies the HMAC signature of a request body.  The
+// signature is in the form `<algorithm>=<hex encoded hmac>`, for example
+// `sha512=164b7a7b...`.
+type Signer struct {
+	algorithm string
+	secret    []byte
+	hash      func() hash.Hash
+}
+
+// NewSigner creates a Signer for the hash algorithm, which is one of sha1,
+// sha256 or sha512, and the secret.
+func NewSigner(algorithm, secret string) (*Signer, error) {
+	h, ok := signatureHashes[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported secret hash %q", ErrInvalidInput, algorithm)
+	}
+	if secret == "" {
+		return nil, fmt.Errorf("%w: a secret is required", ErrInvalidInput)
+	}
+
+	return &Signer{
+		algorithm: algorithm,
+		secret:    []byte(secret),
+		hash:      h,
+	}, nil
+}
+
+// Signer returns the Signer for the webhook.  The SecretHash defaults to
+// sha512 if it is not set.  If the webhook does not have a Secret, signing is
+// disabled and nil is returned.
+func (w Webhook) Signer() (*Signer, error) {
+	if w.Secret == "" {
+		return nil, nil
+	}
+
+	algorithm := w.SecretHash
+	if algorithm == "" {
+		algorithm = SecretHashSHA512
+	}
+	if !contains(secretHashes, algorithm) {
+		return nil, fmt.Errorf("%w: unsupported secret hash %q", ErrInvalidInput, algorithm)
+	}
+
+	return NewSigner(algorithm, w.Secret)
+}
+
+// Signer returns the sha1 Signer for the delivery config.  If the delivery
+// config does not have a Secret, signing is disabled and nil is returned.
+func (dc DeliveryConfig) Signer() (*Signer, error) {
+	if dc.Secret == "" {
+		return nil, nil
+	}
+	return NewSigner(SecretHashSHA1, dc.Secret)
+}
+
+// Algorithm returns the name of the hash algorithm used by the Signer.
+func (s *Signer) Algorithm() string {
+	return s.algorithm
+}
+
+// Sign returns the signature of the body.
+func (s *Signer) Sign(body []byte) string {
+	return s.algorithm + "=" + hex.EncodeToString(s.mac(body))
+}
+
+// Verify checks that the signature matches the body.  The signature must use
+// the same algorithm as the Signer.  The comparison is done in constant time.
+func (s *Signer) Verify(body []byte, signature string) error {
+	algorithm, sig, found := strings.Cut(signature, "=")
+	if !found {
+		return fmt.Errorf("%w: expected the form <algorithm>=<hex>", ErrInvalidSignature)
+	}
+	if algorithm != s.algorithm {
+		return fmt.Errorf("%w: expected the %s algorithm, not %q", ErrInvalidSignature, s.algorithm, algorithm)
+	}
+
+	mac, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSignature, err)
+	}
+
+	if !hmac.Equal(mac, s.mac(body)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *Signer) mac(body []byte) []byte {
+	m := hmac.New(s.hash, s.secret)
+	m.Write(body)
+	return m.Sum(nil)
+}
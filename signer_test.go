@@ -0,0 +1,150 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// The "Jefe" vectors are from RFC 2202 (sha1) and RFC 4231 (sha256, sha512).
+var signerVectors = []struct {
+	algorithm string
+	secret    string
+	body      string
+	signature string
+}{
+	{
+		algorithm: SecretHashSHA1,
+		secret:    "Jefe",
+		body:      "what do ya want for nothing?",
+		signature: "sha1=effcdf6ae5eb2fa2d27416d5f184df9c259a7c79",
+	}, {
+		algorithm: SecretHashSHA256,
+		secret:    "Jefe",
+		body:      "what do ya want for nothing?",
+		signature: "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843",
+	}, {
+		algorithm: SecretHashSHA512,
+		secret:    "Jefe",
+		body:      "what do ya want for nothing?",
+		signature: "sha512=164b7a7bfcf819e2e395fbe73b56e0a387bd64222e831fd610270cd7ea2505549758bf75c05a994a6d034f65f8f0e6fdcaeab1a34d4a6b4b636e070a38bce737",
+	}, {
+		algorithm: SecretHashSHA1,
+		secret:    "secret",
+		body:      `{"msg_type":4}`,
+		signature: "sha1=0ec6edff02ab84185314103d2fe961fcf9db4ab4",
+	}, {
+		algorithm: SecretHashSHA256,
+		secret:    "secret",
+		body:      `{"msg_type":4}`,
+		signature: "sha256=dc94192108e34c1ce4002bbc332ca8e5779049d8f57e16672c612b8361ba16ea",
+	}, {
+		algorithm: SecretHashSHA512,
+		secret:    "secret",
+		body:      `{"msg_type":4}`,
+		signature: "sha512=c70e82b2506ab7aeb4bc0b5b596bdbd9218d5a77db5689f6b870e8e1cfed371009089ced75253527d98435db870184e38b13d551bd16c4e3dbc54874d322876d",
+	},
+}
+
+func TestSignerVectors(t *testing.T) {
+	for _, tc := range signerVectors {
+		t.Run(tc.signature[:12], func(t *testing.T) {
+			assert := assert.New(t)
+			s, err := NewSigner(tc.algorithm, tc.secret)
+			require.NoError(t, err)
+
+			assert.Equal(tc.algorithm, s.Algorithm())
+			assert.Equal(tc.signature, s.Sign([]byte(tc.body)))
+			assert.NoError(s.Verify([]byte(tc.body), tc.signature))
+		})
+	}
+}
+
+func TestSignerVerifyFailures(t *testing.T) {
+	s, err := NewSigner(SecretHashSHA256, "Jefe")
+	require.NoError(t, err)
+
+	body := []byte("what do ya want for nothing?")
+	valid := s.Sign(body)
+
+	tests := []struct {
+		description string
+		body        []byte
+		signature   string
+	}{
+		{description: "empty signature"},
+		{description: "no algorithm", body: body, signature: strings.TrimPrefix(valid, "sha256=")},
+		{description: "different algorithm", body: body, signature: "sha512=" + strings.TrimPrefix(valid, "sha256=")},
+		{description: "not hex", body: body, signature: "sha256=zz"},
+		{description: "truncated", body: body, signature: valid[:len(valid)-2]},
+		{description: "different body", body: []byte("what do ya want for something?"), signature: valid},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.ErrorIs(t, s.Verify(tc.body, tc.signature), ErrInvalidSignature)
+		})
+	}
+}
+
+func TestNewSignerErrors(t *testing.T) {
+	_, err := NewSigner("md5", "secret")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+
+	_, err = NewSigner(SecretHashSHA256, "")
+	assert.ErrorIs(t, err, ErrInvalidInput)
+}
+
+func TestWebhookSigner(t *testing.T) {
+	tests := []struct {
+		description string
+		in          Webhook
+		algorithm   string
+		expectedErr error
+	}{
+		{
+			description: "no secret",
+			in:          Webhook{SecretHash: SecretHashSHA256},
+		}, {
+			description: "default hash",
+			in:          Webhook{Secret: "secret"},
+			algorithm:   SecretHashSHA512,
+		}, {
+			description: "sha256",
+			in:          Webhook{Secret: "secret", SecretHash: SecretHashSHA256},
+			algorithm:   SecretHashSHA256,
+		}, {
+			description: "sha1 is not allowed",
+			in:          Webhook{Secret: "secret", SecretHash: SecretHashSHA1},
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			s, err := tc.in.Signer()
+			assert.ErrorIs(t, err, tc.expectedErr)
+			if tc.algorithm == "" {
+				assert.Nil(t, s)
+				return
+			}
+			require.NotNil(t, s)
+			assert.Equal(t, tc.algorithm, s.Algorithm())
+		})
+	}
+}
+
+func TestDeliveryConfigSigner(t *testing.T) {
+	s, err := DeliveryConfig{}.Signer()
+	assert.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = DeliveryConfig{Secret: "Jefe"}.Signer()
+	require.NoError(t, err)
+	assert.Equal(t, signerVectors[0].signature, s.Sign([]byte(signerVectors[0].body)))
+}
@@ -0,0 +1,173 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNoSRVTargets = errors.New("no srv targets")
+)
+
+// SRVLookup looks up dns srv records.  It is implemented by *net.Resolver.
+type SRVLookup interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// SRVResolver resolves the FQDNs of a DNSSrvRecord into the receiver URLs of
+// the srv targets.
+type SRVResolver struct {
+	// Lookup looks up the srv records.  If nil, net.DefaultResolver is used.
+	Lookup SRVLookup
+
+	// URLScheme is the scheme of the receiver URLs.  If empty, https is used.
+	URLScheme string
+
+	// Rand returns a pseudo-random number in [0,n).  It is used to pick the
+	// targets by weight.  If nil, math/rand.Intn is used.
+	Rand func(n int) int
+}
+
+// Resolve looks up the srv records of each of the FQDNs and returns the
+// receiver URLs of the targets in the order they should be used.  The order
+// depends on the LoadBalancingScheme:
+//   - priority (the default) orders the targets by priority, lowest first, as
+//     described by RFC 2782.  Targets with the same priority are in weighted
+//     random order.
+//   - weight ignores the priority and puts all of the targets in weighted
+//     random order.
+//
+// Targets of "." mean the service is not available and are skipped.  If some
+// of the lookups fail, the URLs of the others are returned.  An error is only
+// returned if the record is invalid or there are no targets.
+func (r *SRVResolver) Resolve(ctx context.Context, record DNSSrvRecord) ([]string, error) {
+	if len(record.FQDNs) == 0 {
+		return nil, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    "dns_srv_record.fqdns",
+			Message: "at least one fqdn is required",
+		}
+	}
+	if err := record.validate("dns_srv_record"); err != nil {
+		return nil, err
+	}
+
+	var (
+		records []*net.SRV
+		errs    error
+	)
+	for _, fqdn := range record.FQDNs {
+		_, addrs, err := r.lookup().LookupSRV(ctx, "", "", fqdn)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if addr != nil && addr.Target != "." && addr.Target != "" {
+				records = append(records, addr)
+			}
+		}
+	}
+
+	if len(records) == 0 {
+		if errs != nil {
+			return nil, fmt.Errorf("%w: %w", ErrNoSRVTargets, errs)
+		}
+		return nil, fmt.Errorf("%w: %s", ErrNoSRVTargets, strings.Join(record.FQDNs, ", "))
+	}
+
+	if record.LoadBalancingScheme == LoadBalancingWeight {
+		records = weightedOrder(records, r.rand())
+	} else {
+		records = priorityOrder(records, r.rand())
+	}
+
+	scheme := r.URLScheme
+	if scheme == "" {
+		scheme = "https"
+	}
+
+	urls := make([]string, 0, len(records))
+	for _, rec := range records {
+		host := strings.TrimSuffix(rec.Target, ".")
+		urls = append(urls, scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(rec.Port))))
+	}
+	return urls, nil
+}
+
+func (r *SRVResolver) lookup() SRVLookup {
+	if r.Lookup != nil {
+		return r.Lookup
+	}
+	return net.DefaultResolver
+}
+
+func (r *SRVResolver) rand() func(int) int {
+	if r.Rand != nil {
+		return r.Rand
+	}
+	return rand.Intn
+}
+
+// priorityOrder sorts the records by priority, and the records with the same
+// priority by weight using weightedOrder.
+func priorityOrder(records []*net.SRV, rnd func(int) int) []*net.SRV {
+	sorted := make([]*net.SRV, len(records))
+	copy(sorted, records)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Priority < sorted[j].Priority
+	})
+
+	ordered := make([]*net.SRV, 0, len(sorted))
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[end].Priority == sorted[start].Priority {
+			end++
+		}
+		ordered = append(ordered, weightedOrder(sorted[start:end], rnd)...)
+		start = end
+	}
+	return ordered
+}
+
+// weightedOrder orders the records using the selection algorithm from RFC 2782.
+// Each record is picked with a probability proportional to its weight from the
+// records that have not been picked yet.  Records with a weight of zero are
+// placed first in the list, so they have a very small chance of being picked
+// before the others.
+func weightedOrder(records []*net.SRV, rnd func(int) int) []*net.SRV {
+	remaining := make([]*net.SRV, len(records))
+	copy(remaining, records)
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return remaining[i].Weight == 0 && remaining[j].Weight != 0
+	})
+
+	ordered := make([]*net.SRV, 0, len(remaining))
+	for len(remaining) > 0 {
+		var sum int
+		for _, rec := range remaining {
+			sum += int(rec.Weight)
+		}
+
+		n := rnd(sum + 1)
+		i, running := 0, int(remaining[0].Weight)
+		for running < n && i < len(remaining)-1 {
+			i++
+			running += int(remaining[i].Weight)
+		}
+
+		ordered = append(ordered, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+	return ordered
+}
@@ -0,0 +1,204 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errLookup = errors.New("lookup failed")
+
+type fakeSRVLookup map[string][]*net.SRV
+
+func (f fakeSRVLookup) LookupSRV(_ context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if service != "" || proto != "" {
+		return "", nil, errors.New("the fqdn should be looked up directly")
+	}
+	addrs, ok := f[strings.TrimSuffix(name, ".")]
+	if !ok {
+		return "", nil, errLookup
+	}
+	return name, addrs, nil
+}
+
+// sequence returns a rand function that returns the values in order.
+func sequence(values ...int) func(int) int {
+	return func(n int) int {
+		v := values[0]
+		values = values[1:]
+		if v >= n {
+			panic("value out of range")
+		}
+		return v
+	}
+}
+
+func TestSRVResolverResolve(t *testing.T) {
+	lookup := fakeSRVLookup{
+		"_webhook._tcp.example.com": {
+			{Target: "c.example.com.", Port: 443, Priority: 20, Weight: 1},
+			{Target: "a.example.com.", Port: 8443, Priority: 10, Weight: 1},
+			{Target: "b.example.com.", Port: 443, Priority: 10, Weight: 3},
+		},
+		"_webhook._tcp.example.org": {
+			{Target: "d.example.org.", Port: 80, Priority: 5, Weight: 0},
+		},
+		"_webhook._tcp.example.net": {
+			{Target: ".", Port: 0},
+		},
+	}
+
+	tests := []struct {
+		description string
+		resolver    SRVResolver
+		record      DNSSrvRecord
+		expected    []string
+		expectedErr error
+	}{
+		{
+			description: "priority order",
+			resolver:    SRVResolver{Lookup: lookup, Rand: sequence(0, 0, 0)},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}},
+			expected: []string{
+				"https://a.example.com:8443",
+				"https://b.example.com:443",
+				"https://c.example.com:443",
+			},
+		}, {
+			description: "priority order, weighted pick within a priority",
+			resolver:    SRVResolver{Lookup: lookup, Rand: sequence(2, 0, 0)},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}, LoadBalancingScheme: LoadBalancingPriority},
+			expected: []string{
+				"https://b.example.com:443",
+				"https://a.example.com:8443",
+				"https://c.example.com:443",
+			},
+		}, {
+			description: "weight order ignores the priority",
+			resolver:    SRVResolver{Lookup: lookup, Rand: sequence(0, 2, 0)},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}, LoadBalancingScheme: LoadBalancingWeight},
+			expected: []string{
+				"https://c.example.com:443",
+				"https://b.example.com:443",
+				"https://a.example.com:8443",
+			},
+		}, {
+			description: "multiple fqdns with a custom scheme",
+			resolver:    SRVResolver{Lookup: lookup, URLScheme: "http", Rand: sequence(0, 0, 0, 0)},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com", "_webhook._tcp.example.org."}},
+			expected: []string{
+				"http://d.example.org:80",
+				"http://a.example.com:8443",
+				"http://b.example.com:443",
+				"http://c.example.com:443",
+			},
+		}, {
+			description: "failed lookups are skipped",
+			resolver:    SRVResolver{Lookup: lookup, Rand: sequence(0)},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.invalid", "_webhook._tcp.example.org"}},
+			expected:    []string{"https://d.example.org:80"},
+		}, {
+			description: "all lookups fail",
+			resolver:    SRVResolver{Lookup: lookup},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.invalid"}},
+			expectedErr: errLookup,
+		}, {
+			description: "service not available",
+			resolver:    SRVResolver{Lookup: lookup},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.net"}},
+			expectedErr: ErrNoSRVTargets,
+		}, {
+			description: "no fqdns",
+			resolver:    SRVResolver{Lookup: lookup},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid fqdn",
+			resolver:    SRVResolver{Lookup: lookup},
+			record:      DNSSrvRecord{FQDNs: []string{"example..com"}},
+			expectedErr: ErrInvalidInput,
+		}, {
+			description: "invalid scheme",
+			resolver:    SRVResolver{Lookup: lookup},
+			record:      DNSSrvRecord{FQDNs: []string{"_webhook._tcp.example.com"}, LoadBalancingScheme: "random"},
+			expectedErr: ErrInvalidInput,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			urls, err := tc.resolver.Resolve(context.Background(), tc.record)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, urls)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, urls)
+		})
+	}
+}
+
+func TestWeightedOrderDistribution(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "light", Weight: 1},
+		{Target: "heavy", Weight: 3},
+		{Target: "zero", Weight: 0},
+	}
+
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec // deterministic test
+	first := map[string]int{}
+	const trials = 10000
+	for i := 0; i < trials; i++ {
+		ordered := weightedOrder(records, rnd.Intn)
+		require.Len(t, ordered, 3)
+		first[ordered[0].Target]++
+	}
+
+	// The first pick is proportional to the weight, with the zero weight
+	// record only picked when the random number is zero.
+	assert.InDelta(t, 0.2, float64(first["light"])/trials, 0.03)
+	assert.InDelta(t, 0.6, float64(first["heavy"])/trials, 0.03)
+	assert.InDelta(t, 0.2, float64(first["zero"])/trials, 0.03)
+
+	// the input is not modified
+	assert.Equal(t, "light", records[0].Target)
+}
+
+func TestValidateFQDN(t *testing.T) {
+	long := strings.Repeat("a", 64)
+
+	valid := []string{
+		"example.com",
+		"example.com.",
+		"_webhook._tcp.example.com",
+		"a-b.example.com",
+		"localhost",
+	}
+	for _, fqdn := range valid {
+		assert.NoError(t, validateFQDN(fqdn), fqdn)
+	}
+
+	invalid := []string{
+		"",
+		".",
+		"example..com",
+		".example.com",
+		"-example.com",
+		"example-.com",
+		"exa mple.com",
+		"example.com/path",
+		long + ".com",
+	}
+	for _, fqdn := range invalid {
+		assert.Error(t, validateFQDN(fqdn), fqdn)
+	}
+}
@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+var (
+	ErrNotFound = errors.New("registration not found")
+)
+
+// Store persists registrations so they survive restarts.  The registrations
+// are identified by their RegistrationKey.  See the storetest package for the
+// contract every Store must meet.
+type Store interface {
+	// Get returns the registration with the key, or an error wrapping
+	// ErrNotFound.
+	Get(ctx context.Context, key RegistrationKey) (Registration, error)
+
+	// List returns all of the registrations, ordered by version and then by
+	// ID.
+	List(ctx context.Context) ([]Registration, error)
+
+	// Upsert adds the registration, replacing any registration with the same
+	// key.
+	Upsert(ctx context.Context, r Registration) error
+
+	// Delete removes the registration with the key, or returns an error
+	// wrapping ErrNotFound.
+	Delete(ctx context.Context, key RegistrationKey) error
+
+	// Watch calls fn with a RegistryAdded, RegistryUpdated or RegistryDeleted
+	// event for each change to the Store until the context is done.  The
+	// events are delivered in order, but may be delivered after the change
+	// is complete.  The function must not change the Store.
+	Watch(ctx context.Context, fn func(RegistryEvent)) error
+}
+
+// keyLess orders keys by version and then by ID.
+func keyLess(a, b RegistrationKey) bool {
+	if a.Version != b.Version {
+		return a.Version < b.Version
+	}
+	return a.ID < b.ID
+}
+
+// MemoryStore is a Store that keeps the registrations in memory.  It is safe
+// for concurrent use.  The registrations it holds must not be modified.
+type MemoryStore struct {
+	seq sequencer
+
+	mu       sync.Mutex
+	regs     map[RegistrationKey]Registration
+	watchers map[int]func(RegistryEvent)
+	nextID   int
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		regs:     make(map[RegistrationKey]Registration),
+		watchers: make(map[int]func(RegistryEvent)),
+	}
+}
+
+func (m *MemoryStore) Get(_ context.Context, key RegistrationKey) (Registration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, found := m.regs[key]; found {
+		return r, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+}
+
+func (m *MemoryStore) List(_ context.Context) ([]Registration, error) {
+	m.mu.Lock()
+	keys := make([]RegistrationKey, 0, len(m.regs))
+	for k := range m.regs {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keyLess(keys[i], keys[j])
+	})
+
+	list := make([]Registration, len(keys))
+	for i, k := range keys {
+		list[i] = m.regs[k]
+	}
+	m.mu.Unlock()
+
+	return list, nil
+}
+
+func (m *MemoryStore) Upsert(_ context.Context, r Registration) error {
+	key, err := KeyOf(r)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.upsert(key, r)
+	return nil
+}
+
+// upsert adds the registration and releases mu.  mu must be held.
+func (m *MemoryStore) upsert(key RegistrationKey, r Registration) {
+	event := RegistryEvent{
+		Type:         RegistryAdded,
+		Key:          key,
+		Registration: r,
+	}
+	if prev, found := m.regs[key]; found {
+		event.Type = RegistryUpdated
+		event.Previous = prev
+	}
+	m.regs[key] = r
+	m.release(event)
+}
+
+func (m *MemoryStore) Delete(_ context.Context, key RegistrationKey) error {
+	m.mu.Lock()
+	if _, found := m.regs[key]; !found {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotFound, key)
+	}
+	m.delete(key)
+	return nil
+}
+
+// delete removes the registration and releases mu.  mu must be held.
+func (m *MemoryStore) delete(key RegistrationKey) {
+	r := m.regs[key]
+	delete(m.regs, key)
+	m.release(RegistryEvent{
+		Type:         RegistryDeleted,
+		Key:          key,
+		Registration: r,
+	})
+}
+
+func (m *MemoryStore) Watch(ctx context.Context, fn func(RegistryEvent)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	id := m.nextID
+	m.nextID++
+	m.watchers[id] = fn
+	m.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		delete(m.watchers, id)
+	}()
+	return nil
+}
+
+// release unlocks mu and passes the event to the watchers.  mu must be held.
+func (m *MemoryStore) release(event RegistryEvent) {
+	ids := make([]int, 0, len(m.watchers))
+	for id := range m.watchers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	watchers := make([]func(RegistryEvent), len(ids))
+	for i, id := range ids {
+		watchers[i] = m.watchers[id]
+	}
+
+	t := m.seq.ticket()
+	m.mu.Unlock()
+	m.seq.run(t, func() {
+		for _, fn := range watchers {
+			fn(event)
+		}
+	})
+}
+
+// Load adds the registrations in the Store to the Registry.  Registrations
+// that fail validation or have expired are skipped, and their errors are
+// returned together.
+func (r *Registry) Load(ctx context.Context, s Store) error {
+	list, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for _, reg := range list {
+		if _, err := r.Upsert(reg); err != nil {
+			key, _ := KeyOf(reg)
+			errs = errors.Join(errs, fmt.Errorf("%s: %w", key, err))
+		}
+	}
+	return errs
+}
+
+// Persist writes the registrations in the Registry, and then each change to
+// it, to the Store until the returned cancel function is called.  Expired and
+// deleted registrations are deleted from the Store.  Errors from the Store
+// are passed to onErr, if it is not nil.
+//
+// A RegistrationV1 that expires a Duration after it was added is stored
+// with an Until of when it expires, so loading it after a restart does not
+// start its Duration over.
+func (r *Registry) Persist(ctx context.Context, s Store, onErr func(error)) (cancel func()) {
+	return r.Subscribe(func(e RegistryEvent) {
+		var err error
+		switch e.Type {
+		case RegistryAdded, RegistryUpdated:
+			err = s.Upsert(ctx, persisted(e))
+		case RegistryExpired, RegistryDeleted:
+			if err = s.Delete(ctx, e.Key); errors.Is(err, ErrNotFound) {
+				err = nil
+			}
+		}
+		if err != nil && onErr != nil {
+			onErr(err)
+		}
+	}, ReplayExisting())
+}
+
+// persisted returns the registration of the event as it is stored.  A
+// RegistrationV1 without an Until is copied with an Until of when it
+// expires in place of its Duration.
+func persisted(e RegistryEvent) Registration {
+	v1, ok := e.Registration.(*RegistrationV1)
+	if !ok || !v1.Until.IsZero() || e.ExpiresAt.IsZero() {
+		return e.Registration
+	}
+
+	stored := *v1
+	stored.Until = CustomTime{Time: e.ExpiresAt}
+	stored.Duration = 0
+	return &stored
+}
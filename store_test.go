@@ -0,0 +1,40 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	webhook "github.com/xmidt-org/webhook-schema"
+	"github.com/xmidt-org/webhook-schema/storetest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	storetest.Run(t, func(*testing.T) webhook.Store {
+		return webhook.NewMemoryStore()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	open := func(dir string) webhook.Store {
+		s, err := webhook.OpenFileStore(dir)
+		require.NoError(t, err)
+		return s
+	}
+	closeStore := func(s webhook.Store) {
+		require.NoError(t, s.(*webhook.FileStore).Close())
+	}
+
+	storetest.Run(t, func(t *testing.T) webhook.Store {
+		s := open(t.TempDir())
+		t.Cleanup(func() { closeStore(s) })
+		return s
+	})
+
+	dir := t.TempDir()
+	storetest.RunPersistent(t, func(*testing.T) webhook.Store {
+		return open(dir)
+	}, closeStore)
+}
@@ -0,0 +1,283 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package storetest provides the conformance tests that every
+// webhook.Store implementation must pass.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	webhook "github.com/xmidt-org/webhook-schema"
+)
+
+// waitFor is how long the tests wait for a Watch event to be delivered.
+const waitFor = 5 * time.Second
+
+// Run runs the conformance tests against the stores created by newStore.
+// Each call to newStore must return a new, empty Store.
+func Run(t *testing.T, newStore func(t *testing.T) webhook.Store) {
+	tests := []struct {
+		name string
+		test func(*testing.T, webhook.Store)
+	}{
+		{name: "GetMissing", test: testGetMissing},
+		{name: "UpsertGet", test: testUpsertGet},
+		{name: "Override", test: testOverride},
+		{name: "VersionsAreSeparate", test: testVersionsAreSeparate},
+		{name: "List", test: testList},
+		{name: "Delete", test: testDelete},
+		{name: "InvalidKey", test: testInvalidKey},
+		{name: "Watch", test: testWatch},
+		{name: "WatchCanceled", test: testWatchCanceled},
+		{name: "Concurrent", test: testConcurrent},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newStore(t))
+		})
+	}
+}
+
+// RunPersistent runs the conformance tests for a Store that keeps the
+// registrations across restarts.  Each call to open must return a Store
+// backed by the same storage, and the Store returned by the previous call is
+// closed first with close, if it is not nil.
+func RunPersistent(t *testing.T, open func(t *testing.T) webhook.Store, close func(webhook.Store)) {
+	ctx := context.Background()
+	reopen := func(s webhook.Store) webhook.Store {
+		if close != nil {
+			close(s)
+		}
+		return open(t)
+	}
+
+	s := open(t)
+	a := v2("a", "first")
+	require.NoError(t, s.Upsert(ctx, a))
+	require.NoError(t, s.Upsert(ctx, v2("b", "first")))
+	require.NoError(t, s.Upsert(ctx, v1("https://a.example.com")))
+	require.NoError(t, s.Delete(ctx, webhook.RegistrationKey{Version: 2, ID: "b"}))
+
+	s = reopen(s)
+	assertKeys(t, s, []webhook.RegistrationKey{
+		{Version: 1, ID: "https://a.example.com"},
+		{Version: 2, ID: "a"},
+	})
+	got, err := s.Get(ctx, webhook.RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "first", got.(*webhook.RegistrationV2).FailureURL)
+
+	require.NoError(t, s.Upsert(ctx, v2("a", "second")))
+	s = reopen(s)
+	got, err = s.Get(ctx, webhook.RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.(*webhook.RegistrationV2).FailureURL)
+
+	if close != nil {
+		close(s)
+	}
+}
+
+func v1(url string) *webhook.RegistrationV1 {
+	return &webhook.RegistrationV1{
+		Config: webhook.DeliveryConfig{ReceiverURL: url},
+		Events: []string{".*"},
+	}
+}
+
+func v2(name, failureURL string) *webhook.RegistrationV2 {
+	return &webhook.RegistrationV2{
+		CanonicalName: name,
+		FailureURL:    failureURL,
+		Webhooks: []webhook.Webhook{
+			{ReceiverURLs: []string{"https://" + name + ".example.com"}},
+		},
+	}
+}
+
+func keys(t *testing.T, list []webhook.Registration) []webhook.RegistrationKey {
+	var keys []webhook.RegistrationKey
+	for _, r := range list {
+		key, err := webhook.KeyOf(r)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func assertKeys(t *testing.T, s webhook.Store, expected []webhook.RegistrationKey) {
+	list, err := s.List(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, keys(t, list))
+}
+
+func testGetMissing(t *testing.T, s webhook.Store) {
+	_, err := s.Get(context.Background(), webhook.RegistrationKey{Version: 2, ID: "missing"})
+	assert.ErrorIs(t, err, webhook.ErrNotFound)
+
+	list, err := s.List(context.Background())
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func testUpsertGet(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	r := v2("a", "https://failure.example.com")
+	require.NoError(t, s.Upsert(ctx, r))
+
+	got, err := s.Get(ctx, webhook.RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, r.CanonicalName, got.(*webhook.RegistrationV2).CanonicalName)
+	assert.Equal(t, r.FailureURL, got.(*webhook.RegistrationV2).FailureURL)
+	assert.Equal(t, r.Webhooks, got.(*webhook.RegistrationV2).Webhooks)
+}
+
+func testOverride(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.Upsert(ctx, v2("a", "first")))
+	require.NoError(t, s.Upsert(ctx, v2("a", "second")))
+
+	got, err := s.Get(ctx, webhook.RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(t, err)
+	assert.Equal(t, "second", got.(*webhook.RegistrationV2).FailureURL)
+	assertKeys(t, s, []webhook.RegistrationKey{{Version: 2, ID: "a"}})
+}
+
+func testVersionsAreSeparate(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.Upsert(ctx, v1("a")))
+	require.NoError(t, s.Upsert(ctx, v2("a", "")))
+
+	got, err := s.Get(ctx, webhook.RegistrationKey{Version: 1, ID: "a"})
+	require.NoError(t, err)
+	assert.IsType(t, &webhook.RegistrationV1{}, got)
+
+	got, err = s.Get(ctx, webhook.RegistrationKey{Version: 2, ID: "a"})
+	require.NoError(t, err)
+	assert.IsType(t, &webhook.RegistrationV2{}, got)
+}
+
+func testList(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	for _, r := range []webhook.Registration{v2("c", ""), v1("b"), v2("a", ""), v1("a")} {
+		require.NoError(t, s.Upsert(ctx, r))
+	}
+	assertKeys(t, s, []webhook.RegistrationKey{
+		{Version: 1, ID: "a"},
+		{Version: 1, ID: "b"},
+		{Version: 2, ID: "a"},
+		{Version: 2, ID: "c"},
+	})
+}
+
+func testDelete(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	key := webhook.RegistrationKey{Version: 2, ID: "a"}
+	require.NoError(t, s.Upsert(ctx, v2("a", "")))
+	require.NoError(t, s.Upsert(ctx, v2("b", "")))
+
+	require.NoError(t, s.Delete(ctx, key))
+	_, err := s.Get(ctx, key)
+	assert.ErrorIs(t, err, webhook.ErrNotFound)
+	assert.ErrorIs(t, s.Delete(ctx, key), webhook.ErrNotFound)
+	assertKeys(t, s, []webhook.RegistrationKey{{Version: 2, ID: "b"}})
+}
+
+func testInvalidKey(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	assert.ErrorIs(t, s.Upsert(ctx, &webhook.RegistrationV2{}), webhook.ErrInvalidInput)
+	assert.ErrorIs(t, s.Upsert(ctx, &webhook.RegistrationV1{}), webhook.ErrInvalidInput)
+	assertKeys(t, s, nil)
+}
+
+// recorder collects the events of a Watch.
+type recorder struct {
+	mu     sync.Mutex
+	events []webhook.RegistryEvent
+}
+
+func (r *recorder) record(e webhook.RegistryEvent) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *recorder) summary() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var list []string
+	for _, e := range r.events {
+		list = append(list, fmt.Sprintf("%s %s", e.Type, e.Key))
+	}
+	return list
+}
+
+func testWatch(t *testing.T, s webhook.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var rec recorder
+	require.NoError(t, s.Watch(ctx, rec.record))
+
+	require.NoError(t, s.Upsert(ctx, v2("a", "first")))
+	require.NoError(t, s.Upsert(ctx, v2("a", "second")))
+	require.NoError(t, s.Upsert(ctx, v1("b")))
+	require.NoError(t, s.Delete(ctx, webhook.RegistrationKey{Version: 2, ID: "a"}))
+
+	expected := []string{"added v2:a", "updated v2:a", "added v1:b", "deleted v2:a"}
+	assert.Eventually(t, func() bool {
+		return len(rec.summary()) >= len(expected)
+	}, waitFor, time.Millisecond)
+	assert.Equal(t, expected, rec.summary())
+}
+
+func testWatchCanceled(t *testing.T, s webhook.Store) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Error(t, s.Watch(ctx, func(webhook.RegistryEvent) {}))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	var rec recorder
+	require.NoError(t, s.Watch(ctx, rec.record))
+	cancel()
+
+	// The watcher is removed some time after the context is done, so keep
+	// making changes until the events stop.
+	assert.Eventually(t, func() bool {
+		before := len(rec.summary())
+		require.NoError(t, s.Upsert(context.Background(), v2("a", "")))
+		time.Sleep(10 * time.Millisecond)
+		return len(rec.summary()) == before
+	}, waitFor, time.Millisecond)
+}
+
+func testConcurrent(t *testing.T, s webhook.Store) {
+	ctx := context.Background()
+	const workers, names = 8, 5
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				name := fmt.Sprintf("r%d", (w+i)%names)
+				assert.NoError(t, s.Upsert(ctx, v2(name, "")))
+				_, err := s.List(ctx)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	list, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Len(t, list, names)
+}
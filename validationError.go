@@ -0,0 +1,144 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"strings"
+)
+
+// Machine readable codes describing why a validation failed.
+const (
+	// CodeRequired means a required value is missing.
+	CodeRequired = "required"
+
+	// CodeInvalidRegex means a value is not a valid regular expression.
+	CodeInvalidRegex = "invalid_regex"
+
+	// CodeInvalidValue means a value is not one of the allowed values.
+	CodeInvalidValue = "invalid_value"
+
+	// CodeUnknownField means a value refers to a field that does not exist.
+	CodeUnknownField = "unknown_field"
+
+	// CodeInvalidURL means a value is not an acceptable url.
+	CodeInvalidURL = "invalid_url"
+
+	// CodeOutOfRange means a value is outside of the allowed bounds.
+	CodeOutOfRange = "out_of_range"
+
+	// CodeExpired means the registration has already expired.
+	CodeExpired = "expired"
+
+	// CodeConflict means a value cannot be used together with another value.
+	CodeConflict = "conflict"
+
+	// CodeNotAllowed means a value is set that is not allowed to be set.
+	CodeNotAllowed = "not_allowed"
+
+	// CodeNotAcceptable means none of the supported values are allowed by an
+	// Accept or AcceptEncoding value.
+	CodeNotAcceptable = "not_acceptable"
+
+	// CodeUnsupported means the option does not apply to the registration
+	// version it was used with.
+	CodeUnsupported = "unsupported"
+)
+
+// ValidationError describes a single validation failure of a registration.
+// It wraps one of the package errors (such as ErrInvalidInput or
+// ErrInvalidType) so errors.Is continues to work with those errors.
+type ValidationError struct {
+	// Err is the package error describing the class of failure, usually
+	// ErrInvalidInput or ErrInvalidType.
+	Err error `json:"-"`
+
+	// Code is the machine readable reason for the failure.
+	Code string `json:"code"`
+
+	// Path is the json path to the offending field, for example
+	// `webhooks[1].receiver_urls[0]`.  It is empty if the failure does not
+	// apply to a single field.
+	Path string `json:"path,omitempty"`
+
+	// Value is the offending value, if there is one.
+	Value any `json:"value,omitempty"`
+
+	// Option is the String() of the Option that produced the failure.  It is
+	// set by Validators.Validate.
+	Option string `json:"option,omitempty"`
+
+	// Message is the human readable description of the failure.
+	Message string `json:"message"`
+
+	// Cause is the underlying error that caused the failure, if any.
+	Cause error `json:"-"`
+}
+
+func (ve *ValidationError) Error() string {
+	var o strings.Builder
+	if ve.Err != nil {
+		o.WriteString(ve.Err.Error())
+		o.WriteString(": ")
+	}
+	if ve.Path != "" {
+		o.WriteString(ve.Path)
+		o.WriteString(": ")
+	}
+	o.WriteString(ve.Message)
+	if ve.Cause != nil {
+		o.WriteString(": ")
+		o.WriteString(ve.Cause.Error())
+	}
+	return o.String()
+}
+
+func (ve *ValidationError) Unwrap() []error {
+	var errs []error
+	if ve.Err != nil {
+		errs = append(errs, ve.Err)
+	}
+	if ve.Cause != nil {
+		errs = append(errs, ve.Cause)
+	}
+	return errs
+}
+
+// ValidationErrors returns all of the *ValidationError values contained in the
+// error, including the ones combined with errors.Join.
+func ValidationErrors(err error) []*ValidationError {
+	var list []*ValidationError
+	walkValidationErrors(err, func(ve *ValidationError) {
+		list = append(list, ve)
+	})
+	return list
+}
+
+// walkValidationErrors calls fn for each *ValidationError in the error tree.
+// The errors wrapped by a *ValidationError are not visited.
+func walkValidationErrors(err error, fn func(*ValidationError)) {
+	if err == nil {
+		return
+	}
+
+	switch e := err.(type) {
+	case *ValidationError:
+		fn(e)
+	case interface{ Unwrap() []error }:
+		for _, err := range e.Unwrap() {
+			walkValidationErrors(err, fn)
+		}
+	case interface{ Unwrap() error }:
+		walkValidationErrors(e.Unwrap(), fn)
+	}
+}
+
+// unsupported returns the error used when an option does not apply to the
+// registration version it is validating.
+func unsupported(msg string) error {
+	return &ValidationError{
+		Err:     ErrInvalidType,
+		Code:    CodeUnsupported,
+		Message: msg,
+	}
+}
@@ -0,0 +1,148 @@
+// SPDX-FileCopyrightText: 2023 Comcast Cable Communications Management, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/urlegit"
+)
+
+func TestValidationError(t *testing.T) {
+	cause := errors.New("cause")
+	tests := []struct {
+		description string
+		err         *ValidationError
+		str         string
+		is          []error
+	}{
+		{
+			description: "all fields",
+			err: &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidRegex,
+				Path:    "matcher[2].regex",
+				Value:   "(",
+				Message: "unable to compile matching",
+				Cause:   cause,
+			},
+			str: "invalid input: matcher[2].regex: unable to compile matching: cause",
+			is:  []error{ErrInvalidInput, cause},
+		}, {
+			description: "no path",
+			err: &ValidationError{
+				Err:     ErrInvalidType,
+				Code:    CodeUnsupported,
+				Message: "not supported",
+			},
+			str: "invalid type: not supported",
+			is:  []error{ErrInvalidType},
+		}, {
+			description: "message only",
+			err:         &ValidationError{Message: "message"},
+			str:         "message",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			assert := assert.New(t)
+			assert.Equal(tc.str, tc.err.Error())
+			for _, target := range tc.is {
+				assert.ErrorIs(tc.err, target)
+			}
+			assert.Len(tc.err.Unwrap(), len(tc.is))
+		})
+	}
+}
+
+func TestValidationErrorJSON(t *testing.T) {
+	ve := ValidationError{
+		Err:     ErrInvalidInput,
+		Code:    CodeInvalidURL,
+		Path:    "failure_url",
+		Value:   "http://example.com",
+		Option:  "ProvideFailureURLValidator()",
+		Message: "failure url is invalid",
+		Cause:   errors.New("cause"),
+	}
+
+	b, err := json.Marshal(ve)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"code": "invalid_url",
+		"path": "failure_url",
+		"value": "http://example.com",
+		"option": "ProvideFailureURLValidator()",
+		"message": "failure url is invalid"
+	}`, string(b))
+}
+
+func TestValidationErrors(t *testing.T) {
+	a := &ValidationError{Message: "a"}
+	b := &ValidationError{Message: "b"}
+	c := &ValidationError{Message: "c"}
+
+	assert.Nil(t, ValidationErrors(nil))
+	assert.Nil(t, ValidationErrors(errors.New("plain")))
+	assert.Equal(t, []*ValidationError{a}, ValidationErrors(a))
+	assert.Equal(t, []*ValidationError{a, b, c},
+		ValidationErrors(errors.Join(a, fmt.Errorf("wrapped: %w", errors.Join(b, errors.New("plain"))), c)))
+}
+
+func TestValidatorsSetsPathsAndOptions(t *testing.T) {
+	checker, err := urlegit.New(urlegit.OnlyAllowSchemes("https"))
+	require.NoError(t, err)
+
+	receivers := ProvideReceiverURLValidator(checker)
+	regex := EventRegexMustCompile()
+	events := AtLeastOneEvent()
+
+	r := &RegistrationV2{
+		Webhooks: []Webhook{
+			{ReceiverURLs: []string{"https://example.com"}},
+			{ReceiverURLs: []string{"http://example.com", "https://example.com", "http://example.org"}},
+		},
+		Matcher: []FieldRegex{
+			{Field: FieldEvent, Regex: ".*"},
+			{Field: FieldEvent, Regex: "("},
+			{Field: FieldDeviceID, Regex: "["},
+		},
+	}
+
+	err = Validators{receivers, regex, events}.Validate(r)
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.ErrorIs(t, err, ErrInvalidType)
+
+	type found struct {
+		path, code, option string
+		value              any
+	}
+	var got []found
+	for _, ve := range ValidationErrors(err) {
+		got = append(got, found{path: ve.Path, code: ve.Code, option: ve.Option, value: ve.Value})
+	}
+
+	assert.Equal(t, []found{
+		{path: "webhooks[1].receiver_urls[0]", code: CodeInvalidURL, option: receivers.String(), value: "http://example.com"},
+		{path: "webhooks[1].receiver_urls[2]", code: CodeInvalidURL, option: receivers.String(), value: "http://example.org"},
+		{path: "matcher[1].regex", code: CodeInvalidRegex, option: regex.String(), value: "("},
+		{path: "matcher[2].regex", code: CodeInvalidRegex, option: regex.String(), value: "["},
+		{code: CodeUnsupported, option: events.String()},
+	}, got)
+}
+
+func TestValidatorsKeepsExistingOption(t *testing.T) {
+	ve := &ValidationError{Err: ErrInvalidInput, Option: "original"}
+
+	err := Validators{Error(ve)}.Validate(&RegistrationV1{})
+	assert.ErrorIs(t, err, ErrInvalidInput)
+	assert.Equal(t, "original", ve.Option)
+}
@@ -4,21 +4,27 @@
 package webhook
 
 import (
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"net"
 	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/xmidt-org/urlegit"
 )
 
 var (
-	ErrInvalidInput  = fmt.Errorf("invalid input")
-	ErrInvalidType   = fmt.Errorf("invalid type")
-	ErrUknownType    = fmt.Errorf("unknown type")
-	errInvalidTTL    = errors.New("TTL must be non-negative")
-	errInvalidJitter = errors.New("jitter must be non-negative")
-	errInvalidUntil  = errors.New("until value of webhook is out of bounds")
+	ErrInvalidInput   = fmt.Errorf("invalid input")
+	ErrInvalidType    = fmt.Errorf("invalid type")
+	ErrUknownType     = fmt.Errorf("unknown type")
+	errInvalidTTL     = errors.New("TTL must be non-negative")
+	errInvalidJitter  = errors.New("jitter must be non-negative")
+	errInvalidUntil   = errors.New("until value of webhook is out of bounds")
+	errInvalidExpires = errors.New("expires value of webhook is out of bounds")
 )
 
 // Deprecated: This substructure should only be used for backwards compatibility
@@ -33,7 +39,7 @@ type DeliveryConfig struct {
 
 	// Secret is the string value for the SHA1 HMAC.
 	// (Optional, set to "" to disable behavior).
-	Secret string `json:"secret,omitempty"`
+	Secret string `json:"secret,omitempty" redact:"true"`
 
 	// AlternativeURLs is a list of explicit URLs that should be round robin through on failure cases to the main URL.
 	AlternativeURLs []string `json:"alt_urls,omitempty"`
@@ -65,17 +71,23 @@ type RegistrationV1 struct {
 	// Matcher type contains values to match against the metadata.
 	Matcher MetadataMatcherConfig `json:"matcher,omitempty"`
 
-	// Duration describes how long the subscription lasts once added.
+	// Duration describes how long the subscription lasts once added.  It is
+	// marshaled in the style the client wrote it in.
 	Duration CustomDuration `json:"duration"`
 
-	// Until describes the time this subscription expires.
-	Until time.Time `json:"until"`
+	// Until describes the time this subscription expires.  See CustomTime
+	// for the supported formats.
+	Until CustomTime `json:"until"`
 
 	// now is a function that returns the current time.  It is used for testing.
 	nowFunc func() time.Time `json:"-"`
+
+	// durationStyle is the style Duration was unmarshaled from.
+	durationStyle DurationStyle
 }
 
 // RetryHint is the substructure for configuration related to retrying requests.
+// See RetryPlan for how the values are applied.
 type RetryHint struct {
 	//RetryEachUrl is the amount of times a URL should be retried given a failed response until the next URL in the request is tried.
 	//Default value will be set to none
@@ -83,6 +95,24 @@ type RetryHint struct {
 
 	//MaxRetry is the total amount times a request will be retried.
 	MaxRetry int `json:"max_retry"`
+
+	// InitialBackoff is the delay before the first retry.
+	InitialBackoff CustomDuration `json:"initial_backoff,omitempty"`
+
+	// BackoffMultiplier is the factor the delay grows by after each retry.  It
+	// must be at least 1 if it is set.
+	BackoffMultiplier float64 `json:"backoff_multiplier,omitempty"`
+
+	// MaxBackoff is the longest delay between two attempts.
+	MaxBackoff CustomDuration `json:"max_backoff,omitempty"`
+
+	// BackoffJitter is the largest fraction of the delay, between 0 and 1,
+	// that is randomly removed from each delay to spread the retries out.
+	BackoffJitter float64 `json:"backoff_jitter,omitempty"`
+
+	// HonorRetryAfter waits for at least the Retry-After duration of a
+	// response before retrying.
+	HonorRetryAfter bool `json:"honor_retry_after,omitempty"`
 }
 
 // DNSSrvRecord is the substructure for configuration related to load balancing.
@@ -91,7 +121,8 @@ type DNSSrvRecord struct {
 	FQDNs []string `json:"fqdns"`
 
 	// LoadBalancingScheme is the scheme to use for load balancing. Either the
-	// srv record attribute `weight` or `priortiy` can be used.
+	// srv record attribute `weight` or `priority` can be used.  The default is
+	// `priority`.  See SRVResolver for how the targets are ordered.
 	LoadBalancingScheme string `json:"load_balancing_scheme"`
 }
 
@@ -106,16 +137,25 @@ type Webhook struct {
 			application/wrp+octet-stream - one message with the wrp payload in the http payload
 			application/wrp+jsonl - multiple jsonl encoded wrp messages
 			application/wrp+msgpackl - multiple msgpackl encoded wrp messages
+		See DefaultEncoders for how each media type is encoded.
 	*/
+	// The value may also be a list with q-values and wildcards, see NegotiateAccept.
 	Accept string `json:"accept"`
 
 	// AcceptEncoding is the content type of outgoing events. The following content types are supported, otherwise
-	// a 406 response code is returned: gzip.
+	// a 406 response code is returned: gzip, zstd, br, deflate.
+	// The value may also be a list with q-values and wildcards, see NegotiateAcceptEncoding.
 	AcceptEncoding string `json:"accept_encoding"`
 
+	// CompressionLevel is a hint for the compression level used with the
+	// AcceptEncoding.  The level is specific to the encoding: 1-9 for gzip
+	// and deflate, 1-11 for br and 1-22 for zstd.
+	// (Optional, the default value uses the default level of the encoding).
+	CompressionLevel int `json:"compression_level,omitempty"`
+
 	// Secret is the string value.
 	// (Optional, set to "" to disable behavior).
-	Secret string `json:"secret,omitempty"`
+	Secret string `json:"secret,omitempty" redact:"true"`
 
 	// SecretHash is the hash algorithm to be used. Only sha256 HMAC and sha512 HMAC are supported.
 	// (Optional).
@@ -141,6 +181,94 @@ type Webhook struct {
 	RetryHint RetryHint `json:"retry_hint"`
 }
 
+// Media types that can be used as the Webhook Accept value.
+const (
+	MediaTypeWRPJSON        = "application/wrp+json"
+	MediaTypeWRPMsgpack     = "application/wrp+msgpack"
+	MediaTypeWRPOctetStream = "application/wrp+octet-stream"
+	MediaTypeWRPJSONL       = "application/wrp+jsonl"
+	MediaTypeWRPMsgpackL    = "application/wrp+msgpackl"
+)
+
+// Media types that can be used as the Kafka Accept value.
+const (
+	MediaTypeOctetStream = "application/octet-stream"
+	MediaTypeJSON        = "application/json"
+	MediaTypeJSONL       = "application/jsonl"
+	MediaTypeMsgpack     = "application/msgpack"
+)
+
+// Encodings that can be used as the Webhook AcceptEncoding value.
+const (
+	EncodingGzip    = "gzip"
+	EncodingZstd    = "zstd"
+	EncodingBrotli  = "br"
+	EncodingDeflate = "deflate"
+)
+
+// Hash algorithms that can be used as the Webhook SecretHash value.
+const (
+	SecretHashSHA256 = "sha256"
+	SecretHashSHA512 = "sha512"
+)
+
+// Load balancing schemes that can be used as the DNSSrvRecord
+// LoadBalancingScheme value.
+const (
+	LoadBalancingPriority = "priority"
+	LoadBalancingWeight   = "weight"
+)
+
+var (
+	webhookAcceptTypes = []string{
+		MediaTypeWRPJSON,
+		MediaTypeWRPMsgpack,
+		MediaTypeWRPOctetStream,
+		MediaTypeWRPJSONL,
+		MediaTypeWRPMsgpackL,
+	}
+
+	kafkaAcceptTypes = []string{
+		MediaTypeOctetStream,
+		MediaTypeJSON,
+		MediaTypeJSONL,
+		MediaTypeMsgpack,
+	}
+
+	secretHashes = []string{
+		SecretHashSHA256,
+		SecretHashSHA512,
+	}
+
+	acceptEncodings = []string{
+		EncodingGzip,
+		EncodingZstd,
+		EncodingBrotli,
+		EncodingDeflate,
+	}
+
+	loadBalancingSchemes = []string{
+		LoadBalancingPriority,
+		LoadBalancingWeight,
+	}
+)
+
+// levelRange is the range of compression levels an encoding supports.  A
+// level of 0 always means the default level of the encoding.
+type levelRange struct {
+	min, max int
+}
+
+// compressionLevels are the levels that can be used as the Webhook
+// CompressionLevel for each encoding.  They match the levels the compress
+// package accepts.
+var compressionLevels = map[string]levelRange{
+	EncodingGzip:    {min: 1, max: 9},
+	EncodingDeflate: {min: 1, max: 9},
+	EncodingBrotli:  {min: 1, max: 11},
+	EncodingZstd:    {min: 1, max: 22},
+}
+
 // Kafka is a substructure with data related to event delivery.
 type Kafka struct {
 	// Accept is the encoding type of outgoing events. The following encoding types are supported, otherwise
@@ -148,21 +276,140 @@ type Kafka struct {
 	// Note: An `Accept` of application/octet-stream or application/json will result in a single response for batch sizes of 0 or 1
 	// and batch sizes greater than 1 will result in a multipart response. An `Accept` of application/jsonl or application/msgpack
 	// will always result in a single response with a list of batched events for any batch size.
+	// The value may also be a list with q-values and wildcards, see NegotiateAccept.
 	Accept string `json:"accept"`
 
 	// BootstrapServers is a list of kafka broker addresses.
 	BootstrapServers []string `json:"bootstrap_servers"`
 
-	// TODO: figure out which kafka configuration substructures we want to expose to users (to be set by users)
-	// going to be based on https://pkg.go.dev/github.com/IBM/sarama#Config
-	// this substructures also includes auth related secrets, noted `MaxOpenRequests` will be excluded since it's already exposed
-	KafkaProducer struct{} `json:"kafka_producer"`
+	// KafkaProducer is the substructure for configuration related to producing events to kafka.
+	// (Optional, if omited then the producer defaults described by KafkaProducer are used)
+	KafkaProducer KafkaProducer `json:"kafka_producer"`
 
 	//RetryHint is the substructure for configuration related to retrying requests.
 	// (Optional, if omited then retries will be based on default values defined by server)
 	RetryHint RetryHint `json:"retry_hint"`
 }
 
+// KafkaProducer is the substructure for configuration related to producing events to kafka.
+// It is based on https://pkg.go.dev/github.com/IBM/sarama#Config, noted `MaxOpenRequests` is
+// excluded since it's managed by the server.
+type KafkaProducer struct {
+	// Compression is the codec used to compress produced messages. One of none, gzip, snappy, lz4 or zstd.
+	// Default: none.
+	Compression string `json:"compression,omitempty"`
+
+	// CompressionLevel is the level used by the Compression codec. Only gzip (1-9), lz4 (1-12) and
+	// zstd (1-22) support levels.
+	// Default: 0, the default level of the codec.
+	CompressionLevel int `json:"compression_level,omitempty"`
+
+	// RequiredAcks is the level of acknowledgement needed from the brokers before a message is
+	// considered produced. One of none, local (the leader only) or all (all in-sync replicas).
+	// Default: local.
+	RequiredAcks string `json:"required_acks,omitempty"`
+
+	// Idempotent ensures that exactly one copy of each message is written. It requires a
+	// RequiredAcks of all.
+	// Default: false.
+	Idempotent bool `json:"idempotent,omitempty"`
+
+	// Linger is the maximum amount of time messages are buffered before they are sent as a batch.
+	// Default: 0, messages are sent as soon as possible.
+	Linger CustomDuration `json:"linger,omitempty"`
+
+	// MaxMessageBytes is the maximum size of a produced message in bytes.
+	// Default: 0, the server default of 1000000 bytes is used.
+	MaxMessageBytes int `json:"max_message_bytes,omitempty"`
+
+	// SASL is the substructure for configuration related to authenticating with the brokers.
+	// (Optional, if omited then SASL authentication is not used)
+	SASL KafkaSASL `json:"sasl"`
+
+	// TLS is the substructure for configuration related to the connections to the brokers.
+	// (Optional, if omited then TLS is not used)
+	TLS KafkaTLS `json:"tls"`
+}
+
+// KafkaSASL is the substructure for configuration related to SASL authentication.
+type KafkaSASL struct {
+	// Mechanism is the SASL mechanism to use. One of PLAIN, SCRAM-SHA-256 or SCRAM-SHA-512.
+	// Default: "", SASL authentication is not used.
+	Mechanism string `json:"mechanism,omitempty"`
+
+	// User is the user to authenticate as.
+	User string `json:"user,omitempty"`
+
+	// Password is the password of the User.
+	Password string `json:"password,omitempty" redact:"true"`
+}
+
+// KafkaTLS is the substructure for configuration related to TLS connections.
+type KafkaTLS struct {
+	// Enable turns on TLS for the connections to the brokers.
+	// Default: false.
+	Enable bool `json:"enable"`
+
+	// InsecureSkipVerify disables the verification of the broker certificates.
+	// Default: false.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+
+	// ServerName is the name used to verify the broker certificates.
+	// Default: "", the host of the bootstrap server is used.
+	ServerName string `json:"server_name,omitempty"`
+
+	// CACert is the PEM encoded certificate authority used to verify the broker certificates.
+	// Default: "", the system certificate authorities are used.
+	CACert string `json:"ca_cert,omitempty"`
+}
+
+// Values that can be used in the KafkaProducer substructure.
+const (
+	KafkaCompressionNone   = "none"
+	KafkaCompressionGzip   = "gzip"
+	KafkaCompressionSnappy = "snappy"
+	KafkaCompressionLZ4    = "lz4"
+	KafkaCompressionZstd   = "zstd"
+
+	KafkaAcksNone  = "none"
+	KafkaAcksLocal = "local"
+	KafkaAcksAll   = "all"
+
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+var (
+	kafkaCompressions = []string{
+		KafkaCompressionNone,
+		KafkaCompressionGzip,
+		KafkaCompressionSnappy,
+		KafkaCompressionLZ4,
+		KafkaCompressionZstd,
+	}
+
+	// kafkaCompressionLevels are the inclusive ranges of the supported
+	// compression levels of the codecs that support levels.
+	kafkaCompressionLevels = map[string][2]int{
+		KafkaCompressionGzip: {1, 9},
+		KafkaCompressionLZ4:  {1, 12},
+		KafkaCompressionZstd: {1, 22},
+	}
+
+	kafkaAcks = []string{
+		KafkaAcksNone,
+		KafkaAcksLocal,
+		KafkaAcksAll,
+	}
+
+	kafkaSASLMechanisms = []string{
+		KafkaSASLPlain,
+		KafkaSASLScramSHA256,
+		KafkaSASLScramSHA512,
+	}
+)
+
 // FieldRegex is a substructure with data related to regular expressions.
 type FieldRegex struct {
 	// Field is the wrp field to be used for regex.
@@ -173,6 +420,26 @@ type FieldRegex struct {
 	Regex string `json:"regex"`
 }
 
+// Field names that can be used in FieldRegex.Field in addition to the wrp
+// message fields.
+const (
+	// FieldEvent is the event type of a wrp message, which is the destination
+	// with the `event:` prefix removed.  RegistrationV1.Events are matched
+	// against this field.
+	FieldEvent = "event"
+
+	// FieldDeviceID is the device id of a wrp message, which is the source
+	// without any service or path suffix.  RegistrationV1.Matcher.DeviceID is
+	// matched against this field.
+	FieldDeviceID = "device_id"
+
+	// FieldMetadataPrefix is the prefix of a field that refers to a single
+	// metadata value of a wrp message, for example `metadata//hw-model`.
+	FieldMetadataPrefix = "metadata/"
+)
+
+// BatchHint describes how events are batched.  A BatchHint of all zeros
+// disables batching.  See Batcher for a reference implementation.
 type BatchHint struct {
 	// MaxLingerDuration is the maximum delay for batching if MaxMesasges has not been reached.
 	// Default value will set no maximum value.
@@ -222,12 +489,20 @@ type RegistrationV2 struct {
 	FailureURL string `json:"failure_url"`
 
 	// Matcher is the list of regular expressions to match incoming events against to.
-	// Note. Any failures due to a bad regex field or regex expression will result in a silent failure.
+	// Matchers for the same field match if any of them match, and matchers for
+	// different fields must all match.  No matchers matches every event.
+	// Note. A bad regex field or regex expression is rejected by NewMatcher.
 	Matcher []FieldRegex `json:"matcher,omitempty"`
 
-	// Expires describes the time this subscription expires.
-	// TODO: list of supported formats
-	Expires time.Time `json:"expires"`
+	// Expires describes the time this subscription expires.  See CustomTime
+	// for the supported formats.
+	Expires CustomTime `json:"expires"`
+
+	// now is a function that returns the current time.  It is used for testing.
+	nowFunc func() time.Time `json:"-"`
+
+	// durationStyle is the style Duration was unmarshaled from.
+	durationStyle DurationStyle
 }
 
 type Option interface {
@@ -237,12 +512,18 @@ type Option interface {
 type Validators []Option
 
 // Validate is a method that validates the registration
-// against a list of options.
+// against a list of options.  Any *ValidationError returned by an option has
+// its Option field set to the String() of that option.
 func (vs Validators) Validate(r any) error {
 	var errs error
 	for _, opt := range vs {
 		if opt != nil {
 			if err := opt.Validate(r); err != nil {
+				walkValidationErrors(err, func(ve *ValidationError) {
+					if ve.Option == "" {
+						ve.Option = opt.String()
+					}
+				})
 				errs = errors.Join(errs, err)
 			}
 		}
@@ -252,17 +533,29 @@ func (vs Validators) Validate(r any) error {
 
 func (v1 *RegistrationV1) ValidateOneEvent() error {
 	if len(v1.Events) == 0 {
-		return fmt.Errorf("%w: cannot have zero events", ErrInvalidInput)
+		return &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    "events",
+			Message: "cannot have zero events",
+		}
 	}
 	return nil
 }
 
 func (v1 *RegistrationV1) ValidateEventRegex() error {
 	var errs error
-	for _, e := range v1.Events {
+	for i, e := range v1.Events {
 		_, err := regexp.Compile(e)
 		if err != nil {
-			errs = errors.Join(errs, fmt.Errorf("%w: unable to compile matching", ErrInvalidInput))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidRegex,
+				Path:    fmt.Sprintf("events[%d]", i),
+				Value:   e,
+				Message: "unable to compile matching",
+				Cause:   err,
+			})
 		}
 	}
 	return errs
@@ -270,10 +563,17 @@ func (v1 *RegistrationV1) ValidateEventRegex() error {
 
 func (v1 *RegistrationV1) ValidateDeviceId() error {
 	var errs error
-	for _, e := range v1.Matcher.DeviceID {
+	for i, e := range v1.Matcher.DeviceID {
 		_, err := regexp.Compile(e)
 		if err != nil {
-			errs = errors.Join(errs, fmt.Errorf("%w: unable to compile matching", ErrInvalidInput))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidRegex,
+				Path:    fmt.Sprintf("matcher.device_id[%d]", i),
+				Value:   e,
+				Message: "unable to compile matching",
+				Cause:   err,
+			})
 		}
 	}
 	return errs
@@ -286,39 +586,65 @@ func (v1 *RegistrationV1) ValidateDuration(ttl time.Duration) error {
 	}
 
 	if ttl != 0 && ttl < time.Duration(v1.Duration) {
-		errs = errors.Join(errs, fmt.Errorf("%w: the registration is for too long", ErrInvalidInput))
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    "duration",
+			Value:   v1.Duration.String(),
+			Message: "the registration is for too long",
+		})
 	}
 
 	if v1.Until.IsZero() && v1.Duration == 0 {
-		errs = errors.Join(errs, fmt.Errorf("%w: either Duration or Until must be set", ErrInvalidInput))
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    "duration",
+			Message: "either Duration or Until must be set",
+		})
 	}
 
 	if !v1.Until.IsZero() && v1.Duration != 0 {
-		errs = errors.Join(errs, fmt.Errorf("%w: only one of Duration or Until may be set", ErrInvalidInput))
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeConflict,
+			Path:    "until",
+			Value:   v1.Until,
+			Message: "only one of Duration or Until may be set",
+		})
 	}
 
 	if !v1.Until.IsZero() {
-		nowFunc := time.Now
-		if v1.nowFunc != nil {
-			nowFunc = v1.nowFunc
-		}
-
-		now := nowFunc()
+		now := v1.now()
 		if ttl != 0 && v1.Until.After(now.Add(ttl)) {
-			errs = errors.Join(errs, fmt.Errorf("%w: the registration is for too long", ErrInvalidInput))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    "until",
+				Value:   v1.Until,
+				Message: "the registration is for too long",
+			})
 		}
 
 		if v1.Until.Before(now) {
-			errs = errors.Join(errs, fmt.Errorf("%w: the registration has already expired", ErrInvalidInput))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeExpired,
+				Path:    "until",
+				Value:   v1.Until,
+				Message: "the registration has already expired",
+			})
 		}
 	}
 
 	return errs
 }
 
+// CheckUntil ensures that Until is no later than now plus the maxTTL and
+// jitter.  If now is nil, the registration's clock is used.
 func (v1 *RegistrationV1) CheckUntil(now func() time.Time, jitter, maxTTL time.Duration) error {
 	if now == nil {
-		now = time.Now
+		now = v1.now
 	}
 
 	if maxTTL < 0 {
@@ -331,10 +657,15 @@ func (v1 *RegistrationV1) CheckUntil(now func() time.Time, jitter, maxTTL time.D
 		return nil
 	}
 	limit := (now().Add(maxTTL)).Add(jitter)
-	proposed := (v1.Until)
+	proposed := v1.Until.Time
 	if proposed.After(limit) {
-		return fmt.Errorf("%w: %v after %v",
-			errInvalidUntil, proposed.String(), limit.String())
+		return &ValidationError{
+			Err:     errInvalidUntil,
+			Code:    CodeOutOfRange,
+			Path:    "until",
+			Value:   proposed,
+			Message: fmt.Sprintf("%v after %v", proposed.String(), limit.String()),
+		}
 	}
 	return nil
 
@@ -343,7 +674,14 @@ func (v1 *RegistrationV1) CheckUntil(now func() time.Time, jitter, maxTTL time.D
 func (v1 *RegistrationV1) ValidateReceiverURL(c *urlegit.Checker) error {
 	if v1.Config.ReceiverURL != "" {
 		if err := c.Text(v1.Config.ReceiverURL); err != nil {
-			return fmt.Errorf("%w: failure url is invalid", ErrInvalidInput)
+			return &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidURL,
+				Path:    "config.url",
+				Value:   v1.Config.ReceiverURL,
+				Message: "receiver url is invalid",
+				Cause:   err,
+			}
 		}
 	}
 	return nil
@@ -351,9 +689,16 @@ func (v1 *RegistrationV1) ValidateReceiverURL(c *urlegit.Checker) error {
 
 func (v1 *RegistrationV1) ValidateAltURL(c *urlegit.Checker) error {
 	var errs error
-	for _, url := range v1.Config.AlternativeURLs {
+	for i, url := range v1.Config.AlternativeURLs {
 		if err := c.Text(url); err != nil {
-			errs = errors.Join(errs, fmt.Errorf("%w: failure url is invalid", ErrInvalidInput))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidURL,
+				Path:    fmt.Sprintf("config.alt_urls[%d]", i),
+				Value:   url,
+				Message: "alternative url is invalid",
+				Cause:   err,
+			})
 		}
 	}
 	return errs
@@ -361,7 +706,13 @@ func (v1 *RegistrationV1) ValidateAltURL(c *urlegit.Checker) error {
 
 func (v1 *RegistrationV1) ValidateNoUntil() error {
 	if !v1.Until.IsZero() {
-		return fmt.Errorf("%w: Until is not allowed", ErrInvalidInput)
+		return &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeNotAllowed,
+			Path:    "until",
+			Value:   v1.Until,
+			Message: "Until is not allowed",
+		}
 	}
 	return nil
 }
@@ -380,48 +731,910 @@ func (v1 *RegistrationV1) ValidateUntil(jitter time.Duration, maxTTL time.Durati
 		return nil
 	}
 	limit := (now().Add(maxTTL)).Add(jitter)
-	proposed := (v1.Until)
+	proposed := v1.Until.Time
 	if proposed.After(limit) {
-		return fmt.Errorf("%w: %v after %v",
-			ErrInvalidInput, proposed.String(), limit.String())
+		return &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    "until",
+			Value:   proposed,
+			Message: fmt.Sprintf("%v after %v", proposed.String(), limit.String()),
+		}
 	}
 	return nil
 
 }
 
+// ClampUntil shortens the registration to the maximum TTL instead of
+// rejecting it.  If Until is after now plus the maxTTL and jitter, it is set
+// to now plus the maxTTL.  If Duration is longer than the maxTTL plus jitter,
+// it is set to the maxTTL.  A maxTTL of zero disables clamping.
+func (v1 *RegistrationV1) ClampUntil(now func() time.Time, jitter, maxTTL time.Duration) error {
+	if now == nil {
+		now = v1.now
+	}
+
+	if maxTTL < 0 {
+		return errInvalidTTL
+	} else if jitter < 0 {
+		return errInvalidJitter
+	} else if maxTTL == 0 {
+		return nil
+	}
+
+	if time.Duration(v1.Duration) > maxTTL+jitter {
+		v1.Duration = CustomDuration(maxTTL)
+	}
+
+	v1.Until = clamp(v1.Until, now(), jitter, maxTTL)
+	return nil
+}
+
+// SetNowFunc sets the clock of the registration.  A relative Until is resolved
+// against the clock.
 func (v1 *RegistrationV1) SetNowFunc(now func() time.Time) {
 	v1.nowFunc = now
+	v1.Until.resolve(v1.now())
+}
+
+func (v1 *RegistrationV1) now() time.Time {
+	if v1.nowFunc != nil {
+		return v1.nowFunc()
+	}
+	return time.Now()
+}
+
+// v1JSON is RegistrationV1 without its json methods.
+type v1JSON RegistrationV1
+
+// MarshalJSON writes Duration in the style it was unmarshaled from.
+func (v1 RegistrationV1) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		v1JSON
+		Duration StyledDuration `json:"duration"`
+	}{
+		v1JSON:   v1JSON(v1),
+		Duration: v1.Duration.Styled(v1.durationStyle),
+	})
+}
+
+// UnmarshalJSON remembers the style Duration is written in, so MarshalJSON
+// writes it back the same way.
+func (v1 *RegistrationV1) UnmarshalJSON(b []byte) error {
+	aux := struct {
+		*v1JSON
+		Duration StyledDuration `json:"duration"`
+	}{
+		v1JSON:   (*v1JSON)(v1),
+		Duration: v1.Duration.Styled(v1.durationStyle),
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	v1.Duration = aux.Duration.Duration
+	v1.durationStyle = aux.Duration.Style
+	return nil
+}
+
+// clamp returns the time limited to now plus the maxTTL, if it is after now
+// plus the maxTTL and jitter.
+func clamp(t CustomTime, now time.Time, jitter, maxTTL time.Duration) CustomTime {
+	if !t.IsZero() && t.After(now.Add(maxTTL).Add(jitter)) {
+		return CustomTime{Time: now.Add(maxTTL)}
+	}
+	return t
 }
 
 func (v2 *RegistrationV2) ValidateEventRegex() error {
 	var errs error
-	for _, m := range v2.Matcher {
+	for i, m := range v2.Matcher {
 		_, err := regexp.Compile(m.Regex)
 		if err != nil {
-			errs = errors.Join(fmt.Errorf("%w: %v", ErrInvalidInput, err))
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidRegex,
+				Path:    fmt.Sprintf("matcher[%d].regex", i),
+				Value:   m.Regex,
+				Message: "unable to compile matching",
+				Cause:   err,
+			})
 		}
 	}
 	return errs
 }
 
+// ValidateDuration ensures that Expires is set and is in the future.
 func (v2 *RegistrationV2) ValidateDuration() error {
-	now := time.Now()
-	if now.After(v2.Expires) {
-		return fmt.Errorf("%w: the registration has already expired", ErrInvalidInput)
+	return v2.ValidateDurationTTL(0)
+}
+
+// ValidateDurationTTL ensures that Expires is set and is in the future.  If
+// the ttl is greater than zero, Expires must also be within the ttl of now.
+func (v2 *RegistrationV2) ValidateDurationTTL(ttl time.Duration) error {
+	if v2.Expires.IsZero() {
+		return &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    "expires",
+			Message: "Expires must be set",
+		}
+	}
+
+	var errs error
+	now := v2.now()
+	if ttl > 0 && v2.Expires.After(now.Add(ttl)) {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    "expires",
+			Value:   v2.Expires,
+			Message: "the registration is for too long",
+		})
+	}
+
+	if now.After(v2.Expires.Time) {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeExpired,
+			Path:    "expires",
+			Value:   v2.Expires,
+			Message: "the registration has already expired",
+		})
+	}
+	return errs
+}
+
+// CheckExpires ensures that Expires is no later than now plus the maxTTL and
+// jitter.  If now is nil, the registration's clock is used.
+func (v2 *RegistrationV2) CheckExpires(now func() time.Time, jitter, maxTTL time.Duration) error {
+	if now == nil {
+		now = v2.now
+	}
+
+	if maxTTL < 0 {
+		return errInvalidTTL
+	} else if jitter < 0 {
+		return errInvalidJitter
+	}
+
+	if v2.Expires.IsZero() {
+		return nil
+	}
+	limit := now().Add(maxTTL).Add(jitter)
+	proposed := v2.Expires.Time
+	if proposed.After(limit) {
+		return &ValidationError{
+			Err:     errInvalidExpires,
+			Code:    CodeOutOfRange,
+			Path:    "expires",
+			Value:   v2.Expires,
+			Message: fmt.Sprintf("%v after %v", proposed.String(), limit.String()),
+		}
 	}
 	return nil
 }
 
+// ClampExpires shortens the registration to the maximum TTL instead of
+// rejecting it.  If Expires is after now plus the maxTTL and jitter, it is set
+// to now plus the maxTTL.  A maxTTL of zero disables clamping.
+func (v2 *RegistrationV2) ClampExpires(now func() time.Time, jitter, maxTTL time.Duration) error {
+	if now == nil {
+		now = v2.now
+	}
+
+	if maxTTL < 0 {
+		return errInvalidTTL
+	} else if jitter < 0 {
+		return errInvalidJitter
+	} else if maxTTL == 0 {
+		return nil
+	}
+
+	v2.Expires = clamp(v2.Expires, now(), jitter, maxTTL)
+	return nil
+}
+
+// SetNowFunc sets the clock of the registration.  A relative Expires is resolved
+// against the clock.
+func (v2 *RegistrationV2) SetNowFunc(now func() time.Time) {
+	v2.nowFunc = now
+	v2.Expires.resolve(v2.now())
+}
+
+func (v2 *RegistrationV2) now() time.Time {
+	if v2.nowFunc != nil {
+		return v2.nowFunc()
+	}
+	return time.Now()
+}
+
 func (v2 *RegistrationV2) ValidateReceiverURL(checker *urlegit.Checker) error {
 	var errs error
-	for _, w := range v2.Webhooks {
-		for _, url := range w.ReceiverURLs {
+	for i, w := range v2.Webhooks {
+		for j, url := range w.ReceiverURLs {
 			if url != "" {
 				if err := checker.Text(url); err != nil {
-					errs = errors.Join(errs, fmt.Errorf("%w: receiver url [%v] is invalid for webhook [%v]", ErrInvalidInput, url, w))
+					errs = errors.Join(errs, &ValidationError{
+						Err:     ErrInvalidInput,
+						Code:    CodeInvalidURL,
+						Path:    fmt.Sprintf("webhooks[%d].receiver_urls[%d]", i, j),
+						Value:   url,
+						Message: fmt.Sprintf("receiver url [%v] is invalid for webhook [%v]", url, w.Redacted()),
+						Cause:   err,
+					})
 				}
 			}
 		}
 	}
 	return errs
 }
+
+func (v2 *RegistrationV2) ValidateReceivers() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		urls := len(w.ReceiverURLs) > 0
+		// The schema tells the two apart by the fqdns, so a load balancing
+		// scheme on its own does not count as using the dns srv record.
+		srv := len(w.DNSSrvRecord.FQDNs) > 0
+		if urls && srv {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeConflict,
+				Path:    fmt.Sprintf("webhooks[%d].dns_srv_record", i),
+				Message: "only one of receiver_urls or dns_srv_record may be used",
+			})
+		} else if !urls && !srv {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeRequired,
+				Path:    fmt.Sprintf("webhooks[%d].receiver_urls", i),
+				Message: "either receiver_urls or dns_srv_record must be used",
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateAccept ensures that the Accept value of each webhook allows at
+// least one of the supported media types.  The value may be a single media
+// type or a list with q-values and wildcards.
+func (v2 *RegistrationV2) ValidateAccept() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		if _, err := w.NegotiateAccept(); err != nil {
+			errs = errors.Join(errs, notAcceptable(fmt.Sprintf("webhooks[%d].accept", i), w.Accept, err))
+		}
+	}
+	return errs
+}
+
+// ValidateAcceptEncoding ensures that the AcceptEncoding value of each
+// webhook allows at least one of the supported encodings.  The value may be a
+// single encoding or a list with q-values and wildcards.
+func (v2 *RegistrationV2) ValidateAcceptEncoding() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		if _, err := w.NegotiateAcceptEncoding(); err != nil {
+			errs = errors.Join(errs, notAcceptable(fmt.Sprintf("webhooks[%d].accept_encoding", i), w.AcceptEncoding, err))
+		}
+	}
+	return errs
+}
+
+// ValidateCompressionLevel ensures the CompressionLevel of each webhook is
+// valid for the encoding its AcceptEncoding negotiates to.  An AcceptEncoding
+// that cannot be negotiated is left to ValidateNegotiableAccept.
+func (v2 *RegistrationV2) ValidateCompressionLevel() error {
+	var errs error
+	for i := range v2.Webhooks {
+		w := &v2.Webhooks[i]
+		if w.CompressionLevel == 0 {
+			continue
+		}
+
+		encoding, err := w.NegotiateAcceptEncoding()
+		if err != nil {
+			continue
+		}
+
+		path := fmt.Sprintf("webhooks[%d].compression_level", i)
+		levels, found := compressionLevels[encoding]
+		if !found {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeConflict,
+				Path:    path,
+				Value:   w.CompressionLevel,
+				Message: fmt.Sprintf("must not be set for the '%s' encoding", encoding),
+			})
+			continue
+		}
+		if w.CompressionLevel < levels.min || w.CompressionLevel > levels.max {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path,
+				Value:   w.CompressionLevel,
+				Message: fmt.Sprintf("must be between %d and %d for the '%s' encoding", levels.min, levels.max, encoding),
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateNegotiableAccept ensures that the Accept and AcceptEncoding values
+// of each webhook, and the Accept value of each kafka, allow at least one of
+// the supported values.  It is ValidateAccept, ValidateAcceptEncoding and
+// ValidateKafkaAccept together.
+func (v2 *RegistrationV2) ValidateNegotiableAccept() error {
+	return errors.Join(v2.ValidateAccept(), v2.ValidateAcceptEncoding(), v2.ValidateKafkaAccept())
+}
+
+func (v2 *RegistrationV2) ValidateSecretHash() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		if err := oneOf(fmt.Sprintf("webhooks[%d].secret_hash", i), w.SecretHash, secretHashes); err != nil {
+			errs = errors.Join(errs, err)
+		}
+	}
+	return errs
+}
+
+func (v2 *RegistrationV2) ValidateRetryHint() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		errs = errors.Join(errs, w.RetryHint.validate(fmt.Sprintf("webhooks[%d].retry_hint", i)))
+	}
+	return errs
+}
+
+func (v2 *RegistrationV2) ValidateDNSSrvRecord() error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		errs = errors.Join(errs, w.DNSSrvRecord.validate(fmt.Sprintf("webhooks[%d].dns_srv_record", i)))
+	}
+	return errs
+}
+
+func (v2 *RegistrationV2) ValidateBootstrapServers() error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		if len(k.BootstrapServers) == 0 {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeRequired,
+				Path:    fmt.Sprintf("kafkas[%d].bootstrap_servers", i),
+				Message: "cannot have zero bootstrap servers",
+			})
+		}
+	}
+	return errs
+}
+
+func (v2 *RegistrationV2) ValidateBootstrapServerSyntax() error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		for j, server := range k.BootstrapServers {
+			if _, _, err := splitBootstrapServer(server); err != nil {
+				errs = errors.Join(errs, &ValidationError{
+					Err:     ErrInvalidInput,
+					Code:    CodeInvalidValue,
+					Path:    fmt.Sprintf("kafkas[%d].bootstrap_servers[%d]", i, j),
+					Value:   server,
+					Message: "bootstrap server must be in the form host:port",
+					Cause:   err,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateBootstrapServerPolicy ensures the bootstrap servers are allowed by
+// the lists of allowed and denied servers.  Each list entry is either a host,
+// which matches any port, or a host:port.  A host starting with `*.` matches
+// any subdomain of the rest of the host.  Denied servers take precedence over
+// allowed servers, and an empty allowed list allows any server.
+func (v2 *RegistrationV2) ValidateBootstrapServerPolicy(allow, deny []string) error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		for j, server := range k.BootstrapServers {
+			host, port, err := splitBootstrapServer(server)
+			if err != nil {
+				// The syntax is validated by ValidateBootstrapServerSyntax.
+				continue
+			}
+
+			if matchServer(deny, host, port) || (len(allow) > 0 && !matchServer(allow, host, port)) {
+				errs = errors.Join(errs, &ValidationError{
+					Err:     ErrInvalidInput,
+					Code:    CodeNotAllowed,
+					Path:    fmt.Sprintf("kafkas[%d].bootstrap_servers[%d]", i, j),
+					Value:   server,
+					Message: "bootstrap server is not allowed",
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// ValidateKafkaAccept ensures that the Accept value of each kafka allows at
+// least one of the supported media types.  The value may be a single media
+// type or a list with q-values and wildcards.
+func (v2 *RegistrationV2) ValidateKafkaAccept() error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		if _, err := k.NegotiateAccept(); err != nil {
+			errs = errors.Join(errs, notAcceptable(fmt.Sprintf("kafkas[%d].accept", i), k.Accept, err))
+		}
+	}
+	return errs
+}
+
+// ValidateKafkaRetryHint ensures the RetryHint values of each kafka are not
+// negative and are not larger than the maximums.  A maximum less than or equal
+// to zero is not enforced.
+func (v2 *RegistrationV2) ValidateKafkaRetryHint(maxRetryEachURL, maxRetry int) error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		path := fmt.Sprintf("kafkas[%d].retry_hint", i)
+		errs = errors.Join(errs, k.RetryHint.validate(path))
+		if maxRetryEachURL > 0 && k.RetryHint.RetryEachUrl > maxRetryEachURL {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path + ".retry_each_url",
+				Value:   k.RetryHint.RetryEachUrl,
+				Message: fmt.Sprintf("must not be larger than %d", maxRetryEachURL),
+			})
+		}
+		if maxRetry > 0 && k.RetryHint.MaxRetry > maxRetry {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path + ".max_retry",
+				Value:   k.RetryHint.MaxRetry,
+				Message: fmt.Sprintf("must not be larger than %d", maxRetry),
+			})
+		}
+	}
+	return errs
+}
+
+// ValidateBatchHint ensures the BatchHint values are not negative.
+func (v2 *RegistrationV2) ValidateBatchHint() error {
+	return v2.BatchHint.validate("batch_hints")
+}
+
+// ValidateRetryBackoff ensures the backoff values of the RetryHint of each
+// webhook and kafka are within the bounds.  Negative values are left to
+// ValidateRetryHint.
+func (v2 *RegistrationV2) ValidateRetryBackoff(minBackoff, maxBackoff time.Duration, maxMultiplier float64) error {
+	var errs error
+	for i, w := range v2.Webhooks {
+		path := fmt.Sprintf("webhooks[%d].retry_hint", i)
+		errs = errors.Join(errs, w.RetryHint.validateBackoff(path, minBackoff, maxBackoff, maxMultiplier))
+	}
+	for i, k := range v2.Kafkas {
+		path := fmt.Sprintf("kafkas[%d].retry_hint", i)
+		errs = errors.Join(errs, k.RetryHint.validateBackoff(path, minBackoff, maxBackoff, maxMultiplier))
+	}
+	return errs
+}
+
+// ValidateKafkaProducer ensures the KafkaProducer values of each kafka are
+// supported and do not contradict each other.
+func (v2 *RegistrationV2) ValidateKafkaProducer() error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		errs = errors.Join(errs, k.KafkaProducer.validate(fmt.Sprintf("kafkas[%d].kafka_producer", i)))
+	}
+	return errs
+}
+
+// ValidateKafkaProducerSecurity ensures the KafkaProducer of each kafka does
+// not send credentials over an unencrypted connection and does not disable
+// the verification of the broker certificates.
+func (v2 *RegistrationV2) ValidateKafkaProducerSecurity() error {
+	var errs error
+	for i, k := range v2.Kafkas {
+		path := fmt.Sprintf("kafkas[%d].kafka_producer", i)
+		p := k.KafkaProducer
+		if (p.SASL.Mechanism != "" || p.SASL.User != "" || p.SASL.Password != "") && !p.TLS.Enable {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeConflict,
+				Path:    path + ".tls.enable",
+				Message: "sasl credentials require tls to be enabled",
+			})
+		}
+		if p.TLS.InsecureSkipVerify {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeNotAllowed,
+				Path:    path + ".tls.insecure_skip_verify",
+				Message: "skipping the verification of broker certificates is not allowed",
+			})
+		}
+	}
+	return errs
+}
+
+// validate ensures the KafkaProducer values are supported and do not
+// contradict each other.  The path is the json path of the KafkaProducer.
+func (kp KafkaProducer) validate(path string) error {
+	errs := errors.Join(
+		oneOf(path+".compression", kp.Compression, kafkaCompressions),
+		oneOf(path+".required_acks", kp.RequiredAcks, kafkaAcks),
+		oneOf(path+".sasl.mechanism", kp.SASL.Mechanism, kafkaSASLMechanisms),
+	)
+
+	if kp.CompressionLevel != 0 {
+		levels, ok := kafkaCompressionLevels[kp.Compression]
+		if !ok {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeConflict,
+				Path:    path + ".compression_level",
+				Value:   kp.CompressionLevel,
+				Message: fmt.Sprintf("compression %q does not support levels", kp.Compression),
+			})
+		} else if kp.CompressionLevel < levels[0] || kp.CompressionLevel > levels[1] {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path + ".compression_level",
+				Value:   kp.CompressionLevel,
+				Message: fmt.Sprintf("must be between %d and %d for %s", levels[0], levels[1], kp.Compression),
+			})
+		}
+	}
+
+	if kp.Idempotent && kp.RequiredAcks != KafkaAcksAll {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeConflict,
+			Path:    path + ".required_acks",
+			Value:   kp.RequiredAcks,
+			Message: "idempotent requires required_acks to be all",
+		})
+	}
+
+	if kp.Linger < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".linger",
+			Value:   kp.Linger.String(),
+			Message: "must be non-negative",
+		})
+	}
+
+	if kp.MaxMessageBytes < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".max_message_bytes",
+			Value:   kp.MaxMessageBytes,
+			Message: "must be non-negative",
+		})
+	}
+
+	if kp.SASL.Mechanism != "" && (kp.SASL.User == "" || kp.SASL.Password == "") {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    path + ".sasl",
+			Message: "a sasl mechanism requires a user and password",
+		})
+	}
+	if kp.SASL.Mechanism == "" && (kp.SASL.User != "" || kp.SASL.Password != "") {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeRequired,
+			Path:    path + ".sasl.mechanism",
+			Message: "sasl credentials require a mechanism",
+		})
+	}
+
+	if !kp.TLS.Enable && (kp.TLS.InsecureSkipVerify || kp.TLS.ServerName != "" || kp.TLS.CACert != "") {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeConflict,
+			Path:    path + ".tls.enable",
+			Message: "tls settings are set but tls is not enabled",
+		})
+	}
+
+	if kp.TLS.CACert != "" {
+		if block, _ := pem.Decode([]byte(kp.TLS.CACert)); block == nil {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidValue,
+				Path:    path + ".tls.ca_cert",
+				Message: "must be a PEM encoded certificate",
+			})
+		}
+	}
+
+	return errs
+}
+
+// validate checks the FQDNs and the LoadBalancingScheme, using the path as the
+// prefix of the ValidationError paths.
+func (d DNSSrvRecord) validate(path string) error {
+	var errs error
+	for i, fqdn := range d.FQDNs {
+		if err := validateFQDN(fqdn); err != nil {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeInvalidValue,
+				Path:    fmt.Sprintf("%s.fqdns[%d]", path, i),
+				Value:   fqdn,
+				Message: "invalid fqdn",
+				Cause:   err,
+			})
+		}
+	}
+	return errors.Join(errs, oneOf(path+".load_balancing_scheme", d.LoadBalancingScheme, loadBalancingSchemes))
+}
+
+// validateFQDN checks the syntax of a fully qualified domain name, which may
+// have a trailing dot.  Underscores are allowed since srv record names start
+// with the `_service._proto` labels.
+func validateFQDN(fqdn string) error {
+	name := strings.TrimSuffix(fqdn, ".")
+	if name == "" {
+		return errors.New("empty name")
+	}
+	if len(name) > 253 {
+		return errors.New("longer than 253 characters")
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" {
+			return errors.New("empty label")
+		}
+		if len(label) > 63 {
+			return fmt.Errorf("label %q is longer than 63 characters", label)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("label %q starts or ends with a hyphen", label)
+		}
+		for _, c := range label {
+			if c != '-' && c != '_' && (c < 'a' || 'z' < c) && (c < 'A' || 'Z' < c) && (c < '0' || '9' < c) {
+				return fmt.Errorf("label %q has the invalid character %q", label, c)
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitBootstrapServer splits a kafka broker address into the host and port,
+// ensuring the port is in the range 1-65535.
+func splitBootstrapServer(server string) (string, int, error) {
+	host, p, err := net.SplitHostPort(server)
+	if err != nil {
+		return "", 0, err
+	}
+	if host == "" {
+		return "", 0, errors.New("missing host")
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q", p)
+	}
+	if port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("port %d is out of range", port)
+	}
+
+	return host, port, nil
+}
+
+// matchServer returns true if the host and port match any of the entries.
+func matchServer(entries []string, host string, port int) bool {
+	host = strings.ToLower(host)
+	for _, entry := range entries {
+		entryHost, entryPort, err := splitBootstrapServer(entry)
+		if err != nil {
+			entryHost, entryPort = entry, 0
+		}
+		entryHost = strings.ToLower(entryHost)
+
+		if entryPort != 0 && entryPort != port {
+			continue
+		}
+
+		if suffix, found := strings.CutPrefix(entryHost, "*"); found {
+			if strings.HasPrefix(suffix, ".") && strings.HasSuffix(host, suffix) {
+				return true
+			}
+			continue
+		}
+
+		if entryHost == host {
+			return true
+		}
+	}
+	return false
+}
+
+// validate ensures the RetryHint values are not negative.  The path is the
+// json path of the RetryHint.
+func (rh RetryHint) validate(path string) error {
+	var errs error
+	if rh.RetryEachUrl < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".retry_each_url",
+			Value:   rh.RetryEachUrl,
+			Message: "must be non-negative",
+		})
+	}
+	if rh.MaxRetry < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".max_retry",
+			Value:   rh.MaxRetry,
+			Message: "must be non-negative",
+		})
+	}
+	if rh.InitialBackoff < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".initial_backoff",
+			Value:   rh.InitialBackoff.String(),
+			Message: "must be non-negative",
+		})
+	}
+	if rh.MaxBackoff < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".max_backoff",
+			Value:   rh.MaxBackoff.String(),
+			Message: "must be non-negative",
+		})
+	} else if rh.MaxBackoff > 0 && rh.MaxBackoff < rh.InitialBackoff {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeConflict,
+			Path:    path + ".max_backoff",
+			Value:   rh.MaxBackoff.String(),
+			Message: "must not be shorter than initial_backoff",
+		})
+	}
+	if rh.BackoffMultiplier != 0 && !(rh.BackoffMultiplier >= 1) {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".backoff_multiplier",
+			Value:   rh.BackoffMultiplier,
+			Message: "must be at least 1",
+		})
+	}
+	if !(rh.BackoffJitter >= 0 && rh.BackoffJitter <= 1) {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".backoff_jitter",
+			Value:   rh.BackoffJitter,
+			Message: "must be between 0 and 1",
+		})
+	}
+	return errs
+}
+
+// validate checks that the BatchHint values are not negative, using the path
+// as the prefix of the ValidationError paths.
+func (bh BatchHint) validate(path string) error {
+	var errs error
+	if bh.MaxLingerDuration < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".max_linger_duration",
+			Value:   bh.MaxLingerDuration.String(),
+			Message: "must be non-negative",
+		})
+	}
+	if bh.MaxMesasges < 0 {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".max_messages",
+			Value:   bh.MaxMesasges,
+			Message: "must be non-negative",
+		})
+	}
+	return errs
+}
+
+// validateBackoff checks the backoff values against the bounds.  A bound less
+// than or equal to zero is not enforced, and neither are unset values.
+func (rh RetryHint) validateBackoff(path string, minBackoff, maxBackoff time.Duration, maxMultiplier float64) error {
+	var errs error
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{name: "initial_backoff", value: time.Duration(rh.InitialBackoff)},
+		{name: "max_backoff", value: time.Duration(rh.MaxBackoff)},
+	}
+	for _, d := range durations {
+		if d.value <= 0 {
+			continue
+		}
+		if minBackoff > 0 && d.value < minBackoff {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path + "." + d.name,
+				Value:   d.value.String(),
+				Message: fmt.Sprintf("must not be shorter than %v", minBackoff),
+			})
+		}
+		if maxBackoff > 0 && d.value > maxBackoff {
+			errs = errors.Join(errs, &ValidationError{
+				Err:     ErrInvalidInput,
+				Code:    CodeOutOfRange,
+				Path:    path + "." + d.name,
+				Value:   d.value.String(),
+				Message: fmt.Sprintf("must not be longer than %v", maxBackoff),
+			})
+		}
+	}
+	if maxMultiplier > 0 && rh.BackoffMultiplier > maxMultiplier {
+		errs = errors.Join(errs, &ValidationError{
+			Err:     ErrInvalidInput,
+			Code:    CodeOutOfRange,
+			Path:    path + ".backoff_multiplier",
+			Value:   rh.BackoffMultiplier,
+			Message: fmt.Sprintf("must not be larger than %v", maxMultiplier),
+		})
+	}
+	return errs
+}
+
+// oneOf returns an error if the value is neither empty nor one of the allowed
+// values.  The path is the json path of the value.
+func oneOf(path, value string, allowed []string) error {
+	if value == "" || contains(allowed, value) {
+		return nil
+	}
+	return &ValidationError{
+		Err:     ErrInvalidInput,
+		Code:    CodeInvalidValue,
+		Path:    path,
+		Value:   value,
+		Message: "must be one of: " + strings.Join(allowed, ", "),
+	}
+}
+
+// notAcceptable returns the ValidationError for a value that failed to
+// negotiate.
+func notAcceptable(path, value string, err error) error {
+	return &ValidationError{
+		Err:     ErrInvalidInput,
+		Code:    CodeNotAcceptable,
+		Path:    path,
+		Value:   value,
+		Message: "does not allow any supported value",
+		Cause:   err,
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}